@@ -5,16 +5,18 @@ import (
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
+	"practicum-gophermart/internal/accrual"
 	"practicum-gophermart/internal/api"
 	"practicum-gophermart/internal/app"
 	"practicum-gophermart/internal/config"
+	"practicum-gophermart/internal/expiry"
 	"practicum-gophermart/internal/storage"
 )
 
 func main() {
 	gin.SetMode(gin.ReleaseMode)
 
-	cfgOptions := []string{config.WithFlag, config.WithEnv}
+	cfgOptions := []string{config.WithFlag, config.WithEnv, config.WithFile}
 	newCfg, err := config.New(cfgOptions...)
 	if err != nil {
 		log.Fatal().Err(err).Strs("cfg options", cfgOptions).Msg("creating new config")
@@ -40,7 +42,19 @@ func main() {
 	}
 	log.Info().Msg("app created")
 
-	newAPI, err := api.New(newApp)
+	newAccrualWorker, err := accrual.New(newApp)
+	if err != nil {
+		log.Fatal().Err(err).Str("config", newCfg.String()).Msg("creating new accrual worker")
+	}
+	log.Info().Msg("accrual worker created")
+
+	newExpiryJob, err := expiry.New(newApp)
+	if err != nil {
+		log.Fatal().Err(err).Str("config", newCfg.String()).Msg("creating new points expiry job")
+	}
+	log.Info().Msg("points expiry job created")
+
+	newAPI, err := api.New(newApp, newAccrualWorker, newExpiryJob)
 	if err != nil {
 		log.Fatal().Err(err).Str("config", newCfg.String()).Msg("creating new API")
 	}
@@ -0,0 +1,131 @@
+// Package accrualtest provides a scriptable fake of the accrual system for tests.
+package accrualtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+const getOrderPath = "/api/orders/"
+
+// Response is the scripted response of the fake accrual system.
+type Response struct {
+	Status     string
+	RetryAfter string
+	Code       int
+	Accrual    float64
+}
+
+// OK returns response with the order in the status.
+func OK(status string, accrual float64) Response {
+	return Response{Code: http.StatusOK, Status: status, Accrual: accrual}
+}
+
+// NoContent returns response for the order not registered in the accrual system.
+func NoContent() Response {
+	return Response{Code: http.StatusNoContent}
+}
+
+// TooManyRequests returns response asking to retry after retryAfter.
+func TooManyRequests(retryAfter string) Response {
+	return Response{Code: http.StatusTooManyRequests, RetryAfter: retryAfter}
+}
+
+// InternalServerError returns response of the failed accrual system.
+func InternalServerError() Response {
+	return Response{Code: http.StatusInternalServerError}
+}
+
+type order struct {
+	Order   string  `json:"order"`
+	Status  string  `json:"status"`
+	Accrual float64 `json:"accrual,omitempty"`
+}
+
+// Server is the fake accrual system.
+// Responses are scripted per order number and returned in order, the last one is repeated.
+// Orders without script are not registered.
+type Server struct {
+	*httptest.Server
+	responses map[string][]Response
+	requests  map[string]int
+	mu        sync.Mutex
+}
+
+// NewServer starts new Server. It should be closed by the caller.
+func NewServer() *Server {
+	s := &Server{
+		responses: map[string][]Response{},
+		requests:  map[string]int{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.getOrder))
+	return s
+}
+
+// GetOrderURL returns URL of the order with {number} path parameter.
+func (s *Server) GetOrderURL() string {
+	return s.URL + getOrderPath + "{number}"
+}
+
+// Script sets responses of the server for the order.
+func (s *Server) Script(number string, responses ...Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.responses[number] = responses
+}
+
+// Requests returns how many times the order was requested.
+func (s *Server) Requests(number string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests[number]
+}
+
+func (s *Server) next(number string) Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests[number]++
+
+	responses := s.responses[number]
+	if len(responses) == 0 {
+		return NoContent()
+	}
+
+	resp := responses[0]
+	if len(responses) > 1 {
+		s.responses[number] = responses[1:]
+	}
+	return resp
+}
+
+func (s *Server) getOrder(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet || !strings.HasPrefix(r.URL.Path, getOrderPath) {
+		http.NotFound(w, r)
+		return
+	}
+
+	number := strings.TrimPrefix(r.URL.Path, getOrderPath)
+	resp := s.next(number)
+
+	switch resp.Code {
+	case http.StatusOK:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(order{Order: number, Status: resp.Status, Accrual: resp.Accrual})
+	case http.StatusTooManyRequests:
+		if resp.RetryAfter != "" {
+			w.Header().Set("Retry-After", resp.RetryAfter)
+		}
+		http.Error(w, "No more than N requests per minute allowed", http.StatusTooManyRequests)
+	case http.StatusNoContent:
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, http.StatusText(resp.Code), resp.Code)
+	}
+}
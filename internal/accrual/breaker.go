@@ -0,0 +1,121 @@
+package accrual
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+type breakerState int32
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	return [...]string{"closed", "open", "half-open"}[s]
+}
+
+var errBreakerIsOpen = errors.New("accrual system circuit breaker is open")
+
+// breaker stops requests to the accrual system after threshold consecutive failures.
+// After openTimeout a single probe request is allowed, its result closes or reopens the breaker.
+type breaker struct {
+	openedAt    time.Time
+	mu          sync.Mutex
+	openTimeout time.Duration
+	threshold   int
+	failures    int
+	state       breakerState
+	probing     bool
+}
+
+func newBreaker(threshold int, openTimeout time.Duration) *breaker {
+	breakerStateMetric.Store(int32(breakerClosed))
+	return &breaker{threshold: threshold, openTimeout: openTimeout}
+}
+
+// allow returns errBreakerIsOpen if request to the accrual system is not allowed now.
+func (b *breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.openTimeout {
+			return errBreakerIsOpen
+		}
+		b.setState(breakerHalfOpen)
+		b.probing = true
+		return nil
+	case breakerHalfOpen:
+		if b.probing {
+			return errBreakerIsOpen
+		}
+		b.probing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.probing = false
+	if b.state != breakerClosed {
+		b.setState(breakerClosed)
+	}
+}
+
+// release ends the probe request without changing the state, the next request is the probe again.
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+func (b *breaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	b.failures++
+	if b.state == breakerHalfOpen || (b.state == breakerClosed && b.failures >= b.threshold) {
+		b.openedAt = time.Now()
+		b.setState(breakerOpen)
+	}
+}
+
+// isOpen reports whether the breaker is open and the open timeout has not passed yet.
+func (b *breaker) isOpen() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state == breakerOpen && time.Since(b.openedAt) < b.openTimeout
+}
+
+func (b *breaker) currentState() breakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state
+}
+
+func (b *breaker) setState(state breakerState) {
+	log.Warn().
+		Str("from", b.state.String()).
+		Str("to", state.String()).
+		Int("failures", b.failures).
+		Msg("accrual system circuit breaker state changed")
+
+	b.state = state
+	breakerStateMetric.Store(int32(state))
+}
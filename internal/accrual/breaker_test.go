@@ -0,0 +1,48 @@
+package accrual
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_breaker(t *testing.T) {
+	b := newBreaker(2, time.Millisecond*50)
+
+	assert.NoError(t, b.allow())
+	b.failure()
+	assert.Equal(t, breakerClosed, b.currentState())
+
+	b.failure()
+	assert.Equal(t, breakerOpen, b.currentState())
+	assert.ErrorIs(t, b.allow(), errBreakerIsOpen)
+
+	time.Sleep(time.Millisecond * 60)
+
+	assert.NoError(t, b.allow(), "probe request must be allowed after open timeout")
+	assert.Equal(t, breakerHalfOpen, b.currentState())
+	assert.ErrorIs(t, b.allow(), errBreakerIsOpen, "only one probe request is allowed")
+
+	b.failure()
+	assert.Equal(t, breakerOpen, b.currentState(), "failed probe must reopen breaker")
+
+	time.Sleep(time.Millisecond * 60)
+
+	assert.NoError(t, b.allow())
+	b.success()
+	assert.Equal(t, breakerClosed, b.currentState(), "successful probe must close breaker")
+	assert.NoError(t, b.allow())
+}
+
+func Test_breaker_release(t *testing.T) {
+	b := newBreaker(1, time.Millisecond)
+
+	b.failure()
+	time.Sleep(time.Millisecond * 5)
+
+	assert.NoError(t, b.allow())
+	b.release()
+	assert.Equal(t, breakerHalfOpen, b.currentState(), "released probe must not change state")
+	assert.NoError(t, b.allow(), "next probe request must be allowed after release")
+}
@@ -0,0 +1,96 @@
+package accrual
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/rs/zerolog/log"
+
+	"practicum-gophermart/internal/model"
+)
+
+var (
+	ErrOrderNotRegistered   = errors.New("order is not registered in accrual system")
+	ErrTooManyRequests      = errors.New("too many requests to accrual system")
+	ErrAccrualUnavailable   = errors.New("accrual system is unavailable")
+	errUnexpectedStatusCode = errors.New("unexpected status code")
+)
+
+// OrderResult is the order as returned by the accrual system.
+// RetryAfter is set when the accrual system asks to slow down.
+type OrderResult struct {
+	Order      string
+	Status     OrderStatus
+	Accrual    model.Money
+	RetryAfter time.Duration
+}
+
+// AccrualClient requests orders from the accrual system.
+//
+// GetOrder returns ErrOrderNotRegistered if the accrual system doesn't know the order,
+// ErrTooManyRequests with RetryAfter set in the result if the request is rate limited
+// and ErrAccrualUnavailable if the accrual system fails to process the request.
+type AccrualClient interface {
+	GetOrder(ctx context.Context, number string) (OrderResult, error)
+}
+
+// RestyClient is AccrualClient over HTTP.
+type RestyClient struct {
+	client      *resty.Client
+	getOrderURL string
+}
+
+// NewRestyClient returns new RestyClient.
+// getOrderURL is the URL of the order with {number} path parameter.
+func NewRestyClient(getOrderURL string, timeout time.Duration) *RestyClient {
+	return &RestyClient{
+		client:      resty.New().SetTimeout(timeout),
+		getOrderURL: getOrderURL,
+	}
+}
+
+func (c *RestyClient) GetOrder(ctx context.Context, number string) (OrderResult, error) {
+	resp, err := c.client.R().
+		SetContext(ctx).
+		SetPathParam(accrualSystemMethodGetOrderParamNumber, number).
+		Get(c.getOrderURL)
+	if err != nil {
+		return OrderResult{}, fmt.Errorf("getting order with number %s from accrual system: %w: %v",
+			number, ErrAccrualUnavailable, err)
+	}
+
+	switch {
+	case resp.StatusCode() == http.StatusOK:
+	case resp.StatusCode() == http.StatusNoContent:
+		return OrderResult{}, ErrOrderNotRegistered
+	case resp.StatusCode() == http.StatusTooManyRequests:
+		retryAfter, errParsing := parseRetryAfter(resp.Header().Get(accrualRetryAfterHeader), time.Now())
+		if errParsing != nil {
+			log.Error().Err(errParsing).Str("default", defaultRetryAfter.String()).Msg("parsing retry time from accrual system")
+			retryAfter = defaultRetryAfter
+		}
+		return OrderResult{RetryAfter: retryAfter}, ErrTooManyRequests
+	case resp.StatusCode() >= http.StatusInternalServerError:
+		return OrderResult{}, fmt.Errorf("getting order with number %s from accrual system: %w: status code %d",
+			number, ErrAccrualUnavailable, resp.StatusCode())
+	default:
+		return OrderResult{}, fmt.Errorf("getting order with number %s from accrual system: %w: %d",
+			number, errUnexpectedStatusCode, resp.StatusCode())
+	}
+
+	orderFromAccrualSystem := accrualSystemOrder{}
+	if err = json.Unmarshal(resp.Body(), &orderFromAccrualSystem); err != nil {
+		return OrderResult{}, fmt.Errorf("unmarshalling response body from accrual system: %w", err)
+	}
+
+	return OrderResult{
+		Order:   orderFromAccrualSystem.Order,
+		Status:  orderFromAccrualSystem.Status,
+		Accrual: orderFromAccrualSystem.Accrual,
+	}, nil
+}
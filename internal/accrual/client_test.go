@@ -0,0 +1,80 @@
+package accrual
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"practicum-gophermart/internal/accrual/accrualtest"
+)
+
+func TestRestyClient_GetOrder(t *testing.T) {
+	tests := []struct {
+		name           string
+		response       accrualtest.Response
+		expectedErr    error
+		expectedResult OrderResult
+	}{
+		{
+			name:           "processed",
+			response:       accrualtest.OK("PROCESSED", 729.98),
+			expectedResult: OrderResult{Order: "12345678903", Status: StatusProcessed, Accrual: 72998},
+		},
+		{
+			name:           "registered",
+			response:       accrualtest.OK("REGISTERED", 0),
+			expectedResult: OrderResult{Order: "12345678903", Status: StatusRegistered},
+		},
+		{
+			name:        "not registered",
+			response:    accrualtest.NoContent(),
+			expectedErr: ErrOrderNotRegistered,
+		},
+		{
+			name:           "too many requests",
+			response:       accrualtest.TooManyRequests("60"),
+			expectedErr:    ErrTooManyRequests,
+			expectedResult: OrderResult{RetryAfter: time.Minute},
+		},
+		{
+			name:           "too many requests with invalid retry after",
+			response:       accrualtest.TooManyRequests("soon"),
+			expectedErr:    ErrTooManyRequests,
+			expectedResult: OrderResult{RetryAfter: defaultRetryAfter},
+		},
+		{
+			name:        "internal server error",
+			response:    accrualtest.InternalServerError(),
+			expectedErr: ErrAccrualUnavailable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := accrualtest.NewServer()
+			defer server.Close()
+			server.Script("12345678903", tt.response)
+
+			client := NewRestyClient(server.GetOrderURL(), time.Second)
+
+			result, err := client.GetOrder(context.Background(), "12345678903")
+
+			assert.ErrorIs(t, err, tt.expectedErr)
+			assert.Equal(t, tt.expectedResult, result)
+			assert.Equal(t, 1, server.Requests("12345678903"))
+		})
+	}
+
+	t.Run("accrual system is down", func(t *testing.T) {
+		server := accrualtest.NewServer()
+		getOrderURL := server.GetOrderURL()
+		server.Close()
+
+		client := NewRestyClient(getOrderURL, time.Second)
+
+		_, err := client.GetOrder(context.Background(), "12345678903")
+
+		assert.ErrorIs(t, err, ErrAccrualUnavailable)
+	})
+}
@@ -0,0 +1,43 @@
+package accrual
+
+import (
+	"sync/atomic"
+
+	"github.com/rs/zerolog/log"
+)
+
+// failureTracker counts consecutive polling failures
+// and escalates when their number reaches the threshold.
+type failureTracker struct {
+	consecutive atomic.Int64
+	threshold   int64
+}
+
+func newFailureTracker(threshold int) *failureTracker {
+	return &failureTracker{threshold: int64(threshold)}
+}
+
+func (f *failureTracker) failure() {
+	pollFailures.Add(1)
+
+	consecutive := f.consecutive.Add(1)
+	consecutiveFailures.Set(consecutive)
+
+	if f.threshold > 0 && consecutive == f.threshold {
+		log.Error().Int64("consecutive_failures", consecutive).Msg("accrual polling is failing persistently")
+	}
+}
+
+// escalated reports whether the number of consecutive failures has reached the threshold.
+func (f *failureTracker) escalated() bool {
+	return f.threshold > 0 && f.consecutive.Load() >= f.threshold
+}
+
+func (f *failureTracker) success() {
+	consecutive := f.consecutive.Swap(0)
+	consecutiveFailures.Set(0)
+
+	if f.threshold > 0 && consecutive >= f.threshold {
+		log.Info().Int64("consecutive_failures", consecutive).Msg("accrual polling recovered")
+	}
+}
@@ -0,0 +1,27 @@
+package accrual
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_failureTracker(t *testing.T) {
+	tracker := newFailureTracker(3)
+
+	tracker.failure()
+	tracker.failure()
+	assert.Equal(t, int64(2), tracker.consecutive.Load())
+
+	tracker.success()
+	assert.Equal(t, int64(0), tracker.consecutive.Load())
+
+	for i := 0; i < 5; i++ {
+		tracker.failure()
+	}
+	assert.Equal(t, int64(5), tracker.consecutive.Load())
+	assert.Equal(t, int64(5), consecutiveFailures.Value())
+
+	tracker.success()
+	assert.Equal(t, int64(0), consecutiveFailures.Value())
+}
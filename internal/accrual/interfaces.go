@@ -0,0 +1,17 @@
+package accrual
+
+import (
+	"context"
+	"time"
+
+	"practicum-gophermart/internal/config"
+	"practicum-gophermart/internal/model"
+)
+
+type Application interface {
+	ClaimOrdersToPoll(c context.Context, owner string, leaseExpiresAt time.Time, statuses []string, dueAt time.Time,
+		limit int) ([]model.Order, error)
+	UpdateOrders(newOrderStatuses []model.Order) error
+	UpdateOrdersPollingState(c context.Context, owner string, orders []model.Order) error
+	Config() *config.Config
+}
@@ -0,0 +1,39 @@
+package accrual
+
+import (
+	"context"
+	"time"
+)
+
+// rateLimiter is a request budget shared by all workers of the pool.
+type rateLimiter struct {
+	ticker *time.Ticker
+}
+
+// newRateLimiter returns limiter that allows no more than rps requests per second.
+// Non-positive rps disables the limit.
+func newRateLimiter(rps int) *rateLimiter {
+	if rps <= 0 {
+		return &rateLimiter{}
+	}
+	return &rateLimiter{ticker: time.NewTicker(time.Second / time.Duration(rps))}
+}
+
+func (r *rateLimiter) wait(ctx context.Context) error {
+	if r.ticker == nil {
+		return ctx.Err()
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-r.ticker.C:
+		return nil
+	}
+}
+
+func (r *rateLimiter) stop() {
+	if r.ticker != nil {
+		r.ticker.Stop()
+	}
+}
@@ -0,0 +1,36 @@
+package accrual
+
+import (
+	"expvar"
+	"sync/atomic"
+	"time"
+)
+
+// metrics are published with expvar under the "accrual" key.
+var (
+	throttlePausedUntil atomic.Int64
+	breakerStateMetric  atomic.Int32
+	throttlePauses      = new(expvar.Int)
+	pollFailures        = new(expvar.Int)
+	consecutiveFailures = new(expvar.Int)
+)
+
+func init() {
+	m := expvar.NewMap("accrual")
+	m.Set("throttle_paused", expvar.Func(func() any {
+		return time.Now().UnixNano() < throttlePausedUntil.Load()
+	}))
+	m.Set("throttle_paused_until", expvar.Func(func() any {
+		until := throttlePausedUntil.Load()
+		if until == 0 {
+			return ""
+		}
+		return time.Unix(0, until).Format(time.RFC3339)
+	}))
+	m.Set("throttle_pauses_total", throttlePauses)
+	m.Set("poll_failures_total", pollFailures)
+	m.Set("breaker_state", expvar.Func(func() any {
+		return breakerState(breakerStateMetric.Load()).String()
+	}))
+	m.Set("consecutive_failures", consecutiveFailures)
+}
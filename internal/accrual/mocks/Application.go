@@ -0,0 +1,99 @@
+// Code generated by mockery v2.14.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	config "practicum-gophermart/internal/config"
+	model "practicum-gophermart/internal/model"
+	time "time"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// Application is an autogenerated mock type for the Application type
+type Application struct {
+	mock.Mock
+}
+
+// ClaimOrdersToPoll provides a mock function with given fields: c, owner, leaseExpiresAt, statuses, dueAt, limit
+func (_m *Application) ClaimOrdersToPoll(c context.Context, owner string, leaseExpiresAt time.Time, statuses []string, dueAt time.Time, limit int) ([]model.Order, error) {
+	ret := _m.Called(c, owner, leaseExpiresAt, statuses, dueAt, limit)
+
+	var r0 []model.Order
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time, []string, time.Time, int) []model.Order); ok {
+		r0 = rf(c, owner, leaseExpiresAt, statuses, dueAt, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Order)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, time.Time, []string, time.Time, int) error); ok {
+		r1 = rf(c, owner, leaseExpiresAt, statuses, dueAt, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Config provides a mock function with given fields:
+func (_m *Application) Config() *config.Config {
+	ret := _m.Called()
+
+	var r0 *config.Config
+	if rf, ok := ret.Get(0).(func() *config.Config); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*config.Config)
+		}
+	}
+
+	return r0
+}
+
+// UpdateOrders provides a mock function with given fields: newOrderStatuses
+func (_m *Application) UpdateOrders(newOrderStatuses []model.Order) error {
+	ret := _m.Called(newOrderStatuses)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func([]model.Order) error); ok {
+		r0 = rf(newOrderStatuses)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateOrdersPollingState provides a mock function with given fields: c, owner, orders
+func (_m *Application) UpdateOrdersPollingState(c context.Context, owner string, orders []model.Order) error {
+	ret := _m.Called(c, owner, orders)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, []model.Order) error); ok {
+		r0 = rf(c, owner, orders)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+type mockConstructorTestingTNewApplication interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+// NewApplication creates a new instance of Application. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+func NewApplication(t mockConstructorTestingTNewApplication) *Application {
+	mock := &Application{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,44 @@
+package accrual
+
+import "practicum-gophermart/internal/model"
+
+// OrderStatus is status of the order in the accrual system.
+type OrderStatus string
+
+const (
+	StatusRegistered OrderStatus = "REGISTERED"
+	StatusProcessing OrderStatus = "PROCESSING"
+	StatusProcessed  OrderStatus = "PROCESSED"
+	StatusInvalid    OrderStatus = "INVALID"
+
+	accrualSystemMethodGetOrderParamNumber = "number"
+
+	accrualRetryAfterHeader = "Retry-After"
+)
+
+type accrualSystemOrder struct {
+	Order   string      `json:"order"`
+	Status  OrderStatus `json:"status"`
+	Accrual model.Money `json:"accrual"`
+}
+
+func (s OrderStatus) isInvalid() bool {
+	return s == StatusInvalid
+}
+
+// orderStatus maps status of the accrual system to status of the order.
+// It reports false if the status is unknown.
+func (s OrderStatus) orderStatus() (model.OrderStatus, bool) {
+	switch s {
+	case StatusRegistered:
+		return model.OrderStatusNew, true
+	case StatusProcessing:
+		return model.OrderStatusProcessing, true
+	case StatusInvalid:
+		return model.OrderStatusInvalid, true
+	case StatusProcessed:
+		return model.OrderStatusProcessed, true
+	default:
+		return 0, false
+	}
+}
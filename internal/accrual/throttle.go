@@ -0,0 +1,92 @@
+package accrual
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const defaultRetryAfter = time.Second * 60
+
+var errInvalidRetryAfter = errors.New("invalid Retry-After header value")
+
+// throttle pauses traffic of all workers until the deadline requested by the accrual system.
+type throttle struct {
+	pausedUntil time.Time
+	mu          sync.RWMutex
+}
+
+func (t *throttle) pause(until time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !until.After(t.pausedUntil) {
+		return
+	}
+	t.pausedUntil = until
+
+	throttlePausedUntil.Store(until.UnixNano())
+	throttlePauses.Add(1)
+
+	log.Warn().
+		Time("paused_until", until).
+		Str("pause", time.Until(until).Round(time.Second).String()).
+		Msg("accrual system traffic paused")
+}
+
+// until returns the time traffic is paused until and reports whether it is paused now.
+func (t *throttle) until() (time.Time, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.pausedUntil, time.Now().Before(t.pausedUntil)
+}
+
+// wait blocks until the pause is over or ctx is done.
+func (t *throttle) wait(ctx context.Context) error {
+	for {
+		until, paused := t.until()
+		if !paused {
+			return ctx.Err()
+		}
+
+		timer := time.NewTimer(time.Until(until))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// parseRetryAfter parses Retry-After header value given either as delta-seconds or as HTTP-date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, errInvalidRetryAfter
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, errInvalidRetryAfter
+		}
+		return time.Second * time.Duration(seconds), nil
+	}
+
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, errInvalidRetryAfter
+	}
+
+	if retryAfter := date.Sub(now); retryAfter > 0 {
+		return retryAfter, nil
+	}
+	return 0, nil
+}
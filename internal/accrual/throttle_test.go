@@ -0,0 +1,90 @@
+package accrual
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseRetryAfter(t *testing.T) {
+	now := time.Date(2022, time.November, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		value    string
+		expected time.Duration
+		wantErr  bool
+	}{
+		{
+			name:     "delta seconds",
+			value:    "60",
+			expected: time.Second * 60,
+		},
+		{
+			name:     "delta seconds with spaces",
+			value:    " 5 ",
+			expected: time.Second * 5,
+		},
+		{
+			name:     "http date",
+			value:    "Tue, 01 Nov 2022 12:01:30 GMT",
+			expected: time.Second * 90,
+		},
+		{
+			name:     "http date in the past",
+			value:    "Tue, 01 Nov 2022 11:00:00 GMT",
+			expected: 0,
+		},
+		{
+			name:    "empty",
+			value:   "",
+			wantErr: true,
+		},
+		{
+			name:    "negative",
+			value:   "-1",
+			wantErr: true,
+		},
+		{
+			name:    "garbage",
+			value:   "soon",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			retryAfter, err := parseRetryAfter(tt.value, now)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, retryAfter)
+			}
+		})
+	}
+}
+
+func Test_throttle(t *testing.T) {
+	th := throttle{}
+
+	_, paused := th.until()
+	assert.False(t, paused)
+
+	th.pause(time.Now().Add(time.Millisecond * 50))
+	_, paused = th.until()
+	assert.True(t, paused)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	started := time.Now()
+	assert.NoError(t, th.wait(ctx))
+	assert.GreaterOrEqual(t, time.Since(started), time.Millisecond*40)
+
+	th.pause(time.Now().Add(time.Hour))
+	canceledCtx, cancelNow := context.WithCancel(context.Background())
+	cancelNow()
+	assert.ErrorIs(t, th.wait(canceledCtx), context.Canceled)
+}
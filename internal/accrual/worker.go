@@ -0,0 +1,421 @@
+package accrual
+
+//go:generate mockery --name Application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/errgroup"
+
+	"practicum-gophermart/internal/model"
+)
+
+var (
+	ErrEmptyApplication                 = errors.New("empty application")
+	ErrEmptyClient                      = errors.New("empty accrual client")
+	ErrInvalidIntervalUpdateOrderStatus = errors.New("invalid order status update interval")
+	ErrInvalidWorkersCount              = errors.New("invalid accrual workers count")
+	ErrInvalidPollBatchSize             = errors.New("invalid accrual poll batch size")
+	ErrInvalidLeaseTTL                  = errors.New("invalid accrual order lease ttl")
+	ErrInvalidBreakerThreshold          = errors.New("invalid accrual circuit breaker threshold")
+	ErrInvalidNotRegisteredTTL          = errors.New("invalid accrual not registered order ttl")
+)
+
+// Health is the state of the accrual system as seen by the worker.
+type Health struct {
+	PausedUntil         *time.Time `json:"paused_until,omitempty"`
+	Breaker             string     `json:"breaker"`
+	ConsecutiveFailures int64      `json:"consecutive_failures"`
+	Paused              bool       `json:"paused"`
+	Healthy             bool       `json:"healthy"`
+}
+
+// Worker polls the accrual system for orders with non-final statuses
+// using a bounded pool of goroutines.
+// Orders are leased to the worker while polled, so several instances of the service can run at once.
+type Worker struct {
+	app              Application
+	client           AccrualClient
+	limiter          *rateLimiter
+	throttle         *throttle
+	breaker          *breaker
+	failures         *failureTracker
+	owner            string
+	interval         time.Duration
+	leaseTTL         time.Duration
+	maxBackoff       time.Duration
+	notRegisteredTTL time.Duration
+	workersCount     int
+	batchSize        int
+}
+
+// New returns new Worker polling the accrual system configured in the application over HTTP.
+func New(application Application) (*Worker, error) {
+	if application == nil {
+		return nil, ErrEmptyApplication
+	}
+
+	config := application.Config()
+	return NewWithClient(application, NewRestyClient(config.AccrualGetOrder(), config.AccrualRequestTimeout()))
+}
+
+// NewWithClient returns new Worker polling the accrual system with the client.
+func NewWithClient(application Application, client AccrualClient) (newWorker *Worker, err error) {
+	log.Debug().Msg("accrual.NewWithClient START")
+	defer func() {
+		logMethodEnd("accrual.NewWithClient", err)
+	}()
+
+	if application == nil {
+		return nil, ErrEmptyApplication
+	}
+	if client == nil {
+		return nil, ErrEmptyClient
+	}
+
+	config := application.Config()
+
+	if config.OrderStatusUpdateInterval() <= 0 {
+		return nil, ErrInvalidIntervalUpdateOrderStatus
+	}
+	if config.AccrualWorkersCount() <= 0 {
+		return nil, ErrInvalidWorkersCount
+	}
+	if config.AccrualPollBatchSize() <= 0 {
+		return nil, ErrInvalidPollBatchSize
+	}
+	if config.AccrualLeaseTTL() <= 0 {
+		return nil, ErrInvalidLeaseTTL
+	}
+	if config.AccrualBreakerThreshold() <= 0 {
+		return nil, ErrInvalidBreakerThreshold
+	}
+	if config.AccrualNotRegisteredTTL() <= 0 {
+		return nil, ErrInvalidNotRegisteredTTL
+	}
+
+	newWorker = &Worker{
+		app:              application,
+		client:           client,
+		throttle:         &throttle{},
+		breaker:          newBreaker(config.AccrualBreakerThreshold(), config.AccrualBreakerOpenTimeout()),
+		failures:         newFailureTracker(config.AccrualFailureThreshold()),
+		owner:            newOwner(),
+		interval:         config.OrderStatusUpdateInterval(),
+		leaseTTL:         config.AccrualLeaseTTL(),
+		maxBackoff:       config.AccrualMaxPollBackoff(),
+		notRegisteredTTL: config.AccrualNotRegisteredTTL(),
+		workersCount:     config.AccrualWorkersCount(),
+		batchSize:        config.AccrualPollBatchSize(),
+	}
+
+	return newWorker, nil
+}
+
+// Run starts polling the accrual system and blocks until ctx is done.
+// Failed polling cycles are logged and retried on the next tick.
+func (w *Worker) Run(ctx context.Context) (err error) {
+	log.Debug().Msg("Worker.Run START")
+	defer func() {
+		logMethodEnd("Worker.Run", err)
+	}()
+
+	w.limiter = newRateLimiter(w.app.Config().AccrualRateLimit())
+	defer w.limiter.stop()
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if errUpdating := w.updateOrdersStatus(ctx); errUpdating != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				w.failures.failure()
+			}
+		}
+	}
+}
+
+func (w *Worker) updateOrdersStatus(ctx context.Context) (err error) {
+	log.Debug().Msg("Worker.updateOrdersStatus START")
+	defer func() {
+		logMethodEnd("Worker.updateOrdersStatus", err)
+	}()
+
+	if pausedUntil, paused := w.throttle.until(); paused {
+		log.Info().Time("paused_until", pausedUntil).Msg("accrual system traffic is paused")
+	}
+
+	if w.breaker.isOpen() {
+		log.Info().Msg("accrual system circuit breaker is open, polling skipped")
+		return nil
+	}
+
+	nonFinalStatuses := []string{model.OrderStatusNew.String(), model.OrderStatusProcessing.String()}
+	dueAt := time.Now()
+	for {
+		var ordersToPoll []model.Order
+		ordersToPoll, err = w.app.ClaimOrdersToPoll(ctx, w.owner, time.Now().Add(w.leaseTTL), nonFinalStatuses, dueAt, w.batchSize)
+		if err != nil {
+			return fmt.Errorf("claiming orders to poll : %w", err)
+		}
+
+		if len(ordersToPoll) == 0 {
+			return nil
+		}
+
+		if err = w.pollBatch(ctx, ordersToPoll); err != nil {
+			return err
+		}
+
+		if len(ordersToPoll) < w.batchSize {
+			return nil
+		}
+	}
+}
+
+// pollBatch polls the orders with the pool of workers, saves changed statuses and reschedules the orders.
+// Failure to poll one order doesn't stop polling of the others, the failed order is rescheduled with backoff.
+// The order skipped by the open circuit breaker is postponed without counting the attempt.
+func (w *Worker) pollBatch(ctx context.Context, ordersToPoll []model.Order) (err error) {
+	var mu sync.Mutex
+	ordersFromAccrualSystem := make([]model.Order, 0, len(ordersToPoll))
+	polledOrders := make([]model.Order, 0, len(ordersToPoll))
+
+	jobs := make(chan model.Order)
+	errG, errGCtx := errgroup.WithContext(ctx)
+	for i := 0; i < w.workersCount; i++ {
+		errG.Go(func() error {
+			for order := range jobs {
+				orderFromAccrualSystem, isChanged, pollResult, errPolling := w.pollOrder(errGCtx, order)
+				if errGCtx.Err() != nil {
+					return errGCtx.Err()
+				}
+
+				if pollResult == model.OrderPollResultSkipped {
+					w.postpone(&order, time.Now())
+				} else {
+					order.PollResult = pollResult.String()
+					w.schedule(&order, time.Now())
+				}
+
+				switch {
+				case errPolling == nil:
+					w.failures.success()
+				case errors.Is(errPolling, errBreakerIsOpen):
+					log.Debug().Str("order_number", order.Number).Msg("polling order skipped, circuit breaker is open")
+				default:
+					log.Error().
+						Err(errPolling).
+						Str("order_number", order.Number).
+						Str("poll_result", order.PollResult).
+						Int("attempts", order.Attempts).
+						Time("next_poll_at", order.NextPollAt).
+						Msg("polling order")
+					w.failures.failure()
+				}
+
+				mu.Lock()
+				polledOrders = append(polledOrders, order)
+				if isChanged {
+					ordersFromAccrualSystem = append(ordersFromAccrualSystem, orderFromAccrualSystem)
+				}
+				mu.Unlock()
+			}
+			return nil
+		})
+	}
+
+feeding:
+	for _, order := range ordersToPoll {
+		select {
+		case jobs <- order:
+		case <-errGCtx.Done():
+			break feeding
+		}
+	}
+	close(jobs)
+
+	if err = errG.Wait(); err != nil {
+		return err
+	}
+
+	if len(ordersFromAccrualSystem) > 0 {
+		if err = w.app.UpdateOrders(ordersFromAccrualSystem); err != nil {
+			return fmt.Errorf("updating orders: %w", err)
+		}
+	}
+
+	if err = w.app.UpdateOrdersPollingState(ctx, w.owner, polledOrders); err != nil {
+		return fmt.Errorf("updating orders polling state: %w", err)
+	}
+
+	return nil
+}
+
+// postpone sets the next poll of the order which was not polled in the poll interval, the attempt is not counted.
+func (w *Worker) postpone(order *model.Order, skippedAt time.Time) {
+	order.NextPollAt = skippedAt.Add(w.interval)
+}
+
+// schedule counts the poll of the order and sets the time of its next poll.
+func (w *Worker) schedule(order *model.Order, polledAt time.Time) {
+	order.Attempts++
+	order.LastPolledAt = polledAt
+	order.NextPollAt = polledAt.Add(w.backoff(order.Attempts))
+}
+
+// backoff returns the delay before the next poll of the order polled attempts times.
+// The delay doubles with every attempt and is limited by maxBackoff.
+func (w *Worker) backoff(attempts int) time.Duration {
+	delay := w.interval
+	for i := 1; i < attempts; i++ {
+		if delay >= w.maxBackoff {
+			break
+		}
+		delay *= 2
+	}
+
+	if w.maxBackoff > 0 && delay > w.maxBackoff {
+		return w.maxBackoff
+	}
+	return delay
+}
+
+// pollOrder requests order from accrual system.
+// It reports whether the status of the order has changed in the accrual system and the result of the poll.
+// Only the well-formed response closes the circuit breaker, the unexpected ones count as failures.
+func (w *Worker) pollOrder(ctx context.Context, order model.Order) (model.Order, bool, model.OrderPollResult, error) {
+	for {
+		if err := w.throttle.wait(ctx); err != nil {
+			return model.Order{}, false, model.OrderPollResultSkipped, err
+		}
+
+		if err := w.limiter.wait(ctx); err != nil {
+			return model.Order{}, false, model.OrderPollResultSkipped, err
+		}
+
+		if err := w.breaker.allow(); err != nil {
+			return model.Order{}, false, model.OrderPollResultSkipped, err
+		}
+
+		orderFromAccrualSystem, err := w.client.GetOrder(ctx, order.Number)
+		if ctx.Err() != nil {
+			return model.Order{}, false, model.OrderPollResultSkipped, ctx.Err()
+		}
+
+		switch {
+		case errors.Is(err, ErrAccrualUnavailable):
+			w.breaker.failure()
+			return model.Order{}, false, model.OrderPollResultAccrualUnavailable, err
+		case errors.Is(err, ErrTooManyRequests):
+			// the accrual system is up but asks to slow down, it neither closes nor opens the breaker
+			w.breaker.release()
+			w.throttle.pause(time.Now().Add(orderFromAccrualSystem.RetryAfter))
+			continue
+		case errors.Is(err, ErrOrderNotRegistered):
+			w.breaker.success()
+			newOrder, isChanged := w.notRegistered(order)
+			return newOrder, isChanged, model.OrderPollResultNotRegistered, nil
+		case err != nil:
+			// the unexpected status code or the malformed response
+			w.breaker.failure()
+			return model.Order{}, false, model.OrderPollResultError, err
+		}
+
+		newStatus, ok := orderFromAccrualSystem.Status.orderStatus()
+		if !ok {
+			w.breaker.failure()
+			log.Error().
+				Str("order_number", order.Number).
+				Str("status", string(orderFromAccrualSystem.Status)).
+				Msg("unknown order status from accrual system")
+			return model.Order{}, false, model.OrderPollResultError, nil
+		}
+		w.breaker.success()
+
+		if newStatus.String() == order.Status {
+			return model.Order{}, false, model.OrderPollResultFound, nil
+		}
+
+		if orderFromAccrualSystem.Status.isInvalid() {
+			orderFromAccrualSystem.Accrual = 0
+		}
+
+		return model.Order{
+			UserID:  order.UserID,
+			Number:  order.Number,
+			Status:  newStatus.String(),
+			Accrual: orderFromAccrualSystem.Accrual,
+		}, true, model.OrderPollResultFound, nil
+	}
+}
+
+// notRegistered gives up on the order not registered in the accrual system
+// for longer than notRegisteredTTL since upload and marks it invalid.
+// It reports whether the order was marked invalid.
+func (w *Worker) notRegistered(order model.Order) (model.Order, bool) {
+	if time.Since(order.UploadedAt) < w.notRegisteredTTL {
+		log.Debug().Str("order_number", order.Number).Int("attempts", order.Attempts).Msg("order is not registered in accrual system yet")
+		return model.Order{}, false
+	}
+
+	log.Warn().
+		Str("order_number", order.Number).
+		Time("uploaded_at", order.UploadedAt).
+		Int("attempts", order.Attempts).
+		Msg("order is not registered in accrual system, marked invalid")
+
+	return model.Order{
+		UserID: order.UserID,
+		Number: order.Number,
+		Status: model.OrderStatusInvalid.String(),
+	}, true
+}
+
+// Health returns current state of the accrual system.
+func (w *Worker) Health() Health {
+	breakerState := w.breaker.currentState()
+
+	health := Health{
+		Healthy:             breakerState == breakerClosed && !w.failures.escalated(),
+		Breaker:             breakerState.String(),
+		ConsecutiveFailures: w.failures.consecutive.Load(),
+	}
+
+	if pausedUntil, paused := w.throttle.until(); paused {
+		health.Paused = true
+		health.PausedUntil = &pausedUntil
+	}
+
+	return health
+}
+
+// newOwner returns unique name of the worker instance used as owner of order leases.
+func newOwner() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return hostname + "-" + uuid.New().String()
+}
+
+func logMethodEnd(method string, err error) {
+	msg := method + " END"
+	if err != nil {
+		log.Error().Err(err).Msg(msg)
+	} else {
+		log.Debug().Msg(msg)
+	}
+}
@@ -0,0 +1,270 @@
+package accrual
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"practicum-gophermart/internal/accrual/accrualtest"
+	"practicum-gophermart/internal/accrual/mocks"
+	"practicum-gophermart/internal/config"
+	"practicum-gophermart/internal/model"
+)
+
+func TestWorker_backoff(t *testing.T) {
+	w := Worker{interval: time.Second * 5, maxBackoff: time.Minute}
+
+	tests := []struct {
+		name     string
+		attempts int
+		expected time.Duration
+	}{
+		{name: "first attempt", attempts: 1, expected: time.Second * 5},
+		{name: "second attempt", attempts: 2, expected: time.Second * 10},
+		{name: "third attempt", attempts: 3, expected: time.Second * 20},
+		{name: "limited by max backoff", attempts: 5, expected: time.Minute},
+		{name: "many attempts", attempts: 1000, expected: time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, w.backoff(tt.attempts))
+		})
+	}
+}
+
+func TestWorker_schedule(t *testing.T) {
+	w := Worker{interval: time.Second * 5, maxBackoff: time.Minute}
+	polledAt := time.Unix(100, 0)
+
+	order := model.Order{Number: "123", Attempts: 2}
+	w.schedule(&order, polledAt)
+
+	assert.Equal(t, 3, order.Attempts)
+	assert.Equal(t, polledAt, order.LastPolledAt)
+	assert.Equal(t, polledAt.Add(time.Second*20), order.NextPollAt)
+}
+
+func TestWorker_postpone(t *testing.T) {
+	w := Worker{interval: time.Second * 5, maxBackoff: time.Minute}
+	skippedAt := time.Unix(100, 0)
+	polledAt := time.Unix(50, 0)
+
+	order := model.Order{Number: "123", Attempts: 2, LastPolledAt: polledAt, PollResult: "ACCRUAL_UNAVAILABLE"}
+	w.postpone(&order, skippedAt)
+
+	assert.Equal(t, 2, order.Attempts)
+	assert.Equal(t, polledAt, order.LastPolledAt)
+	assert.Equal(t, "ACCRUAL_UNAVAILABLE", order.PollResult)
+	assert.Equal(t, skippedAt.Add(time.Second*5), order.NextPollAt)
+}
+
+func TestWorker_updateOrdersStatus(t *testing.T) {
+	const number = "12345678903"
+	uploadedAt := time.Now()
+
+	tests := []struct {
+		name             string
+		order            model.Order
+		script           []accrualtest.Response
+		expectedOrders   []model.Order
+		expectedResult   model.OrderPollResult
+		expectedRequests int
+		expectedFailures int64
+	}{
+		{
+			name:             "processed",
+			order:            model.Order{UserID: 1, Number: number, Status: model.OrderStatusNew.String(), UploadedAt: uploadedAt},
+			script:           []accrualtest.Response{accrualtest.OK("PROCESSED", 500)},
+			expectedOrders:   []model.Order{{UserID: 1, Number: number, Status: model.OrderStatusProcessed.String(), Accrual: 50000}},
+			expectedRequests: 1,
+		},
+		{
+			name:             "processing",
+			order:            model.Order{UserID: 1, Number: number, Status: model.OrderStatusNew.String(), UploadedAt: uploadedAt},
+			script:           []accrualtest.Response{accrualtest.OK("PROCESSING", 0)},
+			expectedOrders:   []model.Order{{UserID: 1, Number: number, Status: model.OrderStatusProcessing.String()}},
+			expectedRequests: 1,
+		},
+		{
+			name:             "invalid",
+			order:            model.Order{UserID: 1, Number: number, Status: model.OrderStatusProcessing.String(), UploadedAt: uploadedAt},
+			script:           []accrualtest.Response{accrualtest.OK("INVALID", 500)},
+			expectedOrders:   []model.Order{{UserID: 1, Number: number, Status: model.OrderStatusInvalid.String()}},
+			expectedRequests: 1,
+		},
+		{
+			name:             "status not changed",
+			order:            model.Order{UserID: 1, Number: number, Status: model.OrderStatusNew.String(), UploadedAt: uploadedAt, Attempts: 2},
+			script:           []accrualtest.Response{accrualtest.OK("REGISTERED", 0)},
+			expectedRequests: 1,
+		},
+		{
+			name:             "not registered",
+			order:            model.Order{UserID: 1, Number: number, Status: model.OrderStatusNew.String(), UploadedAt: uploadedAt},
+			script:           []accrualtest.Response{accrualtest.NoContent()},
+			expectedResult:   model.OrderPollResultNotRegistered,
+			expectedRequests: 1,
+		},
+		{
+			name:             "not registered for too long",
+			order:            model.Order{UserID: 1, Number: number, Status: model.OrderStatusNew.String(), UploadedAt: uploadedAt.Add(-time.Hour * 48)},
+			script:           []accrualtest.Response{accrualtest.NoContent()},
+			expectedOrders:   []model.Order{{UserID: 1, Number: number, Status: model.OrderStatusInvalid.String()}},
+			expectedResult:   model.OrderPollResultNotRegistered,
+			expectedRequests: 1,
+		},
+		{
+			name:  "rate limited",
+			order: model.Order{UserID: 1, Number: number, Status: model.OrderStatusNew.String(), UploadedAt: uploadedAt},
+			script: []accrualtest.Response{
+				accrualtest.TooManyRequests("1"),
+				accrualtest.OK("PROCESSED", 500),
+			},
+			expectedOrders:   []model.Order{{UserID: 1, Number: number, Status: model.OrderStatusProcessed.String(), Accrual: 50000}},
+			expectedRequests: 2,
+		},
+		{
+			name:             "accrual system failure",
+			order:            model.Order{UserID: 1, Number: number, Status: model.OrderStatusNew.String(), UploadedAt: uploadedAt},
+			script:           []accrualtest.Response{accrualtest.InternalServerError()},
+			expectedResult:   model.OrderPollResultAccrualUnavailable,
+			expectedRequests: 1,
+			expectedFailures: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := accrualtest.NewServer()
+			defer server.Close()
+			server.Script(number, tt.script...)
+
+			testConfig, err := config.New()
+			assert.NoError(t, err)
+
+			testApp := &mocks.Application{}
+			testApp.On("Config").Return(testConfig)
+
+			w, err := NewWithClient(testApp, NewRestyClient(server.GetOrderURL(), time.Second))
+			assert.NoError(t, err)
+			w.limiter = newRateLimiter(0)
+
+			testApp.On("ClaimOrdersToPoll", mock.Anything, w.owner, mock.Anything,
+				[]string{model.OrderStatusNew.String(), model.OrderStatusProcessing.String()}, mock.Anything,
+				testConfig.AccrualPollBatchSize()).
+				Return([]model.Order{tt.order}, nil).
+				Once()
+			if tt.expectedOrders != nil {
+				testApp.On("UpdateOrders", tt.expectedOrders).Return(nil).Once()
+			}
+			testApp.On("UpdateOrdersPollingState", mock.Anything, w.owner, mock.MatchedBy(func(orders []model.Order) bool {
+				return len(orders) == 1 && orders[0].Number == number && orders[0].Attempts == tt.order.Attempts+1 &&
+					orders[0].PollResult == tt.expectedResult.String()
+			})).
+				Return(nil).
+				Once()
+
+			assert.NoError(t, w.updateOrdersStatus(context.Background()))
+
+			testApp.AssertExpectations(t)
+			assert.Equal(t, tt.expectedRequests, server.Requests(number))
+			assert.Equal(t, tt.expectedFailures, w.failures.consecutive.Load())
+		})
+	}
+}
+
+func TestWorker_pollBatch_breakerIsOpen(t *testing.T) {
+	const number = "12345678903"
+
+	server := accrualtest.NewServer()
+	defer server.Close()
+	server.Script(number, accrualtest.OK("PROCESSED", 500))
+
+	testConfig, err := config.New()
+	assert.NoError(t, err)
+
+	testApp := &mocks.Application{}
+	testApp.On("Config").Return(testConfig)
+
+	w, err := NewWithClient(testApp, NewRestyClient(server.GetOrderURL(), time.Second))
+	assert.NoError(t, err)
+	w.limiter = newRateLimiter(0)
+	w.breaker = newBreaker(1, time.Hour)
+	w.breaker.failure()
+
+	order := model.Order{UserID: 1, Number: number, Status: model.OrderStatusNew.String(), Attempts: 2,
+		PollResult: model.OrderPollResultAccrualUnavailable.String()}
+	skippedAt := time.Now()
+	testApp.On("UpdateOrdersPollingState", mock.Anything, w.owner, mock.MatchedBy(func(orders []model.Order) bool {
+		return len(orders) == 1 && orders[0].Attempts == order.Attempts && orders[0].PollResult == order.PollResult &&
+			orders[0].LastPolledAt.IsZero() && orders[0].NextPollAt.After(skippedAt)
+	})).
+		Return(nil).
+		Once()
+
+	assert.NoError(t, w.pollBatch(context.Background(), []model.Order{order}))
+
+	testApp.AssertExpectations(t)
+	assert.Equal(t, 0, server.Requests(number))
+	assert.Equal(t, int64(0), w.failures.consecutive.Load())
+}
+
+func TestWorker_pollOrder_halfOpenBreaker(t *testing.T) {
+	const number = "12345678903"
+
+	tests := []struct {
+		name          string
+		response      accrualtest.Response
+		expectedState breakerState
+	}{
+		{
+			name:          "processed",
+			response:      accrualtest.OK("PROCESSED", 500),
+			expectedState: breakerClosed,
+		},
+		{
+			name:          "not registered",
+			response:      accrualtest.NoContent(),
+			expectedState: breakerClosed,
+		},
+		{
+			name:          "client error",
+			response:      accrualtest.Response{Code: http.StatusBadRequest},
+			expectedState: breakerOpen,
+		},
+		{
+			name:          "unknown status",
+			response:      accrualtest.OK("UNKNOWN", 0),
+			expectedState: breakerOpen,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := accrualtest.NewServer()
+			defer server.Close()
+			server.Script(number, tt.response)
+
+			testConfig, err := config.New()
+			assert.NoError(t, err)
+
+			testApp := &mocks.Application{}
+			testApp.On("Config").Return(testConfig)
+
+			w, err := NewWithClient(testApp, NewRestyClient(server.GetOrderURL(), time.Second))
+			assert.NoError(t, err)
+			w.limiter = newRateLimiter(0)
+			w.breaker = newBreaker(1, time.Millisecond)
+			w.breaker.failure()
+			time.Sleep(time.Millisecond * 5)
+
+			_, _, _, _ = w.pollOrder(context.Background(), model.Order{UserID: 1, Number: number,
+				Status: model.OrderStatusNew.String(), UploadedAt: time.Now()})
+
+			assert.Equal(t, tt.expectedState, w.breaker.currentState())
+			assert.Equal(t, 1, server.Requests(number))
+		})
+	}
+}
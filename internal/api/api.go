@@ -4,7 +4,7 @@ package api
 
 import (
 	"context"
-	"errors"
+	"expvar"
 	"net/http"
 	"os"
 	"os/signal"
@@ -12,24 +12,22 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/go-resty/resty/v2"
 	"github.com/rs/zerolog/log"
 	"golang.org/x/sync/errgroup"
 )
 
 const readHeaderTimeout = time.Second * 5
 
-var errInvalidIntervalUpdateOrderStatus = errors.New("invalid order status update interval")
-
 type API struct {
-	authMngr    *authMngr
-	app         Application
-	serv        *http.Server
-	accrualMngr *resty.Client
+	authMngr      *authMngr
+	app           Application
+	serv          *http.Server
+	accrualWorker AccrualWorker
+	expiryJob     ExpiryJob
 }
 
 // New returns new API.
-func New(application Application) (newAPI *API, err error) {
+func New(application Application, accrualWorker AccrualWorker, expiryJob ExpiryJob) (newAPI *API, err error) {
 	log.Debug().Msg("api.New started")
 	defer func() {
 		logMethodEnd("api.New", err)
@@ -37,19 +35,25 @@ func New(application Application) (newAPI *API, err error) {
 
 	newAPI = &API{}
 
-	newAPI.authMngr = newAuthMngr()
-
 	newAPI.app = application
 
 	config := application.Config()
 
+	jwtMngr, err := newJwtMngrFromConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	newAPI.authMngr = &authMngr{jwtMngr: jwtMngr}
+
 	newAPI.serv = &http.Server{
 		Addr:              config.ServAPIAddr(),
 		Handler:           newAPI.newRouter(),
 		ReadHeaderTimeout: readHeaderTimeout,
 	}
 
-	newAPI.accrualMngr = resty.New()
+	newAPI.accrualWorker = accrualWorker
+
+	newAPI.expiryJob = expiryJob
 
 	return newAPI, nil
 }
@@ -60,22 +64,44 @@ func (a *API) newRouter() *gin.Engine {
 
 	r := gin.Default()
 
+	r.GET("/health", a.healthHandler)
+	// expvar publishes the command line and the memory stats, so it is internal as the internal API
+	r.GET("/debug/vars", a.checkServiceTokenMiddleware, gin.WrapH(expvar.Handler()))
+
 	user := r.Group("/api/user")
 	{
 		auth := user.Group("/")
 		auth.POST("register", a.signUpHandler)
 		auth.POST("login", a.signInHandler)
+		auth.POST("token/refresh", a.refreshTokenHandler)
 
 		orders := user.Group("/").Use(a.checkAuthMiddleware)
-		orders.POST("orders", a.setOrderHandler)
+		orders.POST("orders", a.idempotencyMiddleware, a.setOrderHandler)
 		orders.GET("orders", a.ordersHandler)
 
 		balance := user.Group("/balance").Use(a.checkAuthMiddleware)
 		balance.GET("/", a.balanceHandler)
-		balance.POST("/withdraw", a.withdrawPointsHandler)
+		balance.POST("/withdraw", a.idempotencyMiddleware, a.withdrawPointsHandler)
+		balance.GET("/history", a.balanceHistoryHandler)
+		balance.POST("/holds", a.idempotencyMiddleware, a.holdPointsHandler)
+		balance.POST("/holds/:number/capture", a.idempotencyMiddleware, a.captureHoldHandler)
+		balance.POST("/holds/:number/release", a.idempotencyMiddleware, a.releaseHoldHandler)
 
 		withdraw := user.Group("/").Use(a.checkAuthMiddleware)
 		withdraw.GET("/withdrawals", a.withdrawnPointsHandler)
+
+		logout := user.Group("/").Use(a.checkAuthMiddleware)
+		logout.POST("logout", a.logoutHandler)
+		logout.POST("logout-all", a.logoutAllHandler)
+
+		sessions := user.Group("/sessions").Use(a.checkAuthMiddleware)
+		sessions.GET("", a.sessionsHandler)
+		sessions.DELETE("/:id", a.revokeSessionHandler)
+	}
+
+	internal := r.Group("/api/internal").Use(a.checkServiceTokenMiddleware)
+	{
+		internal.POST("/withdrawals/:number/cancel", a.cancelWithdrawalHandler)
 	}
 
 	return r
@@ -96,7 +122,11 @@ func (a *API) Run() {
 	})
 
 	errG.Go(func() error {
-		return a.startUpdatingOrdersStatus(ctx, shutdown)
+		return a.startAccrualWorker(ctx, shutdown)
+	})
+
+	errG.Go(func() error {
+		return a.startExpiryJob(ctx, shutdown)
 	})
 
 	if err := errG.Wait(); err != nil {
@@ -162,36 +192,50 @@ func (a *API) startListener(ctx context.Context, shutdown chan os.Signal) (err e
 
 }
 
-func (a *API) startUpdatingOrdersStatus(ctx context.Context, shutdown chan os.Signal) (err error) {
-	log.Debug().Msg("api.startUpdatingOrdersStatus started")
+func (a *API) startAccrualWorker(ctx context.Context, shutdown chan os.Signal) (err error) {
+	log.Debug().Msg("api.startAccrualWorker started")
 	defer func() {
-		logMethodEnd("api.startUpdatingOrdersStatus", err)
+		logMethodEnd("api.startAccrualWorker", err)
 	}()
 
-	interval := a.app.Config().OrderStatusUpdateInterval()
-	if interval == 0 {
-		return errInvalidIntervalUpdateOrderStatus
-	}
+	workerCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
-	for {
+	go func() {
 		select {
-		case <-ctx.Done():
-			return nil
-		case <-ticker.C:
-			err = a.updateOrdersStatus()
-			if err != nil {
-				return err
+		case <-workerCtx.Done():
+		case _, ok := <-shutdown:
+			if ok {
+				close(shutdown)
 			}
+			cancel()
+		}
+	}()
+
+	return a.accrualWorker.Run(workerCtx)
+}
+
+func (a *API) startExpiryJob(ctx context.Context, shutdown chan os.Signal) (err error) {
+	log.Debug().Msg("api.startExpiryJob started")
+	defer func() {
+		logMethodEnd("api.startExpiryJob", err)
+	}()
+
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		select {
+		case <-jobCtx.Done():
 		case _, ok := <-shutdown:
 			if ok {
 				close(shutdown)
 			}
-			return nil
+			cancel()
 		}
-	}
+	}()
 
+	return a.expiryJob.Run(jobCtx)
 }
 
 func logMethodEnd(method string, err error) {
@@ -3,17 +3,27 @@ package api
 import (
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 
 	"practicum-gophermart/internal/app"
 	"practicum-gophermart/internal/model"
 )
 
-var errUserAlreadyExists = errors.New("user already exists")
+var (
+	errUserAlreadyExists   = errors.New("user already exists")
+	errEmptyRefreshToken   = errors.New("empty refresh token")
+	errInvalidRefreshToken = errors.New("invalid refresh token")
+	errEmptySessionID      = errors.New("access token has no session")
+	errSessionIsRevoked    = errors.New("session is revoked")
+)
+
+const cookieRefreshToken = "refreshToken"
 
 func (a *API) signUpHandler(c *gin.Context) {
 	log.Debug().Msg("api.signUp START")
@@ -36,23 +46,22 @@ func (a *API) signUpHandler(c *gin.Context) {
 		return
 	}
 
-	accessToken, refreshToken, refreshExpiresIn, err := a.authMngr.newAccessAndRefreshTokens(id)
+	refreshToken, refreshExpiresIn := a.authMngr.newRefreshToken()
+	newRefreshSession := model.RefreshSession{UserID: id, Token: refreshToken, ExpiresIn: refreshExpiresIn,
+		UserAgent: c.Request.UserAgent(), IP: c.ClientIP()}
+	err = a.app.NewRefreshSession(c, &newRefreshSession)
 	if err != nil {
 		a.error(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	newRefreshSession := model.RefreshSession{UserID: id, Token: refreshToken, ExpiresIn: refreshExpiresIn}
-	err = a.app.NewRefreshSession(c, &newRefreshSession)
+	accessToken, err := a.authMngr.jwtMngr.newAccessToken(id, newRefreshSession.FamilyID)
 	if err != nil {
 		a.error(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.Header("Authorization", fmt.Sprintf("Bearer %s", accessToken))
-	c.SetCookie("refreshToken", newRefreshSession.Token, int(newRefreshSession.ExpiresIn.Unix()), "/api", "", true, true)
-
-	a.respond(c, http.StatusOK, map[string]string{"accessToken": accessToken, "refreshToken": newRefreshSession.Token})
+	a.respondWithTokens(c, accessToken, &newRefreshSession)
 }
 
 func (a *API) signInHandler(c *gin.Context) {
@@ -75,88 +84,160 @@ func (a *API) signInHandler(c *gin.Context) {
 		return
 	}
 
-	accessToken, refreshToken, refreshExpiresIn, err := a.authMngr.newAccessAndRefreshTokens(user.ID)
+	refreshToken, refreshExpiresIn := a.authMngr.newRefreshToken()
+	newRefreshSession := model.RefreshSession{UserID: user.ID, Token: refreshToken, ExpiresIn: refreshExpiresIn,
+		UserAgent: c.Request.UserAgent(), IP: c.ClientIP()}
+	err = a.app.NewRefreshSession(c, &newRefreshSession)
 	if err != nil {
 		a.error(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	newRefreshSession := model.RefreshSession{UserID: user.ID, Token: refreshToken, ExpiresIn: refreshExpiresIn}
-	err = a.app.NewRefreshSession(c, &newRefreshSession)
+	accessToken, err := a.authMngr.jwtMngr.newAccessToken(user.ID, newRefreshSession.FamilyID)
 	if err != nil {
 		a.error(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.Header("Authorization", fmt.Sprintf("Bearer %s", accessToken))
-	c.SetCookie("refreshToken", newRefreshSession.Token, int(newRefreshSession.ExpiresIn.Unix()), "/api", "", true, true)
-
-	a.respond(c, http.StatusOK, map[string]string{"accessToken": accessToken, "refreshToken": newRefreshSession.Token})
+	a.respondWithTokens(c, accessToken, &newRefreshSession)
 }
 
-func (a *API) checkAuthMiddleware(c *gin.Context) {
-	log.Debug().Msg("api.checkAuthMiddleware started")
-	defer log.Debug().Msg("api.checkAuthMiddleware ended")
+// refreshTokenHandler rotates the refresh session and returns the new pair of tokens.
+// The refresh token is taken from the cookie or from the body and can be used only once,
+// its reuse revokes all the sessions rotated from the same sign in.
+func (a *API) refreshTokenHandler(c *gin.Context) {
+	log.Debug().Msg("api.refreshTokenHandler START")
+	defer log.Debug().Msg("api.refreshTokenHandler END")
 
-	id, err := a.authMngr.getIDFromAuthHeader(c)
+	refreshToken, err := c.Cookie(cookieRefreshToken)
+	if err != nil {
+		reqTokens := struct {
+			RefreshToken string `json:"refreshToken"`
+		}{}
+		if err = c.ShouldBindJSON(&reqTokens); err != nil && !errors.Is(err, io.EOF) {
+			a.error(c, http.StatusBadRequest, err)
+			return
+		}
+		refreshToken = reqTokens.RefreshToken
+	}
 
-	if err == nil {
-		a.authMngr.setID(c, id)
+	if refreshToken == "" {
+		a.error(c, http.StatusUnauthorized, errEmptyRefreshToken)
+		return
+	}
+	if _, err = uuid.Parse(refreshToken); err != nil {
+		a.error(c, http.StatusUnauthorized, errInvalidRefreshToken)
 		return
 	}
 
-	accessIsAccessTokenIsExpired := errors.Is(err, errAccessTokenIsExpired)
-	if !accessIsAccessTokenIsExpired {
-		c.AbortWithStatusJSON(http.StatusUnauthorized, err.Error())
+	newRefreshToken, newRefreshExpiresIn := a.authMngr.newRefreshToken()
+	newRefreshSession := model.RefreshSession{Token: newRefreshToken, ExpiresIn: newRefreshExpiresIn,
+		UserAgent: c.Request.UserAgent(), IP: c.ClientIP()}
+	err = a.app.RotateRefreshSession(c, refreshToken, &newRefreshSession)
+	if err != nil {
+		if errors.Is(err, app.ErrRefreshSessionIsNotExist) || errors.Is(err, app.ErrRefreshTokenReused) {
+			a.error(c, http.StatusUnauthorized, err)
+		} else {
+			a.error(c, http.StatusInternalServerError, err)
+		}
 		return
 	}
 
-	refreshToken, errGettingRefreshToken := c.Cookie("refreshToken")
-	if errGettingRefreshToken != nil {
-		c.AbortWithStatusJSON(http.StatusUnauthorized, map[string]interface{}{
-			"access token error":          errAccessTokenIsExpired.Error(),
-			"getting refresh token error": errGettingRefreshToken.Error(),
-		})
+	accessToken, err := a.authMngr.jwtMngr.newAccessToken(newRefreshSession.UserID, newRefreshSession.FamilyID)
+	if err != nil {
+		a.error(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	refreshSession, errGettingRefreshSessionByToken := a.app.GetRefreshSessionByToken(c, refreshToken)
-	if errGettingRefreshSessionByToken != nil {
-		c.AbortWithStatusJSON(http.StatusUnauthorized, map[string]interface{}{
-			"access token error": errAccessTokenIsExpired.Error(),
-		})
-		log.Error().Err(errGettingRefreshSessionByToken).Msg("getting refresh session by token")
+	a.respondWithTokens(c, accessToken, &newRefreshSession)
+}
+
+// respondWithTokens sends the access token in the header and the body and the refresh token in the cookie and the body.
+func (a *API) respondWithTokens(c *gin.Context, accessToken string, refreshSession *model.RefreshSession) {
+	c.Header("Authorization", fmt.Sprintf("Bearer %s", accessToken))
+	c.SetCookie(cookieRefreshToken, refreshSession.Token, int(time.Until(refreshSession.ExpiresIn).Seconds()), "/api", "", true, true)
+
+	a.respond(c, http.StatusOK, map[string]string{"accessToken": accessToken, "refreshToken": refreshSession.Token})
+}
+
+// logoutHandler revokes the session of the access token and clears the refresh token cookie.
+func (a *API) logoutHandler(c *gin.Context) {
+	log.Debug().Msg("api.logoutHandler START")
+	defer log.Debug().Msg("api.logoutHandler END")
+
+	userID, err := a.authMngr.getID(c)
+	if err != nil {
+		a.error(c, http.StatusInternalServerError, err)
+		return
+	}
+	sessionID, err := a.authMngr.getSessionID(c)
+	if err != nil {
+		a.error(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	if refreshTokenIsExpired := refreshSession.ExpiresIn.Before(time.Now()); refreshTokenIsExpired {
-		c.AbortWithStatusJSON(http.StatusUnauthorized, map[string]interface{}{
-			"access token error":  errAccessTokenIsExpired.Error(),
-			"refresh token error": errRefreshTokenIsExpired.Error(),
-		})
+	err = a.app.RevokeSession(c, userID, sessionID)
+	if err != nil && !errors.Is(err, app.ErrSessionNotFound) {
+		a.error(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	newAccessToken, newRefreshToken, newRefreshExpiresIn, errCreatingNewTokens := a.authMngr.newAccessAndRefreshTokens(refreshSession.UserID)
-	if errCreatingNewTokens != nil {
-		c.AbortWithStatusJSON(http.StatusUnauthorized, map[string]interface{}{
-			"access token error": errAccessTokenIsExpired.Error(),
-		})
+	a.clearRefreshTokenCookie(c)
+	a.respond(c, http.StatusNoContent, nil)
+}
+
+// logoutAllHandler revokes all the sessions of the user and clears the refresh token cookie.
+func (a *API) logoutAllHandler(c *gin.Context) {
+	log.Debug().Msg("api.logoutAllHandler START")
+	defer log.Debug().Msg("api.logoutAllHandler END")
+
+	userID, err := a.authMngr.getID(c)
+	if err != nil {
+		a.error(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	newRefreshSession := model.RefreshSession{UserID: refreshSession.UserID, Token: newRefreshToken, ExpiresIn: newRefreshExpiresIn}
-	errSavingRefreshSession := a.app.NewRefreshSession(c, &newRefreshSession)
-	if errSavingRefreshSession != nil {
-		c.AbortWithStatusJSON(http.StatusUnauthorized, map[string]interface{}{
-			"access token error": errAccessTokenIsExpired.Error(),
-		})
-		log.Error().Err(errSavingRefreshSession).Msg("getting refresh session by token")
+	err = a.app.RevokeSessions(c, userID)
+	if err != nil {
+		a.error(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.Header("Authorization", fmt.Sprintf("Bearer %s", newAccessToken))
-	c.SetCookie("refreshToken", newRefreshToken, int(newRefreshExpiresIn.Unix()), "/api", "", true, true)
+	a.clearRefreshTokenCookie(c)
+	a.respond(c, http.StatusNoContent, nil)
+}
+
+func (a *API) clearRefreshTokenCookie(c *gin.Context) {
+	c.SetCookie(cookieRefreshToken, "", -1, "/api", "", true, true)
+}
+
+// checkAuthMiddleware authenticates the request by the access token, expired tokens are refreshed
+// by the client with refreshTokenHandler. The access token is bound to the session, so it stops working
+// as soon as the session is revoked.
+func (a *API) checkAuthMiddleware(c *gin.Context) {
+	log.Debug().Msg("api.checkAuthMiddleware started")
+	defer log.Debug().Msg("api.checkAuthMiddleware ended")
+
+	id, sessionID, err := a.authMngr.getIDFromAuthHeader(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, err.Error())
+		return
+	}
+	if sessionID == "" {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, errEmptySessionID.Error())
+		return
+	}
+
+	active, err := a.app.IsSessionActive(c, id, sessionID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !active {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, errSessionIsRevoked.Error())
+		return
+	}
 
 	a.authMngr.setID(c, id)
+	a.authMngr.setSessionID(c, sessionID)
 }
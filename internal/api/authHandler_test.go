@@ -10,6 +10,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 
 	"practicum-gophermart/internal/api/mocks"
 	"practicum-gophermart/internal/app"
@@ -88,7 +89,7 @@ func TestAPI_signUpHandler(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			testAPI := API{}
 			testAPI.app = tt.mockApp
-			testAPI.authMngr = newAuthMngr()
+			testAPI.authMngr = newTestAuthMngr(t)
 
 			rec := httptest.NewRecorder()
 
@@ -122,7 +123,10 @@ func TestAPI_signInHandler(t *testing.T) {
 				testApp.On("GetUser", mock.AnythingOfType("*gin.Context"), mock.AnythingOfType("string"), mock.AnythingOfType("string")).
 					Return(&model.User{Login: "validLogin"}, nil).
 					Once()
-				testApp.On("NewRefreshSession", mock.AnythingOfType("*gin.Context"), mock.AnythingOfType("*model.RefreshSession")).
+				testApp.On("NewRefreshSession", mock.AnythingOfType("*gin.Context"),
+					mock.MatchedBy(func(refreshSession *model.RefreshSession) bool {
+						return refreshSession.UserAgent == "test-agent" && refreshSession.IP == "192.0.2.1"
+					})).
 					Return(nil).
 					Once()
 				return &testApp
@@ -185,7 +189,7 @@ func TestAPI_signInHandler(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			testAPI := API{}
 			testAPI.app = tt.mockApp
-			testAPI.authMngr = newAuthMngr()
+			testAPI.authMngr = newTestAuthMngr(t)
 
 			rec := httptest.NewRecorder()
 
@@ -195,10 +199,368 @@ func TestAPI_signInHandler(t *testing.T) {
 			b := &bytes.Buffer{}
 			b.WriteString(tt.payload)
 			req := httptest.NewRequest(http.MethodPost, "/signInMockEndpoint", b)
+			req.Header.Set("User-Agent", "test-agent")
+
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+		})
+	}
+}
+
+func TestAPI_refreshTokenHandler(t *testing.T) {
+	const refreshToken = "0c9ab3c5-5f4e-4a0e-9d4b-6b6f3b0a8d21"
+
+	tests := []struct {
+		mockApp      *mocks.Application
+		name         string
+		cookie       string
+		payload      string
+		expectedCode int
+	}{
+		{
+			name:   "token in cookie",
+			cookie: refreshToken,
+			mockApp: func() *mocks.Application {
+				testApp := mocks.Application{}
+				testApp.On("RotateRefreshSession", mock.AnythingOfType("*gin.Context"), refreshToken, mock.AnythingOfType("*model.RefreshSession")).
+					Run(func(args mock.Arguments) {
+						args.Get(2).(*model.RefreshSession).UserID = 1
+					}).
+					Return(nil).
+					Once()
+				return &testApp
+			}(),
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:    "token in body",
+			payload: "{\"refreshToken\": \"" + refreshToken + "\"}",
+			mockApp: func() *mocks.Application {
+				testApp := mocks.Application{}
+				testApp.On("RotateRefreshSession", mock.AnythingOfType("*gin.Context"), refreshToken, mock.AnythingOfType("*model.RefreshSession")).
+					Return(nil).
+					Once()
+				return &testApp
+			}(),
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "without token",
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "invalid token",
+			cookie:       "invalid",
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "invalid body",
+			payload:      "{\"refreshToken\": ",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:   "session is not exist",
+			cookie: refreshToken,
+			mockApp: func() *mocks.Application {
+				testApp := mocks.Application{}
+				testApp.On("RotateRefreshSession", mock.AnythingOfType("*gin.Context"), refreshToken, mock.AnythingOfType("*model.RefreshSession")).
+					Return(app.ErrRefreshSessionIsNotExist).
+					Once()
+				return &testApp
+			}(),
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:   "rotated token is reused",
+			cookie: refreshToken,
+			mockApp: func() *mocks.Application {
+				testApp := mocks.Application{}
+				testApp.On("RotateRefreshSession", mock.AnythingOfType("*gin.Context"), refreshToken, mock.AnythingOfType("*model.RefreshSession")).
+					Return(app.ErrRefreshTokenReused).
+					Once()
+				return &testApp
+			}(),
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:   "unexpected err on rotating session",
+			cookie: refreshToken,
+			mockApp: func() *mocks.Application {
+				testApp := mocks.Application{}
+				testApp.On("RotateRefreshSession", mock.AnythingOfType("*gin.Context"), refreshToken, mock.AnythingOfType("*model.RefreshSession")).
+					Return(errors.New("unexpected error")).
+					Once()
+				return &testApp
+			}(),
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testAPI := API{}
+			testAPI.app = tt.mockApp
+			testAPI.authMngr = newTestAuthMngr(t)
+
+			rec := httptest.NewRecorder()
+
+			router := gin.New()
+			router.POST("/refreshMockEndpoint", testAPI.refreshTokenHandler)
+
+			b := &bytes.Buffer{}
+			b.WriteString(tt.payload)
+			req := httptest.NewRequest(http.MethodPost, "/refreshMockEndpoint", b)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: cookieRefreshToken, Value: tt.cookie})
+			}
+
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			if tt.expectedCode == http.StatusOK {
+				assert.NotEmpty(t, rec.Header().Get("Authorization"))
+				assert.NotEmpty(t, rec.Result().Cookies())
+			}
+			if tt.mockApp != nil {
+				tt.mockApp.AssertExpectations(t)
+			}
+		})
+	}
+}
+
+func TestAPI_checkAuthMiddleware(t *testing.T) {
+	const sessionID = "5b0e3f0c-2f8e-4b7a-9d0e-0f6a1c2b3d4e"
+
+	testAuthMngr := newTestAuthMngr(t)
+	accessToken, err := testAuthMngr.jwtMngr.newAccessToken(7, sessionID)
+	assert.NoError(t, err)
+
+	withoutSessionAccessToken, err := testAuthMngr.jwtMngr.newAccessToken(7, "")
+	assert.NoError(t, err)
+
+	expiredJwtMngr, err := newTestJwtMngr(t, map[string]string{"JWT_ACCESS_TOKEN_TTL": "-1m"})
+	require.NoError(t, err)
+	expiredAccessToken, err := expiredJwtMngr.newAccessToken(7, sessionID)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		mockApp      *mocks.Application
+		name         string
+		authHeader   string
+		expectedCode int
+	}{
+		{
+			name:       "OK",
+			authHeader: "Bearer " + accessToken,
+			mockApp: func() *mocks.Application {
+				testApp := mocks.Application{}
+				testApp.On("IsSessionActive", mock.AnythingOfType("*gin.Context"), int64(7), sessionID).
+					Return(true, nil).
+					Once()
+				return &testApp
+			}(),
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "without auth header",
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "expired access token",
+			authHeader:   "Bearer " + expiredAccessToken,
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "invalid auth header",
+			authHeader:   accessToken,
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "access token without session",
+			authHeader:   "Bearer " + withoutSessionAccessToken,
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:       "revoked session",
+			authHeader: "Bearer " + accessToken,
+			mockApp: func() *mocks.Application {
+				testApp := mocks.Application{}
+				testApp.On("IsSessionActive", mock.AnythingOfType("*gin.Context"), int64(7), sessionID).
+					Return(false, nil).
+					Once()
+				return &testApp
+			}(),
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:       "err on checking session",
+			authHeader: "Bearer " + accessToken,
+			mockApp: func() *mocks.Application {
+				testApp := mocks.Application{}
+				testApp.On("IsSessionActive", mock.AnythingOfType("*gin.Context"), int64(7), sessionID).
+					Return(false, errors.New("unexpected error")).
+					Once()
+				return &testApp
+			}(),
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testAPI := API{}
+			testAPI.authMngr = testAuthMngr
+			testAPI.app = tt.mockApp
+
+			rec := httptest.NewRecorder()
+
+			router := gin.New()
+			router.GET("/authMockEndpoint", testAPI.checkAuthMiddleware, func(c *gin.Context) {
+				id, errGettingID := testAPI.authMngr.getID(c)
+				assert.NoError(t, errGettingID)
+				assert.Equal(t, int64(7), id)
+				sid, errGettingSessionID := testAPI.authMngr.getSessionID(c)
+				assert.NoError(t, errGettingSessionID)
+				assert.Equal(t, sessionID, sid)
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/authMockEndpoint", nil)
+			if tt.authHeader != "" {
+				req.Header.Set("Authorization", tt.authHeader)
+			}
+
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			if tt.mockApp != nil {
+				tt.mockApp.AssertExpectations(t)
+			}
+		})
+	}
+}
+
+func TestAPI_logoutHandler(t *testing.T) {
+	const sessionID = "5b0e3f0c-2f8e-4b7a-9d0e-0f6a1c2b3d4e"
+
+	tests := []struct {
+		mockApp      *mocks.Application
+		name         string
+		expectedCode int
+	}{
+		{
+			name: "OK",
+			mockApp: func() *mocks.Application {
+				testApp := mocks.Application{}
+				testApp.On("RevokeSession", mock.AnythingOfType("*gin.Context"), int64(7), sessionID).
+					Return(nil).
+					Once()
+				return &testApp
+			}(),
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name: "session is already revoked",
+			mockApp: func() *mocks.Application {
+				testApp := mocks.Application{}
+				testApp.On("RevokeSession", mock.AnythingOfType("*gin.Context"), int64(7), sessionID).
+					Return(app.ErrSessionNotFound).
+					Once()
+				return &testApp
+			}(),
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name: "err on revoking session",
+			mockApp: func() *mocks.Application {
+				testApp := mocks.Application{}
+				testApp.On("RevokeSession", mock.AnythingOfType("*gin.Context"), int64(7), sessionID).
+					Return(errors.New("unexpected error")).
+					Once()
+				return &testApp
+			}(),
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testAPI := API{}
+			testAPI.app = tt.mockApp
+			testAPI.authMngr = newTestAuthMngr(t)
+
+			rec := httptest.NewRecorder()
+
+			router := gin.New()
+			router.POST("/logoutMockEndpoint", func(c *gin.Context) {
+				testAPI.authMngr.setID(c, 7)
+				testAPI.authMngr.setSessionID(c, sessionID)
+			}, testAPI.logoutHandler)
+
+			req := httptest.NewRequest(http.MethodPost, "/logoutMockEndpoint", nil)
+
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			if tt.expectedCode == http.StatusNoContent {
+				cookies := rec.Result().Cookies()
+				assert.Len(t, cookies, 1)
+				assert.Equal(t, cookieRefreshToken, cookies[0].Name)
+				assert.Equal(t, "", cookies[0].Value)
+				assert.Less(t, cookies[0].MaxAge, 0)
+			}
+			tt.mockApp.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAPI_logoutAllHandler(t *testing.T) {
+
+	tests := []struct {
+		mockApp      *mocks.Application
+		name         string
+		expectedCode int
+	}{
+		{
+			name: "OK",
+			mockApp: func() *mocks.Application {
+				testApp := mocks.Application{}
+				testApp.On("RevokeSessions", mock.AnythingOfType("*gin.Context"), int64(7)).
+					Return(nil).
+					Once()
+				return &testApp
+			}(),
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name: "err on revoking sessions",
+			mockApp: func() *mocks.Application {
+				testApp := mocks.Application{}
+				testApp.On("RevokeSessions", mock.AnythingOfType("*gin.Context"), int64(7)).
+					Return(errors.New("unexpected error")).
+					Once()
+				return &testApp
+			}(),
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testAPI := API{}
+			testAPI.app = tt.mockApp
+			testAPI.authMngr = newTestAuthMngr(t)
+
+			rec := httptest.NewRecorder()
+
+			router := gin.New()
+			router.POST("/logoutAllMockEndpoint", func(c *gin.Context) {
+				testAPI.authMngr.setID(c, 7)
+			}, testAPI.logoutAllHandler)
+
+			req := httptest.NewRequest(http.MethodPost, "/logoutAllMockEndpoint", nil)
 
 			router.ServeHTTP(rec, req)
 
 			assert.Equal(t, tt.expectedCode, rec.Code)
+			tt.mockApp.AssertExpectations(t)
 		})
 	}
 }
@@ -2,7 +2,6 @@ package api
 
 import (
 	"errors"
-	"fmt"
 	"strings"
 	"time"
 
@@ -13,6 +12,7 @@ import (
 var (
 	errUserIDNotFound       = errors.New("user id not found")
 	errUnexpectedUserIDType = errors.New("unexpected user id type")
+	errSessionIDNotFound    = errors.New("session id not found")
 )
 
 var (
@@ -24,28 +24,12 @@ type authMngr struct {
 	jwtMngr *jwtMngr
 }
 
-func newAuthMngr() *authMngr {
-	return &authMngr{jwtMngr: newJwtMngr("", time.Second*0, time.Second*0)}
+func (a *authMngr) newRefreshToken() (refreshToken string, refreshExpiresIn time.Time) {
+	return a.jwtMngr.newRefreshToken(), time.Now().Add(a.jwtMngr.refreshTokenTTL)
 }
 
-func (a *authMngr) newAccessAndRefreshTokens(id int64) (accessToken, refreshToken string, refreshExpiresIn time.Time, err error) {
-	log.Debug().Str("id", fmt.Sprint(id)).Msg("authMngr.newAccessAndRefreshTokens START")
-	defer func() {
-		logMethodEnd("authMngr.newAccessAndRefreshTokens", err)
-	}()
-
-	accessToken, err = a.jwtMngr.newAccessToken(id)
-	if err != nil {
-		return "", "", time.Time{}, err
-	}
-	refreshToken = a.jwtMngr.newRefreshToken()
-
-	refreshExpiresIn = time.Now().Add(a.jwtMngr.refreshTokenTTL)
-
-	return accessToken, refreshToken, refreshExpiresIn, nil
-}
-
-func (a *authMngr) getIDFromAuthHeader(c *gin.Context) (id int64, err error) {
+// getIDFromAuthHeader returns the user and the session of the access token from the auth header.
+func (a *authMngr) getIDFromAuthHeader(c *gin.Context) (id int64, sessionID string, err error) {
 	log.Debug().Msg("authMngr.getIDFromAuthHeader START")
 	defer func() {
 		logMethodEnd("authMngr.getIDFromAuthHeader", err)
@@ -53,21 +37,21 @@ func (a *authMngr) getIDFromAuthHeader(c *gin.Context) (id int64, err error) {
 
 	authHeader := c.GetHeader("Authorization")
 	if authHeader == "" {
-		return 0, errEmptyAuthHeader
+		return 0, "", errEmptyAuthHeader
 	}
 
 	headerParts := strings.Split(authHeader, " ")
 	if len(headerParts) != 2 || headerParts[0] != "Bearer" {
-		return 0, errInvalidAuthHeader
+		return 0, "", errInvalidAuthHeader
 	}
 
 	accessToken := headerParts[1]
-	id, err = a.jwtMngr.getID(accessToken)
+	id, sessionID, err = a.jwtMngr.getClaims(accessToken)
 	if err != nil {
-		return 0, err
+		return 0, "", err
 	}
 
-	return id, nil
+	return id, sessionID, nil
 }
 
 func (a *authMngr) getID(c *gin.Context) (userID int64, err error) {
@@ -93,3 +77,23 @@ func (a *authMngr) setID(c *gin.Context, id int64) {
 
 	c.Set("id", id)
 }
+
+func (a *authMngr) getSessionID(c *gin.Context) (sessionID string, err error) {
+	log.Debug().Msg("authMngr.getSessionID START")
+	defer func() {
+		logMethodEnd("authMngr.getSessionID", err)
+	}()
+
+	sessionID = c.GetString("sid")
+	if sessionID == "" {
+		return "", errSessionIDNotFound
+	}
+	return sessionID, nil
+}
+
+func (a *authMngr) setSessionID(c *gin.Context, sessionID string) {
+	log.Debug().Msg("authMngr.setSessionID START")
+	defer log.Debug().Msg("authMngr.setSessionID END")
+
+	c.Set("sid", sessionID)
+}
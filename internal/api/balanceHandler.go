@@ -3,7 +3,6 @@ package api
 import (
 	"errors"
 	"net/http"
-	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -23,17 +22,19 @@ func (a *API) balanceHandler(c *gin.Context) {
 		return
 	}
 
-	balance, withdrawn, err := a.app.GetBalance(c, userID)
+	balance, held, withdrawn, err := a.app.GetBalance(c, userID)
 	if err != nil {
 		a.error(c, http.StatusInternalServerError, err)
 		return
 	}
 
 	resp := struct {
-		Current   float64 `json:"current"`
-		Withdrawn float64 `json:"withdrawn"`
+		Current   model.Money `json:"current"`
+		Held      model.Money `json:"held"`
+		Withdrawn model.Money `json:"withdrawn"`
 	}{
 		Current:   balance,
+		Held:      held,
 		Withdrawn: withdrawn,
 	}
 
@@ -56,13 +57,9 @@ func (a *API) withdrawPointsHandler(c *gin.Context) {
 		return
 	}
 
-	orderNumber := reqWithdraw.Order
-	if numberForCheckValid, err := strconv.Atoi(orderNumber); err != nil {
+	if err = app.ValidateWithdraw(reqWithdraw); err != nil {
 		a.error(c, http.StatusUnprocessableEntity, err)
 		return
-	} else if !isValidNumber(int64(numberForCheckValid)) {
-		a.error(c, http.StatusUnprocessableEntity, errInvalidOrderNumber)
-		return
 	}
 
 	err = a.app.WithdrawFromBalance(c, userID, reqWithdraw)
@@ -88,7 +85,13 @@ func (a *API) withdrawnPointsHandler(c *gin.Context) {
 		return
 	}
 
-	withdrawals, err := a.app.GetWithdrawals(c, userID)
+	filter, err := parseListFilter(c, nil)
+	if err != nil {
+		a.error(c, http.StatusBadRequest, err)
+		return
+	}
+
+	withdrawals, next, err := a.app.GetWithdrawals(c, userID, filter)
 	if err != nil {
 		a.error(c, http.StatusInternalServerError, err)
 		return
@@ -98,5 +101,42 @@ func (a *API) withdrawnPointsHandler(c *gin.Context) {
 		return
 	}
 
+	setNextCursor(c, next)
+
 	a.respond(c, http.StatusOK, withdrawals)
 }
+
+func (a *API) balanceHistoryHandler(c *gin.Context) {
+	log.Debug().Msg("api.balanceHistoryHandler START")
+	defer log.Debug().Msg("api.balanceHistoryHandler END")
+
+	userID, err := a.authMngr.getID(c)
+	if err != nil {
+		a.error(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	filter, err := parseListFilter(c, nil)
+	if err != nil {
+		a.error(c, http.StatusBadRequest, err)
+		return
+	}
+	// the history grows with every posting, so it is paginated even if the limit is not requested
+	if filter.Limit == 0 {
+		filter.Limit = defaultPageLimit
+	}
+
+	history, next, err := a.app.GetBalanceHistory(c, userID, filter)
+	if err != nil {
+		a.error(c, http.StatusInternalServerError, err)
+		return
+	}
+	if len(history) == 0 {
+		a.respond(c, http.StatusNoContent, nil)
+		return
+	}
+
+	setNextCursor(c, next)
+
+	a.respond(c, http.StatusOK, history)
+}
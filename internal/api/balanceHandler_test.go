@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -28,7 +29,7 @@ func TestAPI_balanceHandler(t *testing.T) {
 			mockApp: func() *mocks.Application {
 				testApp := mocks.Application{}
 				testApp.On("GetBalance", mock.AnythingOfType("*gin.Context"), mock.AnythingOfType("int64")).
-					Return(1.11, 11.33, nil).
+					Return(model.Money(111), model.Money(50), model.Money(1133), nil).
 					Once()
 				return &testApp
 			}(),
@@ -45,7 +46,7 @@ func TestAPI_balanceHandler(t *testing.T) {
 			mockApp: func() *mocks.Application {
 				testApp := mocks.Application{}
 				testApp.On("GetBalance", mock.AnythingOfType("*gin.Context"), mock.AnythingOfType("int64")).
-					Return(-1.0, -1.0, errors.New("unexpected error")).
+					Return(model.Money(-1), model.Money(-1), model.Money(-1), errors.New("unexpected error")).
 					Once()
 				return &testApp
 			}(),
@@ -57,7 +58,7 @@ func TestAPI_balanceHandler(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			testAPI := API{}
 			testAPI.app = tt.mockApp
-			testAPI.authMngr = newAuthMngr()
+			testAPI.authMngr = newTestAuthMngr(t)
 
 			rec := httptest.NewRecorder()
 
@@ -100,15 +101,8 @@ func TestAPI_withdrawPointsHandler(t *testing.T) {
 			expectedCode: http.StatusUnauthorized,
 		},
 		{
-			name:    "bad payload",
-			payload: "{\"order\": \"12345678903\", \"sum\": \"\"\"\"\"\"\"\"\"}",
-			mockApp: func() *mocks.Application {
-				testApp := mocks.Application{}
-				testApp.On("WithdrawFromBalance", mock.AnythingOfType("*gin.Context"), mock.AnythingOfType("int64"), mock.AnythingOfType("model.Withdraw")).
-					Return(nil).
-					Once()
-				return &testApp
-			}(),
+			name:         "bad payload",
+			payload:      "{\"order\": \"12345678903\", \"sum\": \"\"\"\"\"\"\"\"\"}",
 			authorized:   true,
 			expectedCode: http.StatusBadRequest,
 		},
@@ -118,6 +112,52 @@ func TestAPI_withdrawPointsHandler(t *testing.T) {
 			authorized:   true,
 			expectedCode: http.StatusUnprocessableEntity,
 		},
+		{
+			name:         "order number with letters",
+			payload:      "{\"order\": \"1234567890a\", \"sum\": 755}",
+			authorized:   true,
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:         "empty order number",
+			payload:      "{\"order\": \"\", \"sum\": 755}",
+			authorized:   true,
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:         "zero sum",
+			payload:      "{\"order\": \"12345678903\", \"sum\": 0}",
+			authorized:   true,
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:         "negative sum",
+			payload:      "{\"order\": \"12345678903\", \"sum\": -751}",
+			authorized:   true,
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:         "sum rounded to zero",
+			payload:      "{\"order\": \"12345678903\", \"sum\": 0.001}",
+			authorized:   true,
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:    "order number longer than int64",
+			payload: "{\"order\": \"12345678901234567890121\", \"sum\": 0.01}",
+			mockApp: func() *mocks.Application {
+				testApp := mocks.Application{}
+				testApp.On("WithdrawFromBalance", mock.AnythingOfType("*gin.Context"), int64(1),
+					mock.MatchedBy(func(withdraw model.Withdraw) bool {
+						return withdraw.Order == "12345678901234567890121" && withdraw.Sum == 1
+					})).
+					Return(nil).
+					Once()
+				return &testApp
+			}(),
+			authorized:   true,
+			expectedCode: http.StatusOK,
+		},
 		{
 			name:    "err insufficient funds",
 			payload: "{\"order\": \"12345678903\", \"sum\": 751}",
@@ -149,7 +189,7 @@ func TestAPI_withdrawPointsHandler(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			testAPI := API{}
 			testAPI.app = tt.mockApp
-			testAPI.authMngr = newAuthMngr()
+			testAPI.authMngr = newTestAuthMngr(t)
 
 			rec := httptest.NewRecorder()
 
@@ -167,40 +207,72 @@ func TestAPI_withdrawPointsHandler(t *testing.T) {
 			testAPI.withdrawPointsHandler(testCtx)
 
 			assert.Equal(t, tt.expectedCode, rec.Code)
+			if tt.mockApp != nil {
+				tt.mockApp.AssertExpectations(t)
+			}
 		})
 	}
 }
 
 func TestAPI_withdrawnPointsHandler(t *testing.T) {
 	tests := []struct {
-		mockApp      *mocks.Application
-		name         string
-		expectedCode int
-		authorized   bool
+		mockApp            *mocks.Application
+		name               string
+		query              string
+		expectedNextCursor string
+		expectedCode       int
+		authorized         bool
 	}{
 		{
 			name: "OK",
 			mockApp: func() *mocks.Application {
 				testApp := mocks.Application{}
-				testApp.On("GetWithdrawals", mock.AnythingOfType("*gin.Context"), mock.AnythingOfType("int64")).
-					Return([]model.Withdraw{{Order: "123"}, {Order: "321"}}, nil).
+				testApp.On("GetWithdrawals", mock.AnythingOfType("*gin.Context"), int64(1), model.ListFilter{}).
+					Return([]model.Withdraw{{Order: "123"}, {Order: "321"}}, nil, nil).
 					Once()
 				return &testApp
 			}(),
 			authorized:   true,
 			expectedCode: http.StatusOK,
 		},
+		{
+			name:  "page with next page",
+			query: "?limit=2&from=2022-01-01T00:00:00Z",
+			mockApp: func() *mocks.Application {
+				testApp := mocks.Application{}
+				testApp.On("GetWithdrawals", mock.AnythingOfType("*gin.Context"), int64(1),
+					model.ListFilter{Limit: 2, From: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)}).
+					Return([]model.Withdraw{{Order: "123"}, {Order: "321"}}, &model.Cursor{Time: time.Unix(3, 0), ID: 2}, nil).
+					Once()
+				return &testApp
+			}(),
+			authorized:         true,
+			expectedNextCursor: model.Cursor{Time: time.Unix(3, 0), ID: 2}.String(),
+			expectedCode:       http.StatusOK,
+		},
 		{
 			name:         "unauthorized",
 			authorized:   false,
 			expectedCode: http.StatusUnauthorized,
 		},
+		{
+			name:         "withdrawals have no status",
+			query:        "?status=NEW",
+			authorized:   true,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "invalid to",
+			query:        "?to=yesterday",
+			authorized:   true,
+			expectedCode: http.StatusBadRequest,
+		},
 		{
 			name: "unexpected error",
 			mockApp: func() *mocks.Application {
 				testApp := mocks.Application{}
-				testApp.On("GetWithdrawals", mock.AnythingOfType("*gin.Context"), mock.AnythingOfType("int64")).
-					Return(nil, errors.New("unexpected error")).
+				testApp.On("GetWithdrawals", mock.AnythingOfType("*gin.Context"), int64(1), model.ListFilter{}).
+					Return(nil, nil, errors.New("unexpected error")).
 					Once()
 				return &testApp
 			}(),
@@ -211,8 +283,8 @@ func TestAPI_withdrawnPointsHandler(t *testing.T) {
 			name: "haven't withdrawals",
 			mockApp: func() *mocks.Application {
 				testApp := mocks.Application{}
-				testApp.On("GetWithdrawals", mock.AnythingOfType("*gin.Context"), mock.AnythingOfType("int64")).
-					Return([]model.Withdraw{}, nil).
+				testApp.On("GetWithdrawals", mock.AnythingOfType("*gin.Context"), int64(1), model.ListFilter{}).
+					Return([]model.Withdraw{}, nil, nil).
 					Once()
 				return &testApp
 			}(),
@@ -224,7 +296,7 @@ func TestAPI_withdrawnPointsHandler(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			testAPI := API{}
 			testAPI.app = tt.mockApp
-			testAPI.authMngr = newAuthMngr()
+			testAPI.authMngr = newTestAuthMngr(t)
 
 			rec := httptest.NewRecorder()
 
@@ -232,10 +304,128 @@ func TestAPI_withdrawnPointsHandler(t *testing.T) {
 			if tt.authorized {
 				testCtx.Set("id", int64(1))
 			}
+			testCtx.Request = httptest.NewRequest(http.MethodGet, "/api/user/withdrawals"+tt.query, nil)
 
 			testAPI.withdrawnPointsHandler(testCtx)
 
 			assert.Equal(t, tt.expectedCode, rec.Code)
+			assert.Equal(t, tt.expectedNextCursor, rec.Header().Get(headerNextCursor))
+			if tt.mockApp != nil {
+				tt.mockApp.AssertExpectations(t)
+			}
+		})
+	}
+}
+
+func TestAPI_balanceHistoryHandler(t *testing.T) {
+	tests := []struct {
+		mockApp            *mocks.Application
+		name               string
+		query              string
+		expectedNextCursor string
+		expectedCode       int
+		authorized         bool
+	}{
+		{
+			name:  "page with next page",
+			query: "?limit=2&cursor=" + model.Cursor{Time: time.Unix(1, 0), ID: 1}.String(),
+			mockApp: func() *mocks.Application {
+				testApp := mocks.Application{}
+				testApp.On("GetBalanceHistory", mock.AnythingOfType("*gin.Context"), int64(1),
+					model.ListFilter{Limit: 2, After: &model.Cursor{Time: time.Unix(1, 0).UTC(), ID: 1}}).
+					Return([]model.BalanceOperation{
+						{Type: "ACCRUAL", Order: "123", Amount: 50000, Balance: 50000},
+						{Type: "WITHDRAWAL", Order: "321", Amount: -12050, Balance: 37950},
+					}, &model.Cursor{Time: time.Unix(3, 0), ID: 3}, nil).
+					Once()
+				return &testApp
+			}(),
+			authorized:         true,
+			expectedNextCursor: model.Cursor{Time: time.Unix(3, 0), ID: 3}.String(),
+			expectedCode:       http.StatusOK,
+		},
+		{
+			name: "default page",
+			mockApp: func() *mocks.Application {
+				testApp := mocks.Application{}
+				testApp.On("GetBalanceHistory", mock.AnythingOfType("*gin.Context"), int64(1), model.ListFilter{Limit: defaultPageLimit}).
+					Return([]model.BalanceOperation{{Type: "ACCRUAL", Order: "123", Amount: 50000, Balance: 50000}}, nil, nil).
+					Once()
+				return &testApp
+			}(),
+			authorized:   true,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "unauthorized",
+			authorized:   false,
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "invalid limit",
+			query:        "?limit=1000",
+			authorized:   true,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "invalid cursor",
+			query:        "?cursor=abc",
+			authorized:   true,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "history has no status",
+			query:        "?status=NEW",
+			authorized:   true,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:  "unexpected error",
+			query: "?limit=2",
+			mockApp: func() *mocks.Application {
+				testApp := mocks.Application{}
+				testApp.On("GetBalanceHistory", mock.AnythingOfType("*gin.Context"), int64(1), model.ListFilter{Limit: 2}).
+					Return(nil, nil, errors.New("unexpected error")).
+					Once()
+				return &testApp
+			}(),
+			authorized:   true,
+			expectedCode: http.StatusInternalServerError,
+		},
+		{
+			name: "empty history",
+			mockApp: func() *mocks.Application {
+				testApp := mocks.Application{}
+				testApp.On("GetBalanceHistory", mock.AnythingOfType("*gin.Context"), int64(1), model.ListFilter{Limit: defaultPageLimit}).
+					Return([]model.BalanceOperation{}, nil, nil).
+					Once()
+				return &testApp
+			}(),
+			authorized:   true,
+			expectedCode: http.StatusNoContent,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testAPI := API{}
+			testAPI.app = tt.mockApp
+			testAPI.authMngr = newTestAuthMngr(t)
+
+			rec := httptest.NewRecorder()
+
+			testCtx, _ := gin.CreateTestContext(rec)
+			if tt.authorized {
+				testCtx.Set("id", int64(1))
+			}
+			testCtx.Request = httptest.NewRequest(http.MethodGet, "/api/user/balance/history"+tt.query, nil)
+
+			testAPI.balanceHistoryHandler(testCtx)
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			assert.Equal(t, tt.expectedNextCursor, rec.Header().Get(headerNextCursor))
+			if tt.mockApp != nil {
+				tt.mockApp.AssertExpectations(t)
+			}
 		})
 	}
 }
@@ -0,0 +1,27 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	healthStatusOK       = "ok"
+	healthStatusDegraded = "degraded"
+)
+
+func (a *API) healthHandler(c *gin.Context) {
+	log.Debug().Msg("api.healthHandler START")
+	defer log.Debug().Msg("api.healthHandler END")
+
+	accrualHealth := a.accrualWorker.Health()
+
+	status := healthStatusOK
+	if !accrualHealth.Healthy {
+		status = healthStatusDegraded
+	}
+
+	a.respond(c, http.StatusOK, gin.H{"status": status, "accrual": accrualHealth})
+}
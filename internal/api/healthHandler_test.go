@@ -0,0 +1,68 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"practicum-gophermart/internal/accrual"
+	"practicum-gophermart/internal/api/mocks"
+)
+
+func TestAPI_healthHandler(t *testing.T) {
+	tests := []struct {
+		mockAccrualWorker *mocks.AccrualWorker
+		name              string
+		expectedStatus    string
+		expectedCode      int
+	}{
+		{
+			name: "OK",
+			mockAccrualWorker: func() *mocks.AccrualWorker {
+				testAccrualWorker := mocks.AccrualWorker{}
+				testAccrualWorker.On("Health").
+					Return(accrual.Health{Healthy: true, Breaker: "closed"}).
+					Once()
+				return &testAccrualWorker
+			}(),
+			expectedStatus: healthStatusOK,
+			expectedCode:   http.StatusOK,
+		},
+		{
+			name: "accrual system is unavailable",
+			mockAccrualWorker: func() *mocks.AccrualWorker {
+				testAccrualWorker := mocks.AccrualWorker{}
+				testAccrualWorker.On("Health").
+					Return(accrual.Health{Healthy: false, Breaker: "open", ConsecutiveFailures: 10}).
+					Once()
+				return &testAccrualWorker
+			}(),
+			expectedStatus: healthStatusDegraded,
+			expectedCode:   http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testAPI := API{}
+			testAPI.accrualWorker = tt.mockAccrualWorker
+
+			rec := httptest.NewRecorder()
+
+			testCtx, _ := gin.CreateTestContext(rec)
+
+			testAPI.healthHandler(testCtx)
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+
+			resp := struct {
+				Status string `json:"status"`
+			}{}
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, tt.expectedStatus, resp.Status)
+		})
+	}
+}
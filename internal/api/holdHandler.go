@@ -0,0 +1,107 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	"practicum-gophermart/internal/app"
+	"practicum-gophermart/internal/model"
+)
+
+// holdPointsHandler holds the points of the user for the order until the payment is confirmed or cancelled.
+func (a *API) holdPointsHandler(c *gin.Context) {
+	log.Debug().Msg("api.holdPointsHandler START")
+	defer log.Debug().Msg("api.holdPointsHandler END")
+
+	userID, err := a.authMngr.getID(c)
+	if err != nil {
+		a.error(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	reqHold := model.Hold{}
+	if err = c.BindJSON(&reqHold); err != nil {
+		a.error(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if err = app.ValidateOrderNumber(reqHold.Order); err != nil {
+		a.error(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if err = app.ValidateWithdrawSum(reqHold.Sum); err != nil {
+		a.error(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	hold, err := a.app.HoldPoints(c, userID, reqHold.Order, reqHold.Sum)
+	if err != nil {
+		switch {
+		case errors.Is(err, app.ErrInsufficientFunds):
+			a.error(c, http.StatusPaymentRequired, err)
+		case errors.Is(err, app.ErrHoldAlreadyExists), errors.Is(err, app.ErrOrderWithdrawn):
+			a.error(c, http.StatusConflict, err)
+		default:
+			a.error(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	a.respond(c, http.StatusCreated, hold)
+}
+
+// captureHoldHandler withdraws the points held for the order of the confirmed payment.
+func (a *API) captureHoldHandler(c *gin.Context) {
+	log.Debug().Msg("api.captureHoldHandler START")
+	defer log.Debug().Msg("api.captureHoldHandler END")
+
+	userID, err := a.authMngr.getID(c)
+	if err != nil {
+		a.error(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	hold, err := a.app.CaptureHold(c, userID, c.Param("number"))
+	if err != nil {
+		a.holdError(c, err)
+		return
+	}
+
+	a.respond(c, http.StatusOK, hold)
+}
+
+// releaseHoldHandler returns the points held for the order of the cancelled payment to the available balance.
+func (a *API) releaseHoldHandler(c *gin.Context) {
+	log.Debug().Msg("api.releaseHoldHandler START")
+	defer log.Debug().Msg("api.releaseHoldHandler END")
+
+	userID, err := a.authMngr.getID(c)
+	if err != nil {
+		a.error(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	hold, err := a.app.ReleaseHold(c, userID, c.Param("number"))
+	if err != nil {
+		a.holdError(c, err)
+		return
+	}
+
+	a.respond(c, http.StatusOK, hold)
+}
+
+func (a *API) holdError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, app.ErrHoldNotFound):
+		a.error(c, http.StatusNotFound, err)
+	case errors.Is(err, app.ErrHoldNotActive), errors.Is(err, app.ErrOrderWithdrawn):
+		a.error(c, http.StatusConflict, err)
+	case errors.Is(err, app.ErrInsufficientFunds):
+		a.error(c, http.StatusPaymentRequired, err)
+	default:
+		a.error(c, http.StatusInternalServerError, err)
+	}
+}
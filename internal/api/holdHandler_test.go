@@ -0,0 +1,277 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"practicum-gophermart/internal/api/mocks"
+	"practicum-gophermart/internal/app"
+	"practicum-gophermart/internal/model"
+)
+
+func TestAPI_holdPointsHandler(t *testing.T) {
+	tests := []struct {
+		mockApp      *mocks.Application
+		name         string
+		payload      string
+		expectedBody string
+		expectedCode int
+		authorized   bool
+	}{
+		{
+			name:    "OK",
+			payload: "{\"order\": \"12345678903\", \"sum\": 751}",
+			mockApp: func() *mocks.Application {
+				testApp := mocks.Application{}
+				testApp.On("HoldPoints", mock.AnythingOfType("*gin.Context"), int64(1), "12345678903", model.Money(75100)).
+					Return(model.Hold{Order: "12345678903", Sum: 75100, Status: "HELD", CreatedAt: time.Unix(0, 0).UTC(),
+						ExpiresAt: time.Unix(900, 0).UTC()}, nil).
+					Once()
+				return &testApp
+			}(),
+			authorized: true,
+			expectedBody: `{"order": "12345678903", "sum": 751, "status": "HELD", "created_at": "1970-01-01T00:00:00Z",
+				"expires_at": "1970-01-01T00:15:00Z"}`,
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:         "unauthorized",
+			authorized:   false,
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "bad payload",
+			payload:      "{\"order\": \"12345678903\", \"sum\": \"\"\"}",
+			authorized:   true,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "invalid order number",
+			payload:      "{\"order\": \"1\", \"sum\": 751}",
+			authorized:   true,
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:         "zero sum",
+			payload:      "{\"order\": \"12345678903\", \"sum\": 0}",
+			authorized:   true,
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:    "err insufficient funds",
+			payload: "{\"order\": \"12345678903\", \"sum\": 751}",
+			mockApp: func() *mocks.Application {
+				testApp := mocks.Application{}
+				testApp.On("HoldPoints", mock.AnythingOfType("*gin.Context"), int64(1), "12345678903", model.Money(75100)).
+					Return(model.Hold{}, app.ErrInsufficientFunds).
+					Once()
+				return &testApp
+			}(),
+			authorized:   true,
+			expectedCode: http.StatusPaymentRequired,
+		},
+		{
+			name:    "hold already exists",
+			payload: "{\"order\": \"12345678903\", \"sum\": 751}",
+			mockApp: func() *mocks.Application {
+				testApp := mocks.Application{}
+				testApp.On("HoldPoints", mock.AnythingOfType("*gin.Context"), int64(1), "12345678903", model.Money(75100)).
+					Return(model.Hold{}, app.ErrHoldAlreadyExists).
+					Once()
+				return &testApp
+			}(),
+			authorized:   true,
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:    "points are already withdrawn for the order",
+			payload: "{\"order\": \"12345678903\", \"sum\": 751}",
+			mockApp: func() *mocks.Application {
+				testApp := mocks.Application{}
+				testApp.On("HoldPoints", mock.AnythingOfType("*gin.Context"), int64(1), "12345678903", model.Money(75100)).
+					Return(model.Hold{}, app.ErrOrderWithdrawn).
+					Once()
+				return &testApp
+			}(),
+			authorized:   true,
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:    "unexpected error",
+			payload: "{\"order\": \"12345678903\", \"sum\": 751}",
+			mockApp: func() *mocks.Application {
+				testApp := mocks.Application{}
+				testApp.On("HoldPoints", mock.AnythingOfType("*gin.Context"), int64(1), "12345678903", model.Money(75100)).
+					Return(model.Hold{}, errors.New("unexpected error")).
+					Once()
+				return &testApp
+			}(),
+			authorized:   true,
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testAPI := API{}
+			testAPI.app = tt.mockApp
+			testAPI.authMngr = newTestAuthMngr(t)
+
+			rec := httptest.NewRecorder()
+
+			testCtx, _ := gin.CreateTestContext(rec)
+			if tt.authorized {
+				testCtx.Set("id", int64(1))
+			}
+
+			b := &bytes.Buffer{}
+			b.WriteString(tt.payload)
+			testCtx.Request = httptest.NewRequest(http.MethodPost, "/holdPointsMockEndpoint", b)
+
+			testAPI.holdPointsHandler(testCtx)
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			if tt.expectedBody != "" {
+				assert.JSONEq(t, tt.expectedBody, rec.Body.String())
+			}
+			if tt.mockApp != nil {
+				tt.mockApp.AssertExpectations(t)
+			}
+		})
+	}
+}
+
+func TestAPI_closeHoldHandlers(t *testing.T) {
+	tests := []struct {
+		mockApp      *mocks.Application
+		name         string
+		action       string
+		expectedBody string
+		expectedCode int
+		authorized   bool
+	}{
+		{
+			name:   "capture OK",
+			action: "capture",
+			mockApp: func() *mocks.Application {
+				testApp := mocks.Application{}
+				testApp.On("CaptureHold", mock.AnythingOfType("*gin.Context"), int64(1), "12345678903").
+					Return(model.Hold{Order: "12345678903", Sum: 75100, Status: "CAPTURED", CreatedAt: time.Unix(0, 0).UTC(),
+						ExpiresAt: time.Unix(900, 0).UTC()}, nil).
+					Once()
+				return &testApp
+			}(),
+			authorized: true,
+			expectedBody: `{"order": "12345678903", "sum": 751, "status": "CAPTURED", "created_at": "1970-01-01T00:00:00Z",
+				"expires_at": "1970-01-01T00:15:00Z"}`,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "capture unauthorized",
+			action:       "capture",
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:   "capture hold not found",
+			action: "capture",
+			mockApp: func() *mocks.Application {
+				testApp := mocks.Application{}
+				testApp.On("CaptureHold", mock.AnythingOfType("*gin.Context"), int64(1), "12345678903").
+					Return(model.Hold{}, app.ErrHoldNotFound).
+					Once()
+				return &testApp
+			}(),
+			authorized:   true,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:   "capture held points expired",
+			action: "capture",
+			mockApp: func() *mocks.Application {
+				testApp := mocks.Application{}
+				testApp.On("CaptureHold", mock.AnythingOfType("*gin.Context"), int64(1), "12345678903").
+					Return(model.Hold{}, app.ErrInsufficientFunds).
+					Once()
+				return &testApp
+			}(),
+			authorized:   true,
+			expectedCode: http.StatusPaymentRequired,
+		},
+		{
+			name:   "release OK",
+			action: "release",
+			mockApp: func() *mocks.Application {
+				testApp := mocks.Application{}
+				testApp.On("ReleaseHold", mock.AnythingOfType("*gin.Context"), int64(1), "12345678903").
+					Return(model.Hold{Order: "12345678903", Sum: 75100, Status: "RELEASED"}, nil).
+					Once()
+				return &testApp
+			}(),
+			authorized:   true,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:   "release hold not active",
+			action: "release",
+			mockApp: func() *mocks.Application {
+				testApp := mocks.Application{}
+				testApp.On("ReleaseHold", mock.AnythingOfType("*gin.Context"), int64(1), "12345678903").
+					Return(model.Hold{}, app.ErrHoldNotActive).
+					Once()
+				return &testApp
+			}(),
+			authorized:   true,
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:   "release unexpected error",
+			action: "release",
+			mockApp: func() *mocks.Application {
+				testApp := mocks.Application{}
+				testApp.On("ReleaseHold", mock.AnythingOfType("*gin.Context"), int64(1), "12345678903").
+					Return(model.Hold{}, errors.New("unexpected error")).
+					Once()
+				return &testApp
+			}(),
+			authorized:   true,
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testAPI := API{}
+			testAPI.app = tt.mockApp
+			testAPI.authMngr = newTestAuthMngr(t)
+
+			setID := func(c *gin.Context) {
+				if tt.authorized {
+					c.Set("id", int64(1))
+				}
+			}
+
+			rec := httptest.NewRecorder()
+
+			router := gin.New()
+			router.POST("/holds/:number/capture", setID, testAPI.captureHoldHandler)
+			router.POST("/holds/:number/release", setID, testAPI.releaseHoldHandler)
+
+			req := httptest.NewRequest(http.MethodPost, "/holds/12345678903/"+tt.action, nil)
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			if tt.expectedBody != "" {
+				assert.JSONEq(t, tt.expectedBody, rec.Body.String())
+			}
+			if tt.mockApp != nil {
+				tt.mockApp.AssertExpectations(t)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	"practicum-gophermart/internal/app"
+)
+
+const (
+	headerIdempotencyKey      = "Idempotency-Key"
+	headerIdempotentReplayed  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	idempotentResponseContent = "application/json; charset=utf-8"
+)
+
+var errInvalidIdempotencyKey = errors.New("idempotency key must be at most 255 characters long")
+
+// idempotencyMiddleware applies the request with the Idempotency-Key header once per user and key.
+// Retries get the response to the first request, a request which differs from the first one gets 422.
+// A retry of the request in progress gets 409 until the request completes or its claim expires after a crash.
+// Requests without the header are handled as usual.
+func (a *API) idempotencyMiddleware(c *gin.Context) {
+	log.Debug().Msg("api.idempotencyMiddleware started")
+	defer log.Debug().Msg("api.idempotencyMiddleware ended")
+
+	key := c.GetHeader(headerIdempotencyKey)
+	if key == "" {
+		return
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		a.abort(c, http.StatusBadRequest, errInvalidIdempotencyKey)
+		return
+	}
+
+	userID, err := a.authMngr.getID(c)
+	if err != nil {
+		a.abort(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		a.abort(c, http.StatusBadRequest, err)
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	stored, err := a.app.ClaimIdempotencyKey(c, userID, key, requestHash(c.Request, body))
+	if err != nil {
+		switch {
+		case errors.Is(err, app.ErrIdempotencyKeyReused):
+			a.abort(c, http.StatusUnprocessableEntity, err)
+		case errors.Is(err, app.ErrIdempotencyKeyInProgress):
+			a.abort(c, http.StatusConflict, err)
+		default:
+			a.abort(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	if stored != nil {
+		c.Header(headerIdempotentReplayed, "true")
+		c.Data(stored.ResponseCode, idempotentResponseContent, stored.ResponseBody)
+		c.Abort()
+		return
+	}
+
+	recorder := &responseRecorder{ResponseWriter: c.Writer}
+	c.Writer = recorder
+
+	c.Next()
+
+	if err = a.app.CompleteIdempotentRequest(c, userID, key, recorder.Status(), recorder.body.Bytes()); err != nil {
+		log.Error().Err(err).Int64("user_id", userID).Msg("completing idempotent request")
+	}
+}
+
+// requestHash identifies the request by its method, path and body.
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder keeps a copy of the response body.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *responseRecorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
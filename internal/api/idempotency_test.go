@@ -0,0 +1,147 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"practicum-gophermart/internal/api/mocks"
+	"practicum-gophermart/internal/app"
+	"practicum-gophermart/internal/model"
+)
+
+func TestAPI_idempotencyMiddleware(t *testing.T) {
+	const payload = `{"order": "12345678903", "sum": 751}`
+	hash := requestHash(httptest.NewRequest(http.MethodPost, "/withdraw", nil), []byte(payload))
+
+	tests := []struct {
+		mockBehavior    func(testApp *mocks.Application)
+		name            string
+		key             string
+		expectedBody    string
+		expectedCode    int
+		expectedHandled bool
+		expectedReplay  bool
+	}{
+		{
+			name:            "without key",
+			expectedCode:    http.StatusOK,
+			expectedBody:    `{"handled":true}`,
+			expectedHandled: true,
+		},
+		{
+			name: "first request",
+			key:  "key",
+			mockBehavior: func(testApp *mocks.Application) {
+				testApp.On("ClaimIdempotencyKey", mock.AnythingOfType("*gin.Context"), int64(1), "key", hash).
+					Return(nil, nil).
+					Once()
+				testApp.On("CompleteIdempotentRequest", mock.AnythingOfType("*gin.Context"), int64(1), "key", http.StatusOK,
+					[]byte(`{"handled":true}`)).
+					Return(nil).
+					Once()
+			},
+			expectedCode:    http.StatusOK,
+			expectedBody:    `{"handled":true}`,
+			expectedHandled: true,
+		},
+		{
+			name: "retry replays the response",
+			key:  "key",
+			mockBehavior: func(testApp *mocks.Application) {
+				testApp.On("ClaimIdempotencyKey", mock.AnythingOfType("*gin.Context"), int64(1), "key", hash).
+					Return(&model.IdempotencyKey{UserID: 1, Key: "key", RequestHash: hash, ResponseCode: http.StatusPaymentRequired,
+						ResponseBody: []byte(`{"error":"insufficient funds"}`)}, nil).
+					Once()
+			},
+			expectedCode:   http.StatusPaymentRequired,
+			expectedBody:   `{"error":"insufficient funds"}`,
+			expectedReplay: true,
+		},
+		{
+			name: "key reused for another request",
+			key:  "key",
+			mockBehavior: func(testApp *mocks.Application) {
+				testApp.On("ClaimIdempotencyKey", mock.AnythingOfType("*gin.Context"), int64(1), "key", hash).
+					Return(nil, app.ErrIdempotencyKeyReused).
+					Once()
+			},
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "first request in progress",
+			key:  "key",
+			mockBehavior: func(testApp *mocks.Application) {
+				testApp.On("ClaimIdempotencyKey", mock.AnythingOfType("*gin.Context"), int64(1), "key", hash).
+					Return(nil, app.ErrIdempotencyKeyInProgress).
+					Once()
+			},
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:         "too long key",
+			key:          strings.Repeat("k", 256),
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "unexpected error",
+			key:  "key",
+			mockBehavior: func(testApp *mocks.Application) {
+				testApp.On("ClaimIdempotencyKey", mock.AnythingOfType("*gin.Context"), int64(1), "key", hash).
+					Return(nil, errors.New("unexpected error")).
+					Once()
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testApp := &mocks.Application{}
+			if tt.mockBehavior != nil {
+				tt.mockBehavior(testApp)
+			}
+
+			testAPI := API{}
+			testAPI.app = testApp
+			testAPI.authMngr = newTestAuthMngr(t)
+
+			handled := false
+			router := gin.New()
+			router.POST("/withdraw",
+				func(c *gin.Context) { c.Set("id", int64(1)) },
+				testAPI.idempotencyMiddleware,
+				func(c *gin.Context) {
+					body, err := c.GetRawData()
+					assert.NoError(t, err)
+					assert.Equal(t, payload, string(body))
+					handled = true
+					c.JSON(http.StatusOK, gin.H{"handled": true})
+				})
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/withdraw", bytes.NewBufferString(payload))
+			if tt.key != "" {
+				req.Header.Set(headerIdempotencyKey, tt.key)
+			}
+
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			assert.Equal(t, tt.expectedHandled, handled)
+			if tt.expectedBody != "" {
+				assert.JSONEq(t, tt.expectedBody, rec.Body.String())
+			}
+			if tt.expectedReplay {
+				assert.Equal(t, "true", rec.Header().Get(headerIdempotentReplayed))
+			}
+			testApp.AssertExpectations(t)
+		})
+	}
+}
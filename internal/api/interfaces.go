@@ -3,6 +3,7 @@ package api
 import (
 	"context"
 
+	"practicum-gophermart/internal/accrual"
 	"practicum-gophermart/internal/config"
 	"practicum-gophermart/internal/model"
 )
@@ -11,14 +12,32 @@ type Application interface {
 	CreateUser(c context.Context, user *model.User) (int64, error)
 	GetUser(c context.Context, login, pwd string) (*model.User, error)
 	NewRefreshSession(c context.Context, newRefreshSession *model.RefreshSession) error
-	GetRefreshSessionByToken(c context.Context, refreshToken string) (*model.RefreshSession, error)
+	RotateRefreshSession(c context.Context, refreshToken string, newRefreshSession *model.RefreshSession) error
+	GetSessions(c context.Context, userID int64) ([]model.Session, error)
+	RevokeSession(c context.Context, userID int64, sessionID string) error
+	RevokeSessions(c context.Context, userID int64) error
+	IsSessionActive(c context.Context, userID int64, sessionID string) (bool, error)
 	AddOrder(c context.Context, order *model.Order) error
-	GetOrdersByUser(c context.Context, userID int64) ([]model.Order, error)
-	GetOrdersByStatuses(statuses []string) ([]model.Order, error)
-	UpdateOrders(newOrderStatuses []model.Order) error
-	GetBalance(c context.Context, userID int64) (balance float64, withdrawn float64, err error)
+	GetOrdersByUser(c context.Context, userID int64, filter model.ListFilter) ([]model.Order, *model.Cursor, error)
+	GetBalance(c context.Context, userID int64) (balance, held, withdrawn model.Money, err error)
 	WithdrawFromBalance(c context.Context, userID int64, withdraw model.Withdraw) error
-	GetWithdrawals(c context.Context, userID int64) ([]model.Withdraw, error)
+	GetWithdrawals(c context.Context, userID int64, filter model.ListFilter) ([]model.Withdraw, *model.Cursor, error)
+	GetBalanceHistory(c context.Context, userID int64, filter model.ListFilter) ([]model.BalanceOperation, *model.Cursor, error)
+	CancelWithdrawal(c context.Context, orderNumber string) (model.Withdraw, error)
+	HoldPoints(c context.Context, userID int64, orderNumber string, sum model.Money) (model.Hold, error)
+	CaptureHold(c context.Context, userID int64, orderNumber string) (model.Hold, error)
+	ReleaseHold(c context.Context, userID int64, orderNumber string) (model.Hold, error)
+	ClaimIdempotencyKey(c context.Context, userID int64, key, requestHash string) (*model.IdempotencyKey, error)
+	CompleteIdempotentRequest(c context.Context, userID int64, key string, code int, body []byte) error
 	Config() *config.Config
 	CloseStorage() error
 }
+
+type AccrualWorker interface {
+	Run(ctx context.Context) error
+	Health() accrual.Health
+}
+
+type ExpiryJob interface {
+	Run(ctx context.Context) error
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	"practicum-gophermart/internal/app"
+)
+
+// headerServiceToken carries the token of the service calling the internal API.
+const headerServiceToken = "X-Service-Token"
+
+var (
+	errInternalAPIDisabled = errors.New("internal api is disabled")
+	errInvalidServiceToken = errors.New("invalid service token")
+)
+
+// checkServiceTokenMiddleware lets through the requests with the configured service token.
+// The internal API is forbidden when no token is configured.
+func (a *API) checkServiceTokenMiddleware(c *gin.Context) {
+	log.Debug().Msg("api.checkServiceTokenMiddleware started")
+	defer log.Debug().Msg("api.checkServiceTokenMiddleware ended")
+
+	serviceToken := a.app.Config().ServiceToken()
+	if serviceToken == "" {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": errInternalAPIDisabled.Error()})
+		return
+	}
+
+	if subtle.ConstantTimeCompare([]byte(c.GetHeader(headerServiceToken)), []byte(serviceToken)) != 1 {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": errInvalidServiceToken.Error()})
+		return
+	}
+}
+
+// cancelWithdrawalHandler cancels the withdrawal for the order of the cancelled shop order
+// and returns the points to the user.
+func (a *API) cancelWithdrawalHandler(c *gin.Context) {
+	log.Debug().Msg("api.cancelWithdrawalHandler START")
+	defer log.Debug().Msg("api.cancelWithdrawalHandler END")
+
+	withdraw, err := a.app.CancelWithdrawal(c, c.Param("number"))
+	if err != nil {
+		switch {
+		case errors.Is(err, app.ErrWithdrawalNotFound):
+			a.error(c, http.StatusNotFound, err)
+		case errors.Is(err, app.ErrWithdrawalAlreadyCancelled):
+			a.error(c, http.StatusConflict, err)
+		default:
+			a.error(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	a.respond(c, http.StatusOK, withdraw)
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"practicum-gophermart/internal/api/mocks"
+	"practicum-gophermart/internal/app"
+	"practicum-gophermart/internal/config"
+	"practicum-gophermart/internal/model"
+)
+
+func TestAPI_cancelWithdrawalHandler(t *testing.T) {
+	t.Setenv("SERVICE_TOKEN", "secret")
+	testConfig, err := config.New(config.WithEnv)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		mockBehavior func(testApp *mocks.Application)
+		name         string
+		serviceToken string
+		expectedBody string
+		expectedCode int
+	}{
+		{
+			name:         "OK",
+			serviceToken: "secret",
+			mockBehavior: func(testApp *mocks.Application) {
+				testApp.On("CancelWithdrawal", mock.AnythingOfType("*gin.Context"), "2377225624").
+					Return(model.Withdraw{Order: "2377225624", Sum: 50000, ProcessedAt: time.Unix(0, 0).UTC(), Status: "CANCELLED"}, nil).
+					Once()
+			},
+			expectedBody: `{"order": "2377225624", "sum": 500, "processed_at": "1970-01-01T00:00:00Z", "status": "CANCELLED"}`,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "without service token",
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "invalid service token",
+			serviceToken: "secret2",
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "withdrawal not found",
+			serviceToken: "secret",
+			mockBehavior: func(testApp *mocks.Application) {
+				testApp.On("CancelWithdrawal", mock.AnythingOfType("*gin.Context"), "2377225624").
+					Return(model.Withdraw{}, app.ErrWithdrawalNotFound).
+					Once()
+			},
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "withdrawal already cancelled",
+			serviceToken: "secret",
+			mockBehavior: func(testApp *mocks.Application) {
+				testApp.On("CancelWithdrawal", mock.AnythingOfType("*gin.Context"), "2377225624").
+					Return(model.Withdraw{}, app.ErrWithdrawalAlreadyCancelled).
+					Once()
+			},
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:         "unexpected error",
+			serviceToken: "secret",
+			mockBehavior: func(testApp *mocks.Application) {
+				testApp.On("CancelWithdrawal", mock.AnythingOfType("*gin.Context"), "2377225624").
+					Return(model.Withdraw{}, errors.New("unexpected error")).
+					Once()
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testApp := &mocks.Application{}
+			testApp.On("Config").Return(testConfig)
+			if tt.mockBehavior != nil {
+				tt.mockBehavior(testApp)
+			}
+
+			testAPI := API{}
+			testAPI.app = testApp
+
+			rec := httptest.NewRecorder()
+
+			router := gin.New()
+			router.POST("/withdrawals/:number/cancel", testAPI.checkServiceTokenMiddleware, testAPI.cancelWithdrawalHandler)
+
+			req := httptest.NewRequest(http.MethodPost, "/withdrawals/2377225624/cancel", nil)
+			if tt.serviceToken != "" {
+				req.Header.Set(headerServiceToken, tt.serviceToken)
+			}
+
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			if tt.expectedBody != "" {
+				assert.JSONEq(t, tt.expectedBody, rec.Body.String())
+			}
+			testApp.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAPI_checkServiceTokenMiddleware_disabled(t *testing.T) {
+	testConfig, err := config.New()
+	assert.NoError(t, err)
+
+	testApp := &mocks.Application{}
+	testApp.On("Config").Return(testConfig)
+
+	testAPI := API{}
+	testAPI.app = testApp
+
+	rec := httptest.NewRecorder()
+
+	router := gin.New()
+	router.POST("/withdrawals/:number/cancel", testAPI.checkServiceTokenMiddleware, testAPI.cancelWithdrawalHandler)
+
+	req := httptest.NewRequest(http.MethodPost, "/withdrawals/2377225624/cancel", nil)
+	req.Header.Set(headerServiceToken, "")
+
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	testApp.AssertExpectations(t)
+}
+
+func TestAPI_debugVars(t *testing.T) {
+	t.Setenv("SERVICE_TOKEN", "secret")
+	testConfig, err := config.New(config.WithEnv)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		serviceToken string
+		expectedCode int
+	}{
+		{
+			name:         "OK",
+			serviceToken: "secret",
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "without service token",
+			expectedCode: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testApp := &mocks.Application{}
+			testApp.On("Config").Return(testConfig)
+
+			testAPI := API{}
+			testAPI.app = testApp
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/debug/vars", nil)
+			if tt.serviceToken != "" {
+				req.Header.Set(headerServiceToken, tt.serviceToken)
+			}
+
+			testAPI.newRouter().ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			testApp.AssertExpectations(t)
+		})
+	}
+}
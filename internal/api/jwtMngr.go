@@ -1,64 +1,180 @@
 package api
 
 import (
+	"crypto/ed25519"
 	"errors"
 	"fmt"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
+
+	"practicum-gophermart/internal/config"
 )
 
+var errAccessTokenIsExpired = errors.New("access token is expired")
+
 var (
-	errAccessTokenIsExpired  = errors.New("access token is expired")
-	errRefreshTokenIsExpired = errors.New("refresh token is expired")
+	errUnsupportedJWTAlgorithm = errors.New("unsupported jwt algorithm")
+	errEmptyJWTPrivateKeyFile  = errors.New("empty jwt private key file")
+	errEmptyJWTKeyID           = errors.New("jwt key id is required with verification keys")
+	errUnknownJWTKeyID         = errors.New("unknown jwt key id")
+	errInvalidJWTIssuer        = errors.New("invalid jwt issuer")
+	errInvalidJWTAudience      = errors.New("invalid jwt audience")
 )
 
+// defaultSigningKey is the HS256 key of the development environment, it is used when the key is not configured.
+const defaultSigningKey = "6Q7TibVvx32RBzMU4j3I5hIKMY2A2azi"
+
+// jwtMngr signs the access tokens with the current key and verifies them with the current
+// or the previous keys which are found by the kid of the token.
 type jwtMngr struct {
-	signingKey      string
-	accessTokenTTL  time.Duration
-	refreshTokenTTL time.Duration
+	signingMethod    jwt.SigningMethod
+	signingKey       interface{}
+	keyID            string
+	verificationKeys map[string]interface{}
+	issuer           string
+	audience         string
+	accessTokenTTL   time.Duration
+	refreshTokenTTL  time.Duration
 }
 
-func newJwtMngr(signingKey string, accessTokenTTL, refreshTokenTTL time.Duration) *jwtMngr {
-	log.Debug().Msg("api.newJwtMngr START")
-	defer log.Debug().Msg("api.newJwtMngr END")
+// newJwtMngrFromConfig returns jwtMngr with the algorithm, the keys, the claims and the ttls of the config.
+func newJwtMngrFromConfig(cfg *config.Config) (newMngr *jwtMngr, err error) {
+	log.Debug().Msg("api.newJwtMngrFromConfig START")
+	defer func() {
+		logMethodEnd("api.newJwtMngrFromConfig", err)
+	}()
 
-	if signingKey == "" {
-		signingKey = "6Q7TibVvx32RBzMU4j3I5hIKMY2A2azi"
+	newMngr = &jwtMngr{
+		keyID:            cfg.JWTKeyID(),
+		verificationKeys: make(map[string]interface{}),
+		issuer:           cfg.JWTIssuer(),
+		audience:         cfg.JWTAudience(),
+		accessTokenTTL:   cfg.JWTAccessTokenTTL(),
+		refreshTokenTTL:  cfg.JWTRefreshTokenTTL(),
 	}
-	if accessTokenTTL == time.Second*0 {
-		accessTokenTTL = time.Minute * 30
+
+	if len(cfg.JWTVerificationKeys()) > 0 && newMngr.keyID == "" {
+		return nil, errEmptyJWTKeyID
 	}
-	if refreshTokenTTL == time.Second*0 {
-		refreshTokenTTL = time.Hour * 24 * 30
+
+	var verificationKey interface{}
+
+	switch cfg.JWTAlgorithm() {
+	case jwt.SigningMethodHS256.Alg():
+		newMngr.signingMethod = jwt.SigningMethodHS256
+		signingKey := cfg.JWTSigningKey()
+		if signingKey == "" {
+			log.Warn().Msg("jwt signing key is not configured, the default development key is used")
+			signingKey = defaultSigningKey
+		}
+		newMngr.signingKey = []byte(signingKey)
+		verificationKey = newMngr.signingKey
+	case jwt.SigningMethodRS256.Alg():
+		newMngr.signingMethod = jwt.SigningMethodRS256
+		pem, err := readKeyFile(cfg.JWTPrivateKeyFile())
+		if err != nil {
+			return nil, err
+		}
+		privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+		if err != nil {
+			return nil, fmt.Errorf("parsing jwt private key: %w", err)
+		}
+		newMngr.signingKey = privateKey
+		verificationKey = &privateKey.PublicKey
+	case jwt.SigningMethodEdDSA.Alg():
+		newMngr.signingMethod = jwt.SigningMethodEdDSA
+		pem, err := readKeyFile(cfg.JWTPrivateKeyFile())
+		if err != nil {
+			return nil, err
+		}
+		privateKey, err := jwt.ParseEdPrivateKeyFromPEM(pem)
+		if err != nil {
+			return nil, fmt.Errorf("parsing jwt private key: %w", err)
+		}
+		newMngr.signingKey = privateKey
+		verificationKey = privateKey.(ed25519.PrivateKey).Public()
+	default:
+		return nil, fmt.Errorf("%w: %s", errUnsupportedJWTAlgorithm, cfg.JWTAlgorithm())
+	}
+
+	for keyID, file := range cfg.JWTVerificationKeys() {
+		if newMngr.verificationKeys[keyID], err = newMngr.parseVerificationKey(file); err != nil {
+			return nil, fmt.Errorf("jwt verification key %s: %w", keyID, err)
+		}
 	}
-	return &jwtMngr{signingKey: signingKey, accessTokenTTL: accessTokenTTL, refreshTokenTTL: refreshTokenTTL}
+	newMngr.verificationKeys[newMngr.keyID] = verificationKey
+
+	return newMngr, nil
 }
 
+// parseVerificationKey reads the previous key of the algorithm: the secret of HS256
+// or the PEM public key of RS256 and EdDSA.
+func (j *jwtMngr) parseVerificationKey(file string) (interface{}, error) {
+	content, err := readKeyFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	switch j.signingMethod {
+	case jwt.SigningMethodRS256:
+		return jwt.ParseRSAPublicKeyFromPEM(content)
+	case jwt.SigningMethodEdDSA:
+		return jwt.ParseEdPublicKeyFromPEM(content)
+	default:
+		return []byte(strings.TrimSpace(string(content))), nil
+	}
+}
+
+func readKeyFile(file string) ([]byte, error) {
+	if file == "" {
+		return nil, errEmptyJWTPrivateKeyFile
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading jwt key file: %w", err)
+	}
+	return content, nil
+}
+
+// tokenClaims are the claims of the access token, the session is the sign in of the user on the device.
 type tokenClaims struct {
 	jwt.RegisteredClaims
-	ID int64 `json:"id"`
+	SessionID string `json:"sid"`
+	ID        int64  `json:"id"`
 }
 
-func (j *jwtMngr) newAccessToken(id int64) (accessToken string, err error) {
+func (j *jwtMngr) newAccessToken(id int64, sessionID string) (accessToken string, err error) {
 	log.Debug().Msg("jwtMngr.newAccessToken START")
 	defer func() {
 		logMethodEnd("jwtMngr.newAccessToken", err)
 	}()
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256,
+	registeredClaims := jwt.RegisteredClaims{
+		Issuer:    j.issuer,
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.accessTokenTTL)),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+	}
+	if j.audience != "" {
+		registeredClaims.Audience = jwt.ClaimStrings{j.audience}
+	}
+
+	token := jwt.NewWithClaims(j.signingMethod,
 		tokenClaims{
-			RegisteredClaims: jwt.RegisteredClaims{
-				ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.accessTokenTTL)),
-				IssuedAt:  jwt.NewNumericDate(time.Now()),
-			},
-			ID: id,
+			RegisteredClaims: registeredClaims,
+			SessionID:        sessionID,
+			ID:               id,
 		},
 	)
+	if j.keyID != "" {
+		token.Header["kid"] = j.keyID
+	}
 
-	accessToken, err = token.SignedString([]byte(j.signingKey))
+	accessToken, err = token.SignedString(j.signingKey)
 	if err != nil {
 		return "", err
 	}
@@ -73,29 +189,44 @@ func (j *jwtMngr) newRefreshToken() string {
 	return uuid.New().String()
 }
 
-func (j *jwtMngr) getID(accessToken string) (userID int64, err error) {
-	log.Debug().Msg("jwtMngr.getID START")
+// getClaims returns the user and the session of the access token.
+// The token is verified by the key of its kid, the tokens without kid are verified by the current key.
+func (j *jwtMngr) getClaims(accessToken string) (userID int64, sessionID string, err error) {
+	log.Debug().Msg("jwtMngr.getClaims START")
 	defer func() {
-		logMethodEnd("jwtMngr.getID", err)
+		logMethodEnd("jwtMngr.getClaims", err)
 	}()
 
-	token, err := jwt.Parse(accessToken, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(j.signingKey), nil
-	})
+	claims := &tokenClaims{}
+	_, err = jwt.ParseWithClaims(accessToken, claims, j.verificationKey,
+		jwt.WithValidMethods([]string{j.signingMethod.Alg()}))
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenExpired) {
-			return 0, errAccessTokenIsExpired
+			return 0, "", errAccessTokenIsExpired
 		}
-		return 0, err
+		return 0, "", err
+	}
+
+	if j.issuer != "" && !claims.VerifyIssuer(j.issuer, true) {
+		return 0, "", errInvalidJWTIssuer
+	}
+	if j.audience != "" && !claims.VerifyAudience(j.audience, true) {
+		return 0, "", errInvalidJWTAudience
+	}
+
+	return claims.ID, claims.SessionID, nil
+}
+
+func (j *jwtMngr) verificationKey(token *jwt.Token) (interface{}, error) {
+	keyID, ok := token.Header["kid"].(string)
+	if !ok {
+		keyID = j.keyID
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
+	key, ok := j.verificationKeys[keyID]
 	if !ok {
-		return 0, fmt.Errorf("error get user claims from token")
+		return nil, fmt.Errorf("%w: %s", errUnknownJWTKeyID, keyID)
 	}
 
-	return int64(claims["id"].(float64)), nil
+	return key, nil
 }
@@ -0,0 +1,223 @@
+package api
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"practicum-gophermart/internal/config"
+)
+
+// writeKeyFiles writes the PEM private and public keys of the algorithm to the dir.
+func writeKeyFiles(t *testing.T, dir, name, algorithm string) (privateKeyFile, publicKeyFile string) {
+	t.Helper()
+
+	var (
+		privateKey interface{}
+		publicKey  interface{}
+	)
+	switch algorithm {
+	case "RS256":
+		rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		privateKey, publicKey = rsaKey, &rsaKey.PublicKey
+	case "EdDSA":
+		edPublicKey, edPrivateKey, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		privateKey, publicKey = edPrivateKey, edPublicKey
+	}
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	require.NoError(t, err)
+	publicDER, err := x509.MarshalPKIXPublicKey(publicKey)
+	require.NoError(t, err)
+
+	privateKeyFile = filepath.Join(dir, name+".pem")
+	publicKeyFile = filepath.Join(dir, name+".pub.pem")
+	require.NoError(t, os.WriteFile(privateKeyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}), 0o600))
+	require.NoError(t, os.WriteFile(publicKeyFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}), 0o600))
+
+	return privateKeyFile, publicKeyFile
+}
+
+func newTestJwtMngr(t *testing.T, env map[string]string) (*jwtMngr, error) {
+	t.Helper()
+
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+	testConfig, err := config.New(config.WithEnv, config.WithFile)
+	require.NoError(t, err)
+
+	return newJwtMngrFromConfig(testConfig)
+}
+
+// newTestAuthMngr returns authMngr with the jwtMngr of the test config.
+func newTestAuthMngr(t *testing.T) *authMngr {
+	t.Helper()
+
+	testJwtMngr, err := newTestJwtMngr(t, nil)
+	require.NoError(t, err)
+
+	return &authMngr{jwtMngr: testJwtMngr}
+}
+
+func TestJwtMngr_algorithms(t *testing.T) {
+	dir := t.TempDir()
+	rsaKeyFile, _ := writeKeyFiles(t, dir, "rsa", "RS256")
+	edKeyFile, _ := writeKeyFiles(t, dir, "ed", "EdDSA")
+
+	tests := []struct {
+		env  map[string]string
+		name string
+	}{
+		{
+			name: "HS256 default key",
+			env:  map[string]string{},
+		},
+		{
+			name: "HS256",
+			env:  map[string]string{"JWT_SIGNING_KEY": "secret"},
+		},
+		{
+			name: "RS256",
+			env:  map[string]string{"JWT_ALGORITHM": "RS256", "JWT_PRIVATE_KEY_FILE": rsaKeyFile},
+		},
+		{
+			name: "EdDSA",
+			env:  map[string]string{"JWT_ALGORITHM": "EdDSA", "JWT_PRIVATE_KEY_FILE": edKeyFile},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testJwtMngr, err := newTestJwtMngr(t, tt.env)
+			require.NoError(t, err)
+
+			accessToken, err := testJwtMngr.newAccessToken(7, "session")
+			require.NoError(t, err)
+
+			userID, sessionID, err := testJwtMngr.getClaims(accessToken)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(7), userID)
+			assert.Equal(t, "session", sessionID)
+		})
+	}
+}
+
+func TestNewJwtMngrFromConfig(t *testing.T) {
+	dir := t.TempDir()
+	rsaKeyFile, _ := writeKeyFiles(t, dir, "rsa", "RS256")
+
+	tests := []struct {
+		env  map[string]string
+		name string
+		err  error
+	}{
+		{
+			name: "unsupported algorithm",
+			env:  map[string]string{"JWT_ALGORITHM": "none"},
+			err:  errUnsupportedJWTAlgorithm,
+		},
+		{
+			name: "without private key file",
+			env:  map[string]string{"JWT_ALGORITHM": "EdDSA"},
+			err:  errEmptyJWTPrivateKeyFile,
+		},
+		{
+			name: "verification keys without key id",
+			env:  map[string]string{"JWT_ALGORITHM": "RS256", "JWT_PRIVATE_KEY_FILE": rsaKeyFile, "JWT_VERIFICATION_KEYS": "1:" + rsaKeyFile},
+			err:  errEmptyJWTKeyID,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newTestJwtMngr(t, tt.env)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestJwtMngr_keyRotation(t *testing.T) {
+	dir := t.TempDir()
+	oldKeyFile, oldPublicKeyFile := writeKeyFiles(t, dir, "old", "EdDSA")
+	newKeyFile, _ := writeKeyFiles(t, dir, "new", "EdDSA")
+	otherKeyFile, _ := writeKeyFiles(t, dir, "other", "EdDSA")
+
+	oldJwtMngr, err := newTestJwtMngr(t, map[string]string{
+		"JWT_ALGORITHM": "EdDSA", "JWT_PRIVATE_KEY_FILE": oldKeyFile, "JWT_KEY_ID": "1",
+	})
+	require.NoError(t, err)
+	oldAccessToken, err := oldJwtMngr.newAccessToken(7, "session")
+	require.NoError(t, err)
+
+	otherJwtMngr, err := newTestJwtMngr(t, map[string]string{
+		"JWT_ALGORITHM": "EdDSA", "JWT_PRIVATE_KEY_FILE": otherKeyFile, "JWT_KEY_ID": "3",
+	})
+	require.NoError(t, err)
+	otherAccessToken, err := otherJwtMngr.newAccessToken(7, "session")
+	require.NoError(t, err)
+
+	// the rotated config comes from the file, the same as it is deployed.
+	configFile := filepath.Join(dir, "gophermart.env")
+	require.NoError(t, os.WriteFile(configFile, []byte(
+		"# rotated jwt key\n"+
+			"JWT_ALGORITHM=EdDSA\n"+
+			"JWT_PRIVATE_KEY_FILE="+newKeyFile+"\n"+
+			"JWT_KEY_ID=2\n"+
+			"JWT_VERIFICATION_KEYS=1:"+oldPublicKeyFile+"\n"), 0o600))
+	rotatedJwtMngr, err := newTestJwtMngr(t, map[string]string{
+		"CONFIG": configFile, "JWT_ALGORITHM": "", "JWT_PRIVATE_KEY_FILE": "", "JWT_KEY_ID": "",
+	})
+	require.NoError(t, err)
+	newAccessToken, err := rotatedJwtMngr.newAccessToken(7, "session")
+	require.NoError(t, err)
+
+	t.Run("token of the new key", func(t *testing.T) {
+		userID, _, err := rotatedJwtMngr.getClaims(newAccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(7), userID)
+	})
+	t.Run("token of the previous key", func(t *testing.T) {
+		userID, _, err := rotatedJwtMngr.getClaims(oldAccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(7), userID)
+	})
+	t.Run("token of the unknown key", func(t *testing.T) {
+		_, _, err := rotatedJwtMngr.getClaims(otherAccessToken)
+		assert.ErrorIs(t, err, errUnknownJWTKeyID)
+	})
+	t.Run("token of the new key is not accepted by the previous config", func(t *testing.T) {
+		_, _, err := oldJwtMngr.getClaims(newAccessToken)
+		assert.ErrorIs(t, err, errUnknownJWTKeyID)
+	})
+}
+
+func TestJwtMngr_issuerAndAudience(t *testing.T) {
+	testJwtMngr, err := newTestJwtMngr(t, map[string]string{
+		"JWT_SIGNING_KEY": "secret", "JWT_ISSUER": "gophermart", "JWT_AUDIENCE": "gophermart-api",
+	})
+	require.NoError(t, err)
+	accessToken, err := testJwtMngr.newAccessToken(7, "session")
+	require.NoError(t, err)
+
+	_, _, err = testJwtMngr.getClaims(accessToken)
+	assert.NoError(t, err)
+
+	otherIssuerJwtMngr, err := newTestJwtMngr(t, map[string]string{"JWT_ISSUER": "other"})
+	require.NoError(t, err)
+	_, _, err = otherIssuerJwtMngr.getClaims(accessToken)
+	assert.ErrorIs(t, err, errInvalidJWTIssuer)
+
+	otherAudienceJwtMngr, err := newTestJwtMngr(t, map[string]string{"JWT_ISSUER": "gophermart", "JWT_AUDIENCE": "other"})
+	require.NoError(t, err)
+	_, _, err = otherAudienceJwtMngr.getClaims(accessToken)
+	assert.ErrorIs(t, err, errInvalidJWTAudience)
+}
@@ -0,0 +1,59 @@
+// Code generated by mockery v2.14.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	accrual "practicum-gophermart/internal/accrual"
+
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// AccrualWorker is an autogenerated mock type for the AccrualWorker type
+type AccrualWorker struct {
+	mock.Mock
+}
+
+// Health provides a mock function with given fields:
+func (_m *AccrualWorker) Health() accrual.Health {
+	ret := _m.Called()
+
+	var r0 accrual.Health
+	if rf, ok := ret.Get(0).(func() accrual.Health); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(accrual.Health)
+	}
+
+	return r0
+}
+
+// Run provides a mock function with given fields: ctx
+func (_m *AccrualWorker) Run(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+type mockConstructorTestingTNewAccrualWorker interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+// NewAccrualWorker creates a new instance of AccrualWorker. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+func NewAccrualWorker(t mockConstructorTestingTNewAccrualWorker) *AccrualWorker {
+	mock := &AccrualWorker{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -5,10 +5,9 @@ package mocks
 import (
 	context "context"
 	config "practicum-gophermart/internal/config"
+	model "practicum-gophermart/internal/model"
 
 	mock "github.com/stretchr/testify/mock"
-
-	model "practicum-gophermart/internal/model"
 )
 
 // Application is an autogenerated mock type for the Application type
@@ -30,6 +29,71 @@ func (_m *Application) AddOrder(c context.Context, order *model.Order) error {
 	return r0
 }
 
+// CancelWithdrawal provides a mock function with given fields: c, orderNumber
+func (_m *Application) CancelWithdrawal(c context.Context, orderNumber string) (model.Withdraw, error) {
+	ret := _m.Called(c, orderNumber)
+
+	var r0 model.Withdraw
+	if rf, ok := ret.Get(0).(func(context.Context, string) model.Withdraw); ok {
+		r0 = rf(c, orderNumber)
+	} else {
+		r0 = ret.Get(0).(model.Withdraw)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(c, orderNumber)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CaptureHold provides a mock function with given fields: c, userID, orderNumber
+func (_m *Application) CaptureHold(c context.Context, userID int64, orderNumber string) (model.Hold, error) {
+	ret := _m.Called(c, userID, orderNumber)
+
+	var r0 model.Hold
+	if rf, ok := ret.Get(0).(func(context.Context, int64, string) model.Hold); ok {
+		r0 = rf(c, userID, orderNumber)
+	} else {
+		r0 = ret.Get(0).(model.Hold)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int64, string) error); ok {
+		r1 = rf(c, userID, orderNumber)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ClaimIdempotencyKey provides a mock function with given fields: c, userID, key, requestHash
+func (_m *Application) ClaimIdempotencyKey(c context.Context, userID int64, key string, requestHash string) (*model.IdempotencyKey, error) {
+	ret := _m.Called(c, userID, key, requestHash)
+
+	var r0 *model.IdempotencyKey
+	if rf, ok := ret.Get(0).(func(context.Context, int64, string, string) *model.IdempotencyKey); ok {
+		r0 = rf(c, userID, key, requestHash)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.IdempotencyKey)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int64, string, string) error); ok {
+		r1 = rf(c, userID, key, requestHash)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // CloseStorage provides a mock function with given fields:
 func (_m *Application) CloseStorage() error {
 	ret := _m.Called()
@@ -44,6 +108,20 @@ func (_m *Application) CloseStorage() error {
 	return r0
 }
 
+// CompleteIdempotentRequest provides a mock function with given fields: c, userID, key, code, body
+func (_m *Application) CompleteIdempotentRequest(c context.Context, userID int64, key string, code int, body []byte) error {
+	ret := _m.Called(c, userID, key, code, body)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, string, int, []byte) error); ok {
+		r0 = rf(c, userID, key, code, body)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // Config provides a mock function with given fields:
 func (_m *Application) Config() *config.Config {
 	ret := _m.Called()
@@ -82,95 +160,120 @@ func (_m *Application) CreateUser(c context.Context, user *model.User) (int64, e
 }
 
 // GetBalance provides a mock function with given fields: c, userID
-func (_m *Application) GetBalance(c context.Context, userID int64) (float64, float64, error) {
+func (_m *Application) GetBalance(c context.Context, userID int64) (model.Money, model.Money, model.Money, error) {
 	ret := _m.Called(c, userID)
 
-	var r0 float64
-	if rf, ok := ret.Get(0).(func(context.Context, int64) float64); ok {
+	var r0 model.Money
+	if rf, ok := ret.Get(0).(func(context.Context, int64) model.Money); ok {
 		r0 = rf(c, userID)
 	} else {
-		r0 = ret.Get(0).(float64)
+		r0 = ret.Get(0).(model.Money)
 	}
 
-	var r1 float64
-	if rf, ok := ret.Get(1).(func(context.Context, int64) float64); ok {
+	var r1 model.Money
+	if rf, ok := ret.Get(1).(func(context.Context, int64) model.Money); ok {
 		r1 = rf(c, userID)
 	} else {
-		r1 = ret.Get(1).(float64)
+		r1 = ret.Get(1).(model.Money)
 	}
 
-	var r2 error
-	if rf, ok := ret.Get(2).(func(context.Context, int64) error); ok {
+	var r2 model.Money
+	if rf, ok := ret.Get(2).(func(context.Context, int64) model.Money); ok {
 		r2 = rf(c, userID)
 	} else {
-		r2 = ret.Error(2)
+		r2 = ret.Get(2).(model.Money)
 	}
 
-	return r0, r1, r2
+	var r3 error
+	if rf, ok := ret.Get(3).(func(context.Context, int64) error); ok {
+		r3 = rf(c, userID)
+	} else {
+		r3 = ret.Error(3)
+	}
+
+	return r0, r1, r2, r3
 }
 
-// GetOrdersByStatuses provides a mock function with given fields: statuses
-func (_m *Application) GetOrdersByStatuses(statuses []string) ([]model.Order, error) {
-	ret := _m.Called(statuses)
+// GetBalanceHistory provides a mock function with given fields: c, userID, filter
+func (_m *Application) GetBalanceHistory(c context.Context, userID int64, filter model.ListFilter) ([]model.BalanceOperation, *model.Cursor, error) {
+	ret := _m.Called(c, userID, filter)
 
-	var r0 []model.Order
-	if rf, ok := ret.Get(0).(func([]string) []model.Order); ok {
-		r0 = rf(statuses)
+	var r0 []model.BalanceOperation
+	if rf, ok := ret.Get(0).(func(context.Context, int64, model.ListFilter) []model.BalanceOperation); ok {
+		r0 = rf(c, userID, filter)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]model.Order)
+			r0 = ret.Get(0).([]model.BalanceOperation)
 		}
 	}
 
-	var r1 error
-	if rf, ok := ret.Get(1).(func([]string) error); ok {
-		r1 = rf(statuses)
+	var r1 *model.Cursor
+	if rf, ok := ret.Get(1).(func(context.Context, int64, model.ListFilter) *model.Cursor); ok {
+		r1 = rf(c, userID, filter)
 	} else {
-		r1 = ret.Error(1)
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(*model.Cursor)
+		}
 	}
 
-	return r0, r1
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, int64, model.ListFilter) error); ok {
+		r2 = rf(c, userID, filter)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
 }
 
-// GetOrdersByUser provides a mock function with given fields: c, userID
-func (_m *Application) GetOrdersByUser(c context.Context, userID int64) ([]model.Order, error) {
-	ret := _m.Called(c, userID)
+// GetOrdersByUser provides a mock function with given fields: c, userID, filter
+func (_m *Application) GetOrdersByUser(c context.Context, userID int64, filter model.ListFilter) ([]model.Order, *model.Cursor, error) {
+	ret := _m.Called(c, userID, filter)
 
 	var r0 []model.Order
-	if rf, ok := ret.Get(0).(func(context.Context, int64) []model.Order); ok {
-		r0 = rf(c, userID)
+	if rf, ok := ret.Get(0).(func(context.Context, int64, model.ListFilter) []model.Order); ok {
+		r0 = rf(c, userID, filter)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]model.Order)
 		}
 	}
 
-	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
-		r1 = rf(c, userID)
+	var r1 *model.Cursor
+	if rf, ok := ret.Get(1).(func(context.Context, int64, model.ListFilter) *model.Cursor); ok {
+		r1 = rf(c, userID, filter)
 	} else {
-		r1 = ret.Error(1)
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(*model.Cursor)
+		}
 	}
 
-	return r0, r1
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, int64, model.ListFilter) error); ok {
+		r2 = rf(c, userID, filter)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
 }
 
-// GetRefreshSessionByToken provides a mock function with given fields: c, refreshToken
-func (_m *Application) GetRefreshSessionByToken(c context.Context, refreshToken string) (*model.RefreshSession, error) {
-	ret := _m.Called(c, refreshToken)
+// GetSessions provides a mock function with given fields: c, userID
+func (_m *Application) GetSessions(c context.Context, userID int64) ([]model.Session, error) {
+	ret := _m.Called(c, userID)
 
-	var r0 *model.RefreshSession
-	if rf, ok := ret.Get(0).(func(context.Context, string) *model.RefreshSession); ok {
-		r0 = rf(c, refreshToken)
+	var r0 []model.Session
+	if rf, ok := ret.Get(0).(func(context.Context, int64) []model.Session); ok {
+		r0 = rf(c, userID)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*model.RefreshSession)
+			r0 = ret.Get(0).([]model.Session)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = rf(c, refreshToken)
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(c, userID)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -201,22 +304,73 @@ func (_m *Application) GetUser(c context.Context, login string, pwd string) (*mo
 	return r0, r1
 }
 
-// GetWithdrawals provides a mock function with given fields: c, userID
-func (_m *Application) GetWithdrawals(c context.Context, userID int64) ([]model.Withdraw, error) {
-	ret := _m.Called(c, userID)
+// GetWithdrawals provides a mock function with given fields: c, userID, filter
+func (_m *Application) GetWithdrawals(c context.Context, userID int64, filter model.ListFilter) ([]model.Withdraw, *model.Cursor, error) {
+	ret := _m.Called(c, userID, filter)
 
 	var r0 []model.Withdraw
-	if rf, ok := ret.Get(0).(func(context.Context, int64) []model.Withdraw); ok {
-		r0 = rf(c, userID)
+	if rf, ok := ret.Get(0).(func(context.Context, int64, model.ListFilter) []model.Withdraw); ok {
+		r0 = rf(c, userID, filter)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]model.Withdraw)
 		}
 	}
 
+	var r1 *model.Cursor
+	if rf, ok := ret.Get(1).(func(context.Context, int64, model.ListFilter) *model.Cursor); ok {
+		r1 = rf(c, userID, filter)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(*model.Cursor)
+		}
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, int64, model.ListFilter) error); ok {
+		r2 = rf(c, userID, filter)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// HoldPoints provides a mock function with given fields: c, userID, orderNumber, sum
+func (_m *Application) HoldPoints(c context.Context, userID int64, orderNumber string, sum model.Money) (model.Hold, error) {
+	ret := _m.Called(c, userID, orderNumber, sum)
+
+	var r0 model.Hold
+	if rf, ok := ret.Get(0).(func(context.Context, int64, string, model.Money) model.Hold); ok {
+		r0 = rf(c, userID, orderNumber, sum)
+	} else {
+		r0 = ret.Get(0).(model.Hold)
+	}
+
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
-		r1 = rf(c, userID)
+	if rf, ok := ret.Get(1).(func(context.Context, int64, string, model.Money) error); ok {
+		r1 = rf(c, userID, orderNumber, sum)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// IsSessionActive provides a mock function with given fields: c, userID, sessionID
+func (_m *Application) IsSessionActive(c context.Context, userID int64, sessionID string) (bool, error) {
+	ret := _m.Called(c, userID, sessionID)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, int64, string) bool); ok {
+		r0 = rf(c, userID, sessionID)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int64, string) error); ok {
+		r1 = rf(c, userID, sessionID)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -238,13 +392,62 @@ func (_m *Application) NewRefreshSession(c context.Context, newRefreshSession *m
 	return r0
 }
 
-// UpdateOrders provides a mock function with given fields: newOrderStatuses
-func (_m *Application) UpdateOrders(newOrderStatuses []model.Order) error {
-	ret := _m.Called(newOrderStatuses)
+// ReleaseHold provides a mock function with given fields: c, userID, orderNumber
+func (_m *Application) ReleaseHold(c context.Context, userID int64, orderNumber string) (model.Hold, error) {
+	ret := _m.Called(c, userID, orderNumber)
+
+	var r0 model.Hold
+	if rf, ok := ret.Get(0).(func(context.Context, int64, string) model.Hold); ok {
+		r0 = rf(c, userID, orderNumber)
+	} else {
+		r0 = ret.Get(0).(model.Hold)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int64, string) error); ok {
+		r1 = rf(c, userID, orderNumber)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RevokeSession provides a mock function with given fields: c, userID, sessionID
+func (_m *Application) RevokeSession(c context.Context, userID int64, sessionID string) error {
+	ret := _m.Called(c, userID, sessionID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, string) error); ok {
+		r0 = rf(c, userID, sessionID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RevokeSessions provides a mock function with given fields: c, userID
+func (_m *Application) RevokeSessions(c context.Context, userID int64) error {
+	ret := _m.Called(c, userID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) error); ok {
+		r0 = rf(c, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RotateRefreshSession provides a mock function with given fields: c, refreshToken, newRefreshSession
+func (_m *Application) RotateRefreshSession(c context.Context, refreshToken string, newRefreshSession *model.RefreshSession) error {
+	ret := _m.Called(c, refreshToken, newRefreshSession)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func([]model.Order) error); ok {
-		r0 = rf(newOrderStatuses)
+	if rf, ok := ret.Get(0).(func(context.Context, string, *model.RefreshSession) error); ok {
+		r0 = rf(c, refreshToken, newRefreshSession)
 	} else {
 		r0 = ret.Error(0)
 	}
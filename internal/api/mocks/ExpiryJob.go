@@ -0,0 +1,43 @@
+// Code generated by mockery v2.14.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// ExpiryJob is an autogenerated mock type for the ExpiryJob type
+type ExpiryJob struct {
+	mock.Mock
+}
+
+// Run provides a mock function with given fields: ctx
+func (_m *ExpiryJob) Run(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+type mockConstructorTestingTNewExpiryJob interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+// NewExpiryJob creates a new instance of ExpiryJob. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+func NewExpiryJob(t mockConstructorTestingTNewExpiryJob) *ExpiryJob {
+	mock := &ExpiryJob{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -4,7 +4,6 @@ import (
 	"errors"
 	"io"
 	"net/http"
-	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -15,7 +14,6 @@ import (
 )
 
 var (
-	errInvalidOrderNumber            = errors.New("invalid order number")
 	errOrderWasUploadedByCurrentUser = errors.New("the order was uploaded by current user")
 	errOrderWasUploadedByAnotherUser = errors.New("the order was uploaded by another user")
 )
@@ -37,12 +35,9 @@ func (a *API) setOrderHandler(c *gin.Context) {
 	}
 
 	orderNumber := string(reqBody)
-	if numberForCheckValid, err := strconv.Atoi(orderNumber); err != nil {
+	if err = app.ValidateOrderNumber(orderNumber); err != nil {
 		a.error(c, http.StatusUnprocessableEntity, err)
 		return
-	} else if !isValidNumber(int64(numberForCheckValid)) {
-		a.error(c, http.StatusUnprocessableEntity, errInvalidOrderNumber)
-		return
 	}
 
 	order := model.Order{
@@ -77,7 +72,13 @@ func (a *API) ordersHandler(c *gin.Context) {
 		return
 	}
 
-	orders, err := a.app.GetOrdersByUser(c, userID)
+	filter, err := parseListFilter(c, orderStatuses)
+	if err != nil {
+		a.error(c, http.StatusBadRequest, err)
+		return
+	}
+
+	orders, next, err := a.app.GetOrdersByUser(c, userID, filter)
 	if err != nil {
 		a.error(c, http.StatusInternalServerError, err)
 		return
@@ -87,28 +88,7 @@ func (a *API) ordersHandler(c *gin.Context) {
 		return
 	}
 
-	a.respond(c, http.StatusOK, orders)
-}
-
-func isValidNumber(number int64) bool {
-	return (number%10+checksum(number/10))%10 == 0
-}
-
-func checksum(number int64) int64 {
-	var luhn int64
-
-	for i := 0; number > 0; i++ {
-		cur := number % 10
+	setNextCursor(c, next)
 
-		if i%2 == 0 {
-			cur *= 2
-			if cur > 9 {
-				cur = cur%10 + cur/10
-			}
-		}
-
-		luhn += cur
-		number /= 10
-	}
-	return luhn % 10
+	a.respond(c, http.StatusOK, orders)
 }
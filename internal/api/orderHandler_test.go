@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -98,7 +99,7 @@ func TestAPI_setOrderHandler(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			testAPI := API{}
 			testAPI.app = tt.mockApp
-			testAPI.authMngr = newAuthMngr()
+			testAPI.authMngr = newTestAuthMngr(t)
 
 			rec := httptest.NewRecorder()
 
@@ -121,35 +122,77 @@ func TestAPI_setOrderHandler(t *testing.T) {
 }
 
 func TestAPI_ordersHandler(t *testing.T) {
+	next := &model.Cursor{Time: time.Unix(2, 0), ID: 7}
+
 	tests := []struct {
-		mockApp      *mocks.Application
-		name         string
-		expectedCode int
-		authorized   bool
+		mockApp            *mocks.Application
+		name               string
+		query              string
+		expectedNextCursor string
+		expectedCode       int
+		authorized         bool
 	}{
 		{
 			name: "OK",
 			mockApp: func() *mocks.Application {
 				testApp := mocks.Application{}
-				testApp.On("GetOrdersByUser", mock.AnythingOfType("*gin.Context"), mock.AnythingOfType("int64")).
-					Return([]model.Order{{UserID: 1}}, nil).
+				testApp.On("GetOrdersByUser", mock.AnythingOfType("*gin.Context"), int64(1), model.ListFilter{}).
+					Return([]model.Order{{UserID: 1}}, nil, nil).
 					Once()
 				return &testApp
 			}(),
 			authorized:   true,
 			expectedCode: http.StatusOK,
 		},
+		{
+			name:  "filtered page with next page",
+			query: "?limit=1&cursor=" + next.String() + "&status=new,processed&status=INVALID&from=2022-01-01T00:00:00Z&to=2022-02-01T00:00:00Z",
+			mockApp: func() *mocks.Application {
+				testApp := mocks.Application{}
+				testApp.On("GetOrdersByUser", mock.AnythingOfType("*gin.Context"), int64(1), model.ListFilter{
+					Limit:    1,
+					After:    &model.Cursor{Time: time.Unix(2, 0).UTC(), ID: 7},
+					Statuses: []string{"NEW", "PROCESSED", "INVALID"},
+					From:     time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+					To:       time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC),
+				}).
+					Return([]model.Order{{UserID: 1}}, &model.Cursor{Time: time.Unix(3, 0), ID: 9}, nil).
+					Once()
+				return &testApp
+			}(),
+			authorized:         true,
+			expectedNextCursor: model.Cursor{Time: time.Unix(3, 0), ID: 9}.String(),
+			expectedCode:       http.StatusOK,
+		},
 		{
 			name:         "unauthorized",
 			authorized:   false,
 			expectedCode: http.StatusUnauthorized,
 		},
+		{
+			name:         "invalid status",
+			query:        "?status=DONE",
+			authorized:   true,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "invalid cursor",
+			query:        "?cursor=abc",
+			authorized:   true,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "invalid time range",
+			query:        "?from=2022-02-01T00:00:00Z&to=2022-01-01T00:00:00Z",
+			authorized:   true,
+			expectedCode: http.StatusBadRequest,
+		},
 		{
 			name: "unexpected err",
 			mockApp: func() *mocks.Application {
 				testApp := mocks.Application{}
-				testApp.On("GetOrdersByUser", mock.AnythingOfType("*gin.Context"), mock.AnythingOfType("int64")).
-					Return(nil, errors.New("unexpected error")).
+				testApp.On("GetOrdersByUser", mock.AnythingOfType("*gin.Context"), int64(1), model.ListFilter{}).
+					Return(nil, nil, errors.New("unexpected error")).
 					Once()
 				return &testApp
 			}(),
@@ -160,8 +203,8 @@ func TestAPI_ordersHandler(t *testing.T) {
 			name: "no orders",
 			mockApp: func() *mocks.Application {
 				testApp := mocks.Application{}
-				testApp.On("GetOrdersByUser", mock.AnythingOfType("*gin.Context"), mock.AnythingOfType("int64")).
-					Return(nil, nil).
+				testApp.On("GetOrdersByUser", mock.AnythingOfType("*gin.Context"), int64(1), model.ListFilter{}).
+					Return(nil, nil, nil).
 					Once()
 				return &testApp
 			}(),
@@ -173,7 +216,7 @@ func TestAPI_ordersHandler(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			testAPI := API{}
 			testAPI.app = tt.mockApp
-			testAPI.authMngr = newAuthMngr()
+			testAPI.authMngr = newTestAuthMngr(t)
 
 			rec := httptest.NewRecorder()
 
@@ -181,10 +224,15 @@ func TestAPI_ordersHandler(t *testing.T) {
 			if tt.authorized {
 				testCtx.Set("id", int64(1))
 			}
+			testCtx.Request = httptest.NewRequest(http.MethodGet, "/api/user/orders"+tt.query, nil)
 
 			testAPI.ordersHandler(testCtx)
 
 			assert.Equal(t, tt.expectedCode, rec.Code)
+			assert.Equal(t, tt.expectedNextCursor, rec.Header().Get(headerNextCursor))
+			if tt.mockApp != nil {
+				tt.mockApp.AssertExpectations(t)
+			}
 		})
 	}
 }
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"practicum-gophermart/internal/model"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 100
+
+	queryParamLimit  = "limit"
+	queryParamCursor = "cursor"
+	queryParamStatus = "status"
+	queryParamFrom   = "from"
+	queryParamTo     = "to"
+
+	headerNextCursor = "X-Next-Cursor"
+)
+
+var (
+	errInvalidLimit  = errors.New("limit must be a number from 1 to 100")
+	errInvalidStatus = errors.New("invalid status")
+	errInvalidFrom   = errors.New("from must be a time in RFC3339 format")
+	errInvalidTo     = errors.New("to must be a time in RFC3339 format")
+	errInvalidRange  = errors.New("from must be before to")
+)
+
+var orderStatuses = map[string]bool{
+	model.OrderStatusNew.String():        true,
+	model.OrderStatusProcessing.String(): true,
+	model.OrderStatusInvalid.String():    true,
+	model.OrderStatusProcessed.String():  true,
+}
+
+// parseListFilter returns limit, cursor and time range of the requested page of a listing.
+// The listing is not paginated if the limit is not requested.
+// Statuses are accepted only if the listing can be filtered by them, nil statuses reject any status,
+// as a comma separated list or a repeated query param.
+func parseListFilter(c *gin.Context, statuses map[string]bool) (filter model.ListFilter, err error) {
+	if _, ok := c.GetQuery(queryParamLimit); ok {
+		if filter.Limit, err = parseLimit(c); err != nil {
+			return model.ListFilter{}, err
+		}
+	}
+
+	if value := c.Query(queryParamCursor); value != "" {
+		if filter.After, err = model.ParseCursor(value); err != nil {
+			return model.ListFilter{}, err
+		}
+	}
+
+	for _, values := range c.QueryArray(queryParamStatus) {
+		for _, status := range strings.Split(values, ",") {
+			status = strings.ToUpper(strings.TrimSpace(status))
+			if !statuses[status] {
+				return model.ListFilter{}, errInvalidStatus
+			}
+			filter.Statuses = append(filter.Statuses, status)
+		}
+	}
+
+	if value := c.Query(queryParamFrom); value != "" {
+		if filter.From, err = time.Parse(time.RFC3339, value); err != nil {
+			return model.ListFilter{}, errInvalidFrom
+		}
+	}
+
+	if value := c.Query(queryParamTo); value != "" {
+		if filter.To, err = time.Parse(time.RFC3339, value); err != nil {
+			return model.ListFilter{}, errInvalidTo
+		}
+	}
+
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return model.ListFilter{}, errInvalidRange
+	}
+
+	return filter, nil
+}
+
+func parseLimit(c *gin.Context) (limit int, err error) {
+	limit = defaultPageLimit
+	if value, ok := c.GetQuery(queryParamLimit); ok {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > maxPageLimit {
+			return 0, errInvalidLimit
+		}
+	}
+	return limit, nil
+}
+
+// setNextCursor tells the client where the next page starts. There is no header on the last page.
+func setNextCursor(c *gin.Context, next *model.Cursor) {
+	if next != nil {
+		c.Header(headerNextCursor, next.String())
+	}
+}
@@ -15,3 +15,8 @@ func (a *API) error(c *gin.Context, code int, err error) {
 		a.respond(c, code, nil)
 	}
 }
+
+func (a *API) abort(c *gin.Context, code int, err error) {
+	a.error(c, code, err)
+	c.Abort()
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"practicum-gophermart/internal/app"
+)
+
+// sessionsHandler returns the active sessions of the user on the devices.
+func (a *API) sessionsHandler(c *gin.Context) {
+	log.Debug().Msg("api.sessionsHandler START")
+	defer log.Debug().Msg("api.sessionsHandler END")
+
+	userID, err := a.authMngr.getID(c)
+	if err != nil {
+		a.error(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	sessions, err := a.app.GetSessions(c, userID)
+	if err != nil {
+		a.error(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	if len(sessions) == 0 {
+		a.respond(c, http.StatusNoContent, nil)
+		return
+	}
+
+	a.respond(c, http.StatusOK, sessions)
+}
+
+// revokeSessionHandler signs the user out on the device of the session.
+// The access tokens issued for the session are rejected right away,
+// the auth middleware checks that their session is still active.
+func (a *API) revokeSessionHandler(c *gin.Context) {
+	log.Debug().Msg("api.revokeSessionHandler START")
+	defer log.Debug().Msg("api.revokeSessionHandler END")
+
+	userID, err := a.authMngr.getID(c)
+	if err != nil {
+		a.error(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	sessionID := c.Param("id")
+	if _, err = uuid.Parse(sessionID); err != nil {
+		a.error(c, http.StatusNotFound, app.ErrSessionNotFound)
+		return
+	}
+
+	err = a.app.RevokeSession(c, userID, sessionID)
+	if err != nil {
+		if errors.Is(err, app.ErrSessionNotFound) {
+			a.error(c, http.StatusNotFound, err)
+		} else {
+			a.error(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	a.respond(c, http.StatusNoContent, nil)
+}
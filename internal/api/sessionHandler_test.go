@@ -0,0 +1,183 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"practicum-gophermart/internal/api/mocks"
+	"practicum-gophermart/internal/app"
+	"practicum-gophermart/internal/model"
+)
+
+func TestAPI_sessionsHandler(t *testing.T) {
+	tests := []struct {
+		mockApp      *mocks.Application
+		name         string
+		expectedBody string
+		expectedCode int
+		authorized   bool
+	}{
+		{
+			name: "OK",
+			mockApp: func() *mocks.Application {
+				testApp := mocks.Application{}
+				testApp.On("GetSessions", mock.AnythingOfType("*gin.Context"), int64(1)).
+					Return([]model.Session{{ID: "0c9ab3c5-5f4e-4a0e-9d4b-6b6f3b0a8d21", UserAgent: "Mozilla/5.0", IP: "127.0.0.1",
+						CreatedAt: time.Unix(0, 0).UTC(), LastUsedAt: time.Unix(60, 0).UTC()}}, nil).
+					Once()
+				return &testApp
+			}(),
+			authorized: true,
+			expectedBody: `[{"id": "0c9ab3c5-5f4e-4a0e-9d4b-6b6f3b0a8d21", "user_agent": "Mozilla/5.0", "ip": "127.0.0.1",
+				"created_at": "1970-01-01T00:00:00Z", "last_used_at": "1970-01-01T00:01:00Z"}]`,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "unauthorized",
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name: "no sessions",
+			mockApp: func() *mocks.Application {
+				testApp := mocks.Application{}
+				testApp.On("GetSessions", mock.AnythingOfType("*gin.Context"), int64(1)).
+					Return(nil, nil).
+					Once()
+				return &testApp
+			}(),
+			authorized:   true,
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name: "unexpected error",
+			mockApp: func() *mocks.Application {
+				testApp := mocks.Application{}
+				testApp.On("GetSessions", mock.AnythingOfType("*gin.Context"), int64(1)).
+					Return(nil, errors.New("unexpected error")).
+					Once()
+				return &testApp
+			}(),
+			authorized:   true,
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testAPI := API{}
+			testAPI.app = tt.mockApp
+			testAPI.authMngr = newTestAuthMngr(t)
+
+			rec := httptest.NewRecorder()
+
+			testCtx, _ := gin.CreateTestContext(rec)
+			if tt.authorized {
+				testCtx.Set("id", int64(1))
+			}
+			testCtx.Request = httptest.NewRequest(http.MethodGet, "/sessionsMockEndpoint", nil)
+
+			testAPI.sessionsHandler(testCtx)
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			if tt.expectedBody != "" {
+				assert.JSONEq(t, tt.expectedBody, rec.Body.String())
+			}
+			if tt.mockApp != nil {
+				tt.mockApp.AssertExpectations(t)
+			}
+		})
+	}
+}
+
+func TestAPI_revokeSessionHandler(t *testing.T) {
+	const sessionID = "0c9ab3c5-5f4e-4a0e-9d4b-6b6f3b0a8d21"
+
+	tests := []struct {
+		mockApp      *mocks.Application
+		name         string
+		sessionID    string
+		expectedCode int
+		authorized   bool
+	}{
+		{
+			name:      "OK",
+			sessionID: sessionID,
+			mockApp: func() *mocks.Application {
+				testApp := mocks.Application{}
+				testApp.On("RevokeSession", mock.AnythingOfType("*gin.Context"), int64(1), sessionID).
+					Return(nil).
+					Once()
+				return &testApp
+			}(),
+			authorized:   true,
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:         "unauthorized",
+			sessionID:    sessionID,
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "invalid session id",
+			sessionID:    "123",
+			authorized:   true,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:      "session not found",
+			sessionID: sessionID,
+			mockApp: func() *mocks.Application {
+				testApp := mocks.Application{}
+				testApp.On("RevokeSession", mock.AnythingOfType("*gin.Context"), int64(1), sessionID).
+					Return(app.ErrSessionNotFound).
+					Once()
+				return &testApp
+			}(),
+			authorized:   true,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:      "unexpected error",
+			sessionID: sessionID,
+			mockApp: func() *mocks.Application {
+				testApp := mocks.Application{}
+				testApp.On("RevokeSession", mock.AnythingOfType("*gin.Context"), int64(1), sessionID).
+					Return(errors.New("unexpected error")).
+					Once()
+				return &testApp
+			}(),
+			authorized:   true,
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testAPI := API{}
+			testAPI.app = tt.mockApp
+			testAPI.authMngr = newTestAuthMngr(t)
+
+			rec := httptest.NewRecorder()
+
+			router := gin.New()
+			router.DELETE("/sessions/:id", func(c *gin.Context) {
+				if tt.authorized {
+					c.Set("id", int64(1))
+				}
+			}, testAPI.revokeSessionHandler)
+
+			req := httptest.NewRequest(http.MethodDelete, "/sessions/"+tt.sessionID, nil)
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			if tt.mockApp != nil {
+				tt.mockApp.AssertExpectations(t)
+			}
+		})
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 	"golang.org/x/crypto/bcrypt"
 
@@ -16,6 +18,8 @@ var (
 	ErrUserAlreadyExists        = errors.New("user already exists")
 	ErrInvalidLoginOrPassword   = errors.New("invalid login or password")
 	ErrRefreshSessionIsNotExist = errors.New("refresh session is not exists")
+	ErrRefreshTokenReused       = errors.New("refresh token is reused, all the sessions of the token family are revoked")
+	ErrSessionNotFound          = errors.New("session is not found")
 )
 
 func (a *App) CreateUser(c context.Context, user *model.User) (id int64, err error) {
@@ -71,12 +75,15 @@ func (a *App) GetUser(c context.Context, login, pwd string) (user *model.User, e
 	return user, nil
 }
 
+// NewRefreshSession adds the refresh session of the signed-in user, the session starts a new token family.
 func (a *App) NewRefreshSession(c context.Context, newRefreshSession *model.RefreshSession) (err error) {
 	log.Debug().Str("userID", fmt.Sprint(newRefreshSession.UserID)).Msg("app.NewRefreshSession START")
 	defer func() {
 		logMethodEnd("app.NewRefreshSession", err)
 	}()
 
+	newRefreshSession.FamilyID = uuid.New().String()
+
 	err = a.storage.UpdateRefreshSession(c, newRefreshSession)
 	if err != nil {
 		return err
@@ -85,19 +92,87 @@ func (a *App) NewRefreshSession(c context.Context, newRefreshSession *model.Refr
 	return nil
 }
 
-func (a *App) GetRefreshSessionByToken(c context.Context, refreshToken string) (refreshSession *model.RefreshSession, err error) {
-	log.Debug().Msg("app.GetRefreshSessionByToken START")
+// RotateRefreshSession replaces the refresh session of the token with the new one of the same family and sets its user.
+// The token can be rotated only once, ErrRefreshSessionIsNotExist is returned for the unknown, expired or revoked token.
+// The reuse of the rotated token revokes the whole family and ErrRefreshTokenReused is returned.
+func (a *App) RotateRefreshSession(c context.Context, refreshToken string, newRefreshSession *model.RefreshSession) (err error) {
+	log.Debug().Msg("app.RotateRefreshSession START")
 	defer func() {
-		logMethodEnd("app.GetRefreshSessionByToken", err)
+		logMethodEnd("app.RotateRefreshSession", err)
 	}()
 
-	refreshSession, err = a.storage.GetRefreshSessionByToken(c, refreshToken)
+	err = a.storage.RotateRefreshSession(c, refreshToken, time.Now(), newRefreshSession)
 	if err != nil {
 		if errors.Is(err, dberr.ErrRefreshSessionIsNotExists) {
-			return nil, ErrRefreshSessionIsNotExist
+			return ErrRefreshSessionIsNotExist
+		} else if errors.Is(err, dberr.ErrRefreshTokenIsReused) {
+			return ErrRefreshTokenReused
 		}
+		return err
+	}
+
+	return nil
+}
+
+// GetSessions returns the active sessions of the user on the devices.
+func (a *App) GetSessions(c context.Context, userID int64) (sessions []model.Session, err error) {
+	log.Debug().Msg("app.GetSessions START")
+	defer func() {
+		logMethodEnd("app.GetSessions", err)
+	}()
+
+	sessions, err = a.storage.GetSessions(c, userID)
+	if err != nil {
 		return nil, err
 	}
 
-	return refreshSession, nil
+	return sessions, nil
+}
+
+// RevokeSession signs the user out on the device of the session, its refresh tokens can not be rotated anymore.
+func (a *App) RevokeSession(c context.Context, userID int64, sessionID string) (err error) {
+	log.Debug().Str("session_id", sessionID).Msg("app.RevokeSession START")
+	defer func() {
+		logMethodEnd("app.RevokeSession", err)
+	}()
+
+	err = a.storage.RevokeSession(c, userID, sessionID, time.Now())
+	if err != nil {
+		if errors.Is(err, dberr.ErrSessionIsNotExists) {
+			return ErrSessionNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+// RevokeSessions signs the user out on all the devices.
+func (a *App) RevokeSessions(c context.Context, userID int64) (err error) {
+	log.Debug().Msg("app.RevokeSessions START")
+	defer func() {
+		logMethodEnd("app.RevokeSessions", err)
+	}()
+
+	err = a.storage.RevokeSessions(c, userID, time.Now())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// IsSessionActive reports whether the session of the user is not revoked.
+func (a *App) IsSessionActive(c context.Context, userID int64, sessionID string) (active bool, err error) {
+	log.Debug().Str("session_id", sessionID).Msg("app.IsSessionActive START")
+	defer func() {
+		logMethodEnd("app.IsSessionActive", err)
+	}()
+
+	active, err = a.storage.IsSessionActive(c, userID, sessionID)
+	if err != nil {
+		return false, err
+	}
+
+	return active, nil
 }
@@ -3,6 +3,7 @@ package app
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/rs/zerolog/log"
 
@@ -10,20 +11,26 @@ import (
 	dberr "practicum-gophermart/internal/storage/errors"
 )
 
-var ErrInsufficientFunds = errors.New("there are not enough funds in the account")
+var (
+	ErrInsufficientFunds          = errors.New("there are not enough funds in the account")
+	ErrWithdrawalNotFound         = errors.New("the withdrawal is not found")
+	ErrWithdrawalAlreadyCancelled = errors.New("the withdrawal is already cancelled")
+)
 
-func (a *App) GetBalance(c context.Context, userID int64) (balance, withdrawn float64, err error) {
+// GetBalance returns the available balance of the user, the points held for the pending payments
+// and the sum of the withdrawals.
+func (a *App) GetBalance(c context.Context, userID int64) (balance, held, withdrawn model.Money, err error) {
 	log.Debug().Msg("app.GetBalance START")
 	defer func() {
 		logMethodEnd("app.GetBalance", err)
 	}()
 
-	balance, withdrawn, err = a.storage.GetBalance(c, userID)
+	balance, held, withdrawn, err = a.storage.GetBalance(c, userID)
 	if err != nil {
-		return -1, -1, err
+		return -1, -1, -1, err
 	}
 
-	return balance, withdrawn, nil
+	return balance, held, withdrawn, nil
 }
 
 func (a *App) WithdrawFromBalance(c context.Context, userID int64, withdraw model.Withdraw) (err error) {
@@ -32,6 +39,10 @@ func (a *App) WithdrawFromBalance(c context.Context, userID int64, withdraw mode
 		logMethodEnd("app.WithdrawFromBalance", err)
 	}()
 
+	if err = ValidateWithdraw(withdraw); err != nil {
+		return err
+	}
+
 	err = a.storage.AddWithdrawal(c, userID, withdraw)
 	if err != nil {
 		if errors.Is(err, dberr.ErrNegativeBalance) {
@@ -43,16 +54,69 @@ func (a *App) WithdrawFromBalance(c context.Context, userID int64, withdraw mode
 	return nil
 }
 
-func (a *App) GetWithdrawals(c context.Context, userID int64) (withdrawals []model.Withdraw, err error) {
+func (a *App) GetWithdrawals(c context.Context, userID int64, filter model.ListFilter) (withdrawals []model.Withdraw,
+	next *model.Cursor, err error) {
 	log.Debug().Msg("app.GetWithdrawals START")
 	defer func() {
 		logMethodEnd("app.WithdrawFromBalance", err)
 	}()
 
-	withdrawals, err = a.storage.GetWithdrawals(c, userID)
+	withdrawals, next, err = a.storage.GetWithdrawals(c, userID, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return withdrawals, next, nil
+}
+
+// CancelWithdrawal cancels the withdrawal for the order and returns the points to the user.
+// The returned points expire as if they were accrued now.
+func (a *App) CancelWithdrawal(c context.Context, orderNumber string) (withdraw model.Withdraw, err error) {
+	log.Debug().Str("order_number", orderNumber).Msg("app.CancelWithdrawal START")
+	defer func() {
+		logMethodEnd("app.CancelWithdrawal", err)
+	}()
+
+	cancelledAt := time.Now()
+	withdraw, err = a.storage.CancelWithdrawal(c, orderNumber, cancelledAt, cancelledAt.AddDate(0, a.cfg.PointsExpiryMonths(), 0))
+	if err != nil {
+		if errors.Is(err, dberr.ErrWithdrawalIsNotExists) {
+			return model.Withdraw{}, ErrWithdrawalNotFound
+		} else if errors.Is(err, dberr.ErrWithdrawalIsAlreadyCancelled) {
+			return model.Withdraw{}, ErrWithdrawalAlreadyCancelled
+		}
+		return model.Withdraw{}, err
+	}
+
+	return withdraw, nil
+}
+
+func (a *App) GetBalanceHistory(c context.Context, userID int64, filter model.ListFilter) (history []model.BalanceOperation,
+	next *model.Cursor, err error) {
+	log.Debug().Msg("app.GetBalanceHistory START")
+	defer func() {
+		logMethodEnd("app.GetBalanceHistory", err)
+	}()
+
+	history, next, err = a.storage.GetBalanceHistory(c, userID, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return history, next, nil
+}
+
+// ExpirePointLots expires no more than limit lots of points expired by expiredAt and returns them.
+func (a *App) ExpirePointLots(c context.Context, expiredAt time.Time, limit int) (lots []model.PointLot, err error) {
+	log.Debug().Msg("app.ExpirePointLots START")
+	defer func() {
+		logMethodEnd("app.ExpirePointLots", err)
+	}()
+
+	lots, err = a.storage.ExpirePointLots(c, expiredAt, limit)
 	if err != nil {
 		return nil, err
 	}
 
-	return withdrawals, nil
+	return lots, nil
 }
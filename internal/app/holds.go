@@ -0,0 +1,119 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"practicum-gophermart/internal/model"
+	dberr "practicum-gophermart/internal/storage/errors"
+)
+
+var (
+	ErrHoldNotFound      = errors.New("the hold is not found")
+	ErrHoldNotActive     = errors.New("the hold is already captured, released or expired")
+	ErrHoldAlreadyExists = errors.New("the hold for the order already exists")
+	ErrOrderWithdrawn    = errors.New("the points are already withdrawn for the order")
+)
+
+// HoldPoints holds the sum of the available balance of the user for the order until the hold is captured or released.
+// The hold is released automatically after the hold ttl.
+func (a *App) HoldPoints(c context.Context, userID int64, orderNumber string, sum model.Money) (hold model.Hold, err error) {
+	log.Debug().Str("order_number", orderNumber).Msg("app.HoldPoints START")
+	defer func() {
+		logMethodEnd("app.HoldPoints", err)
+	}()
+
+	if err = ValidateOrderNumber(orderNumber); err != nil {
+		return model.Hold{}, err
+	}
+	if err = ValidateWithdrawSum(sum); err != nil {
+		return model.Hold{}, err
+	}
+
+	createdAt := time.Now()
+	hold = model.Hold{
+		UserID:    userID,
+		Order:     orderNumber,
+		Sum:       sum,
+		Status:    model.HoldStatusHeld.String(),
+		CreatedAt: createdAt,
+		ExpiresAt: createdAt.Add(a.cfg.HoldTTL()),
+	}
+
+	err = a.storage.AddHold(c, hold)
+	if err != nil {
+		if errors.Is(err, dberr.ErrNegativeBalance) {
+			return model.Hold{}, ErrInsufficientFunds
+		} else if errors.Is(err, dberr.ErrHoldAlreadyExists) {
+			return model.Hold{}, ErrHoldAlreadyExists
+		} else if errors.Is(err, dberr.ErrWithdrawalAlreadyExists) {
+			return model.Hold{}, ErrOrderWithdrawn
+		}
+		return model.Hold{}, err
+	}
+
+	return hold, nil
+}
+
+// CaptureHold withdraws the points held for the order.
+func (a *App) CaptureHold(c context.Context, userID int64, orderNumber string) (hold model.Hold, err error) {
+	log.Debug().Str("order_number", orderNumber).Msg("app.CaptureHold START")
+	defer func() {
+		logMethodEnd("app.CaptureHold", err)
+	}()
+
+	hold, err = a.storage.CaptureHold(c, userID, orderNumber, time.Now())
+	if err != nil {
+		return model.Hold{}, holdErr(err)
+	}
+
+	return hold, nil
+}
+
+// ReleaseHold returns the points held for the order to the available balance.
+func (a *App) ReleaseHold(c context.Context, userID int64, orderNumber string) (hold model.Hold, err error) {
+	log.Debug().Str("order_number", orderNumber).Msg("app.ReleaseHold START")
+	defer func() {
+		logMethodEnd("app.ReleaseHold", err)
+	}()
+
+	hold, err = a.storage.ReleaseHold(c, userID, orderNumber, time.Now())
+	if err != nil {
+		return model.Hold{}, holdErr(err)
+	}
+
+	return hold, nil
+}
+
+// ReleaseExpiredHolds releases no more than limit holds expired by expiredAt and returns them.
+func (a *App) ReleaseExpiredHolds(c context.Context, expiredAt time.Time, limit int) (holds []model.Hold, err error) {
+	log.Debug().Msg("app.ReleaseExpiredHolds START")
+	defer func() {
+		logMethodEnd("app.ReleaseExpiredHolds", err)
+	}()
+
+	holds, err = a.storage.ReleaseExpiredHolds(c, expiredAt, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return holds, nil
+}
+
+// holdErr maps the storage errors of closing the hold to the app errors.
+func holdErr(err error) error {
+	switch {
+	case errors.Is(err, dberr.ErrHoldIsNotExists):
+		return ErrHoldNotFound
+	case errors.Is(err, dberr.ErrHoldIsNotActive):
+		return ErrHoldNotActive
+	case errors.Is(err, dberr.ErrNegativeBalance):
+		return ErrInsufficientFunds
+	case errors.Is(err, dberr.ErrWithdrawalAlreadyExists):
+		return ErrOrderWithdrawn
+	}
+	return err
+}
@@ -0,0 +1,71 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"practicum-gophermart/internal/model"
+)
+
+// idempotencyKeyTTL is how long the response to the request with an idempotency key is replayed,
+// after that the key can be used again.
+const idempotencyKeyTTL = time.Hour * 24
+
+// idempotencyClaimTTL is how long the request with an idempotency key is in progress,
+// after that the key left by the crashed request can be claimed again.
+const idempotencyClaimTTL = time.Minute
+
+var (
+	ErrIdempotencyKeyReused     = errors.New("the idempotency key was used for another request")
+	ErrIdempotencyKeyInProgress = errors.New("the request with the idempotency key is in progress")
+)
+
+// ClaimIdempotencyKey starts the request with the idempotency key of the user.
+// It returns the stored key with the response to replay if the request with the key was completed,
+// ErrIdempotencyKeyReused if the key was used for another request
+// and ErrIdempotencyKeyInProgress if the request with the key is not completed yet and its claim is not expired.
+// The stored key is nil when the request has to be handled.
+func (a *App) ClaimIdempotencyKey(c context.Context, userID int64, key, requestHash string) (
+	stored *model.IdempotencyKey, err error) {
+	log.Debug().Msg("app.ClaimIdempotencyKey START")
+	defer func() {
+		logMethodEnd("app.ClaimIdempotencyKey", err)
+	}()
+
+	now := time.Now()
+	newKey := model.IdempotencyKey{UserID: userID, Key: key, RequestHash: requestHash, CreatedAt: now}
+	existing, claimed, err := a.storage.ClaimIdempotencyKey(c, newKey, now.Add(-idempotencyKeyTTL), now.Add(-idempotencyClaimTTL))
+	if err != nil {
+		return nil, err
+	}
+	if claimed {
+		return nil, nil
+	}
+
+	if existing.RequestHash != requestHash {
+		return nil, ErrIdempotencyKeyReused
+	}
+	if existing.ResponseCode == 0 {
+		return nil, ErrIdempotencyKeyInProgress
+	}
+
+	return &existing, nil
+}
+
+// CompleteIdempotentRequest saves the response to replay for the request with the idempotency key of the user.
+// The key of the request failed with a server error is deleted, so the request can be retried.
+func (a *App) CompleteIdempotentRequest(c context.Context, userID int64, key string, code int, body []byte) (err error) {
+	log.Debug().Msg("app.CompleteIdempotentRequest START")
+	defer func() {
+		logMethodEnd("app.CompleteIdempotentRequest", err)
+	}()
+
+	if code >= 500 {
+		return a.storage.DeleteIdempotencyKey(c, userID, key)
+	}
+
+	return a.storage.SaveIdempotentResponse(c, userID, key, code, body)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/rs/zerolog/log"
 
@@ -22,6 +23,10 @@ func (a *App) AddOrder(c context.Context, order *model.Order) (err error) {
 		logMethodEnd("app.AddOrder", err)
 	}()
 
+	if err = ValidateOrderNumber(order.Number); err != nil {
+		return err
+	}
+
 	err = a.storage.AddOrder(c, order)
 	if err != nil {
 		if errors.Is(err, dberr.ErrOrderWasUploadedByCurrentUser) {
@@ -29,32 +34,35 @@ func (a *App) AddOrder(c context.Context, order *model.Order) (err error) {
 		} else if errors.Is(err, dberr.ErrOrderWasUploadedByAnotherUser) {
 			return ErrOrderWasUploadedByAnotherUser
 		}
+		return err
 	}
 
 	return nil
 }
 
-func (a *App) GetOrdersByUser(c context.Context, userID int64) (orders []model.Order, err error) {
+func (a *App) GetOrdersByUser(c context.Context, userID int64, filter model.ListFilter) (orders []model.Order,
+	next *model.Cursor, err error) {
 	log.Debug().Str("userID", fmt.Sprint(userID)).Msg("app.GetOrdersByUser START")
 	defer func() {
 		logMethodEnd("app.GetOrdersByUser", err)
 	}()
 
-	orders, err = a.storage.GetOrdersByUser(c, userID)
+	orders, next, err = a.storage.GetOrdersByUser(c, userID, filter)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return orders, nil
+	return orders, next, nil
 }
 
-func (a *App) GetOrdersByStatuses(statuses []string) (orders []model.Order, err error) {
-	log.Debug().Msg("app.GetOrdersByStatuses START")
+func (a *App) ClaimOrdersToPoll(c context.Context, owner string, leaseExpiresAt time.Time, statuses []string, dueAt time.Time,
+	limit int) (orders []model.Order, err error) {
+	log.Debug().Msg("app.ClaimOrdersToPoll START")
 	defer func() {
-		logMethodEnd("app.GetOrdersByStatuses", err)
+		logMethodEnd("app.ClaimOrdersToPoll", err)
 	}()
 
-	orders, err = a.storage.GetOrdersByStatuses(statuses)
+	orders, err = a.storage.ClaimOrdersToPoll(c, owner, leaseExpiresAt, statuses, dueAt, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -68,7 +76,21 @@ func (a *App) UpdateOrders(newOrderStatuses []model.Order) (err error) {
 		logMethodEnd("app.UpdateOrderStatuses", err)
 	}()
 
-	if err = a.storage.UpdateOrderStatuses(newOrderStatuses); err != nil {
+	pointsExpireAt := time.Now().AddDate(0, a.cfg.PointsExpiryMonths(), 0)
+	if err = a.storage.UpdateOrderStatuses(newOrderStatuses, pointsExpireAt); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (a *App) UpdateOrdersPollingState(c context.Context, owner string, orders []model.Order) (err error) {
+	log.Debug().Msg("app.UpdateOrdersPollingState START")
+	defer func() {
+		logMethodEnd("app.UpdateOrdersPollingState", err)
+	}()
+
+	if err = a.storage.UpdateOrdersPollingState(c, owner, orders); err != nil {
 		return err
 	}
 
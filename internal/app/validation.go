@@ -0,0 +1,59 @@
+package app
+
+import (
+	"errors"
+
+	"practicum-gophermart/internal/model"
+)
+
+var (
+	ErrInvalidOrderNumber = errors.New("invalid order number")
+	ErrInvalidWithdrawSum = errors.New("withdrawal sum must be positive")
+)
+
+// ValidateOrderNumber checks that the order number consists of digits and passes the Luhn check.
+func ValidateOrderNumber(number string) error {
+	if number == "" {
+		return ErrInvalidOrderNumber
+	}
+
+	var sum int
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		if number[i] < '0' || number[i] > '9' {
+			return ErrInvalidOrderNumber
+		}
+
+		digit := int(number[i] - '0')
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+
+	if sum%10 != 0 {
+		return ErrInvalidOrderNumber
+	}
+	return nil
+}
+
+// ValidateWithdrawSum checks that the withdrawal sum is positive,
+// a non-positive sum would increase the balance instead of reducing it.
+func ValidateWithdrawSum(sum model.Money) error {
+	if sum <= 0 {
+		return ErrInvalidWithdrawSum
+	}
+	return nil
+}
+
+// ValidateWithdraw checks the order number and the sum of the withdrawal.
+func ValidateWithdraw(withdraw model.Withdraw) error {
+	if err := ValidateOrderNumber(withdraw.Order); err != nil {
+		return err
+	}
+	return ValidateWithdrawSum(withdraw.Sum)
+}
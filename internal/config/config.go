@@ -1,9 +1,17 @@
 package config
 
 import (
+	"errors"
+	"strconv"
 	"time"
 )
 
+var (
+	ErrInvalidConfigFileLine = errors.New("invalid config file line, KEY=VALUE is expected")
+	ErrInvalidKeyFiles       = errors.New("invalid key files, kid:file is expected")
+	ErrInvalidExpiryMonths   = errors.New("invalid points expiry period, positive number of months is expected")
+)
+
 type Config struct {
 	servAPIAddr               string
 	pgConnString              string
@@ -11,6 +19,32 @@ type Config struct {
 	accrualGetOrder           string
 	logLevel                  string
 	orderStatusUpdateInterval time.Duration
+	accrualWorkersCount       int
+	accrualRateLimit          int
+	accrualPollBatchSize      int
+	accrualMaxPollBackoff     time.Duration
+	accrualLeaseTTL           time.Duration
+	accrualFailureThreshold   int
+	accrualRequestTimeout     time.Duration
+	accrualBreakerThreshold   int
+	accrualBreakerOpenTimeout time.Duration
+	accrualNotRegisteredTTL   time.Duration
+	pointsExpiryMonths        int
+	pointsExpiryInterval      time.Duration
+	serviceToken              string
+	holdTTL                   time.Duration
+	holdReleaseInterval       time.Duration
+	configFile                string
+	jwtAlgorithm              string
+	jwtSigningKey             string
+	jwtPrivateKeyFile         string
+	jwtKeyID                  string
+	jwtVerificationKeysList   string
+	jwtVerificationKeys       map[string]string
+	jwtIssuer                 string
+	jwtAudience               string
+	jwtAccessTokenTTL         time.Duration
+	jwtRefreshTokenTTL        time.Duration
 }
 
 func New(options ...string) (newCfg *Config, err error) {
@@ -25,13 +59,25 @@ func New(options ...string) (newCfg *Config, err error) {
 			if err = newCfg.parseFromEnv(); err != nil {
 				return nil, err
 			}
+		case WithFile:
+			if err = newCfg.parseFromFile(); err != nil {
+				return nil, err
+			}
 		}
 	}
 
+	if newCfg.pointsExpiryMonths < 0 {
+		return nil, ErrInvalidExpiryMonths
+	}
+
 	newCfg.setDefaultIfNotConfigured()
 
 	newCfg.accrualGetOrder = newCfg.accrualAPIAddr + "/api/orders/{number}"
 
+	if newCfg.jwtVerificationKeys, err = parseKeyFiles(newCfg.jwtVerificationKeysList); err != nil {
+		return nil, err
+	}
+
 	return newCfg, nil
 }
 
@@ -49,6 +95,70 @@ func (c *Config) setDefaultIfNotConfigured() {
 		c.orderStatusUpdateInterval = time.Second * 5
 	}
 
+	if c.accrualWorkersCount == 0 {
+		c.accrualWorkersCount = 5
+	}
+
+	if c.accrualPollBatchSize == 0 {
+		c.accrualPollBatchSize = 100
+	}
+
+	if c.accrualMaxPollBackoff == 0 {
+		c.accrualMaxPollBackoff = time.Minute * 10
+	}
+
+	if c.accrualLeaseTTL == 0 {
+		c.accrualLeaseTTL = time.Minute
+	}
+
+	if c.accrualFailureThreshold == 0 {
+		c.accrualFailureThreshold = 10
+	}
+
+	if c.accrualRequestTimeout == 0 {
+		c.accrualRequestTimeout = time.Second * 5
+	}
+
+	if c.accrualBreakerThreshold == 0 {
+		c.accrualBreakerThreshold = 5
+	}
+
+	if c.accrualBreakerOpenTimeout == 0 {
+		c.accrualBreakerOpenTimeout = time.Second * 30
+	}
+
+	if c.accrualNotRegisteredTTL == 0 {
+		c.accrualNotRegisteredTTL = time.Hour * 24
+	}
+
+	if c.pointsExpiryMonths == 0 {
+		c.pointsExpiryMonths = 12
+	}
+
+	if c.pointsExpiryInterval == 0 {
+		c.pointsExpiryInterval = time.Hour
+	}
+
+	if c.holdTTL == 0 {
+		c.holdTTL = time.Minute * 15
+	}
+
+	if c.holdReleaseInterval == 0 {
+		c.holdReleaseInterval = time.Minute
+	}
+
+	if c.jwtAlgorithm == "" {
+		c.jwtAlgorithm = "HS256"
+	}
+
+	if c.jwtAccessTokenTTL == 0 {
+		c.jwtAccessTokenTTL = time.Minute * 30
+	}
+
+	if c.jwtRefreshTokenTTL == 0 {
+		c.jwtRefreshTokenTTL = time.Hour * 24 * 30
+	}
+
 	if c.logLevel == "" {
 		c.logLevel = "info"
 	}
@@ -75,6 +185,112 @@ func (c *Config) OrderStatusUpdateInterval() time.Duration {
 	return c.orderStatusUpdateInterval
 }
 
+func (c *Config) AccrualWorkersCount() int {
+	return c.accrualWorkersCount
+}
+
+func (c *Config) AccrualRateLimit() int {
+	return c.accrualRateLimit
+}
+
+func (c *Config) AccrualPollBatchSize() int {
+	return c.accrualPollBatchSize
+}
+
+func (c *Config) AccrualMaxPollBackoff() time.Duration {
+	return c.accrualMaxPollBackoff
+}
+
+func (c *Config) AccrualLeaseTTL() time.Duration {
+	return c.accrualLeaseTTL
+}
+
+func (c *Config) AccrualFailureThreshold() int {
+	return c.accrualFailureThreshold
+}
+
+func (c *Config) AccrualRequestTimeout() time.Duration {
+	return c.accrualRequestTimeout
+}
+
+func (c *Config) AccrualBreakerThreshold() int {
+	return c.accrualBreakerThreshold
+}
+
+func (c *Config) AccrualBreakerOpenTimeout() time.Duration {
+	return c.accrualBreakerOpenTimeout
+}
+
+func (c *Config) AccrualNotRegisteredTTL() time.Duration {
+	return c.accrualNotRegisteredTTL
+}
+
+// PointsExpiryMonths is the number of months after which accrued points expire.
+// It is always positive: zero is not configured and means the default, the expiry cannot be disabled.
+func (c *Config) PointsExpiryMonths() int {
+	return c.pointsExpiryMonths
+}
+
+func (c *Config) PointsExpiryInterval() time.Duration {
+	return c.pointsExpiryInterval
+}
+
+// ServiceToken authenticates the internal service-to-service API, the API is disabled when the token is empty.
+func (c *Config) ServiceToken() string {
+	return c.serviceToken
+}
+
+// HoldTTL is the time after which the points held for the pending payment are released automatically.
+func (c *Config) HoldTTL() time.Duration {
+	return c.holdTTL
+}
+
+func (c *Config) HoldReleaseInterval() time.Duration {
+	return c.holdReleaseInterval
+}
+
+// JWTAlgorithm is the signing algorithm of the access tokens: HS256, RS256 or EdDSA.
+func (c *Config) JWTAlgorithm() string {
+	return c.jwtAlgorithm
+}
+
+// JWTSigningKey is the secret of HS256, the default development key is used when it is empty.
+func (c *Config) JWTSigningKey() string {
+	return c.jwtSigningKey
+}
+
+// JWTPrivateKeyFile is the PEM file of the RS256 or EdDSA private key.
+func (c *Config) JWTPrivateKeyFile() string {
+	return c.jwtPrivateKeyFile
+}
+
+// JWTKeyID is the kid of the signing key in the header of the access tokens.
+func (c *Config) JWTKeyID() string {
+	return c.jwtKeyID
+}
+
+// JWTVerificationKeys are the files of the previous keys by their kid, the access tokens signed
+// by them are still accepted while the keys are rotated.
+func (c *Config) JWTVerificationKeys() map[string]string {
+	return c.jwtVerificationKeys
+}
+
+func (c *Config) JWTIssuer() string {
+	return c.jwtIssuer
+}
+
+func (c *Config) JWTAudience() string {
+	return c.jwtAudience
+}
+
+func (c *Config) JWTAccessTokenTTL() time.Duration {
+	return c.jwtAccessTokenTTL
+}
+
+func (c *Config) JWTRefreshTokenTTL() time.Duration {
+	return c.jwtRefreshTokenTTL
+}
+
 func (c *Config) LogLevel() string {
 	return c.logLevel
 }
@@ -87,5 +303,28 @@ func (c *Config) String() string {
 		" accrualAPIAddr: " + c.accrualAPIAddr +
 		" accrualGetOrder: " + c.accrualGetOrder +
 		" orderStatusUpdateInterval" + c.orderStatusUpdateInterval.String() +
+		" accrualWorkersCount: " + strconv.Itoa(c.accrualWorkersCount) +
+		" accrualRateLimit: " + strconv.Itoa(c.accrualRateLimit) +
+		" accrualPollBatchSize: " + strconv.Itoa(c.accrualPollBatchSize) +
+		" accrualMaxPollBackoff: " + c.accrualMaxPollBackoff.String() +
+		" accrualLeaseTTL: " + c.accrualLeaseTTL.String() +
+		" accrualFailureThreshold: " + strconv.Itoa(c.accrualFailureThreshold) +
+		" accrualRequestTimeout: " + c.accrualRequestTimeout.String() +
+		" accrualBreakerThreshold: " + strconv.Itoa(c.accrualBreakerThreshold) +
+		" accrualBreakerOpenTimeout: " + c.accrualBreakerOpenTimeout.String() +
+		" accrualNotRegisteredTTL: " + c.accrualNotRegisteredTTL.String() +
+		" pointsExpiryMonths: " + strconv.Itoa(c.pointsExpiryMonths) +
+		" pointsExpiryInterval: " + c.pointsExpiryInterval.String() +
+		" holdTTL: " + c.holdTTL.String() +
+		" holdReleaseInterval: " + c.holdReleaseInterval.String() +
+		" configFile: " + c.configFile +
+		" jwtAlgorithm: " + c.jwtAlgorithm +
+		" jwtPrivateKeyFile: " + c.jwtPrivateKeyFile +
+		" jwtKeyID: " + c.jwtKeyID +
+		" jwtVerificationKeys: " + c.jwtVerificationKeysList +
+		" jwtIssuer: " + c.jwtIssuer +
+		" jwtAudience: " + c.jwtAudience +
+		" jwtAccessTokenTTL: " + c.jwtAccessTokenTTL.String() +
+		" jwtRefreshTokenTTL: " + c.jwtRefreshTokenTTL.String() +
 		" logLevel" + c.LogLevel()
 }
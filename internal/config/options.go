@@ -3,4 +3,7 @@ package config
 const (
 	WithFlag = "withFlag"
 	WithEnv  = "withEnv"
+	// WithFile sets the options which are not configured by the flags and the env from the config file,
+	// so it goes after WithFlag and WithEnv.
+	WithFile = "withFile"
 )
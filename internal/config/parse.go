@@ -3,6 +3,8 @@ package config
 import (
 	"flag"
 	"fmt"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/caarlos0/env/v6"
@@ -14,20 +16,73 @@ func (c *Config) parseFromOsArgs() {
 	flag.StringVar(&c.pgConnString, "d", c.pgConnString, "database connection string")
 	flag.StringVar(&c.accrualAPIAddr, "r", c.accrualAPIAddr, "api accrual run address")
 	flag.DurationVar(&c.orderStatusUpdateInterval, "u", c.orderStatusUpdateInterval, "order status update interval")
+	flag.IntVar(&c.accrualWorkersCount, "w", c.accrualWorkersCount, "accrual workers count")
+	flag.IntVar(&c.accrualRateLimit, "rl", c.accrualRateLimit, "accrual requests per second limit")
+	flag.IntVar(&c.accrualPollBatchSize, "b", c.accrualPollBatchSize, "accrual poll batch size")
+	flag.DurationVar(&c.accrualMaxPollBackoff, "mb", c.accrualMaxPollBackoff, "accrual max poll backoff")
+	flag.DurationVar(&c.accrualLeaseTTL, "lt", c.accrualLeaseTTL, "accrual order lease ttl")
+	flag.IntVar(&c.accrualFailureThreshold, "ft", c.accrualFailureThreshold, "accrual consecutive failures threshold")
+	flag.DurationVar(&c.accrualRequestTimeout, "rt", c.accrualRequestTimeout, "accrual request timeout")
+	flag.IntVar(&c.accrualBreakerThreshold, "bt", c.accrualBreakerThreshold, "accrual circuit breaker failures threshold")
+	flag.DurationVar(&c.accrualBreakerOpenTimeout, "bo", c.accrualBreakerOpenTimeout, "accrual circuit breaker open timeout")
+	flag.DurationVar(&c.accrualNotRegisteredTTL, "nr", c.accrualNotRegisteredTTL, "accrual not registered order ttl")
+	flag.IntVar(&c.pointsExpiryMonths, "pe", c.pointsExpiryMonths, "accrued points expiry period in months, positive (the expiry cannot be disabled)")
+	flag.DurationVar(&c.pointsExpiryInterval, "pi", c.pointsExpiryInterval, "points expiry check interval")
+	flag.StringVar(&c.serviceToken, "st", c.serviceToken, "internal api service token")
+	flag.DurationVar(&c.holdTTL, "ht", c.holdTTL, "points hold ttl")
+	flag.DurationVar(&c.holdReleaseInterval, "hi", c.holdReleaseInterval, "expired points holds release interval")
 	flag.StringVar(&c.logLevel, "l", c.logLevel, "log level")
+	flag.StringVar(&c.configFile, "c", c.configFile, "config file")
+	flag.StringVar(&c.jwtAlgorithm, "ja", c.jwtAlgorithm, "jwt signing algorithm: HS256, RS256 or EdDSA")
+	flag.StringVar(&c.jwtSigningKey, "jk", c.jwtSigningKey, "jwt HS256 signing key")
+	flag.StringVar(&c.jwtPrivateKeyFile, "jpk", c.jwtPrivateKeyFile, "jwt RS256 or EdDSA private key PEM file")
+	flag.StringVar(&c.jwtKeyID, "jkid", c.jwtKeyID, "jwt signing key id")
+	flag.StringVar(&c.jwtVerificationKeysList, "jvk", c.jwtVerificationKeysList, "jwt previous verification keys as kid:file,kid:file")
+	flag.StringVar(&c.jwtIssuer, "ji", c.jwtIssuer, "jwt issuer")
+	flag.StringVar(&c.jwtAudience, "jau", c.jwtAudience, "jwt audience")
+	flag.DurationVar(&c.jwtAccessTokenTTL, "jat", c.jwtAccessTokenTTL, "jwt access token ttl")
+	flag.DurationVar(&c.jwtRefreshTokenTTL, "jrt", c.jwtRefreshTokenTTL, "refresh token ttl")
 
 	flag.Parse()
 }
 
+// configValues are the options of the config in the env and in the config file, the file has the same keys as the env.
+type configValues struct {
+	ServAPIAddr               string        `env:"RUN_ADDRESS" toml:"RUN_ADDRESS"`
+	PgConnString              string        `env:"DATABASE_URI" toml:"DATABASE_URI"`
+	AccrualAPIAddr            string        `env:"ACCRUAL_SYSTEM_ADDRESS" toml:"ACCRUAL_SYSTEM_ADDRESS"`
+	LogLevel                  string        `env:"LOG_LEVEL" toml:"LOG_LEVEL"`
+	OrderStatusUpdateInterval time.Duration `env:"ORDER_STATUS_UPDATE_INTERVAL" toml:"ORDER_STATUS_UPDATE_INTERVAL"`
+	AccrualWorkersCount       int           `env:"ACCRUAL_WORKERS_COUNT" toml:"ACCRUAL_WORKERS_COUNT"`
+	AccrualRateLimit          int           `env:"ACCRUAL_RATE_LIMIT" toml:"ACCRUAL_RATE_LIMIT"`
+	AccrualPollBatchSize      int           `env:"ACCRUAL_POLL_BATCH_SIZE" toml:"ACCRUAL_POLL_BATCH_SIZE"`
+	AccrualMaxPollBackoff     time.Duration `env:"ACCRUAL_MAX_POLL_BACKOFF" toml:"ACCRUAL_MAX_POLL_BACKOFF"`
+	AccrualLeaseTTL           time.Duration `env:"ACCRUAL_LEASE_TTL" toml:"ACCRUAL_LEASE_TTL"`
+	AccrualFailureThreshold   int           `env:"ACCRUAL_FAILURE_THRESHOLD" toml:"ACCRUAL_FAILURE_THRESHOLD"`
+	AccrualRequestTimeout     time.Duration `env:"ACCRUAL_REQUEST_TIMEOUT" toml:"ACCRUAL_REQUEST_TIMEOUT"`
+	AccrualBreakerThreshold   int           `env:"ACCRUAL_BREAKER_THRESHOLD" toml:"ACCRUAL_BREAKER_THRESHOLD"`
+	AccrualBreakerOpenTimeout time.Duration `env:"ACCRUAL_BREAKER_OPEN_TIMEOUT" toml:"ACCRUAL_BREAKER_OPEN_TIMEOUT"`
+	AccrualNotRegisteredTTL   time.Duration `env:"ACCRUAL_NOT_REGISTERED_TTL" toml:"ACCRUAL_NOT_REGISTERED_TTL"`
+	PointsExpiryMonths        int           `env:"POINTS_EXPIRY_MONTHS" toml:"POINTS_EXPIRY_MONTHS"`
+	PointsExpiryInterval      time.Duration `env:"POINTS_EXPIRY_INTERVAL" toml:"POINTS_EXPIRY_INTERVAL"`
+	ServiceToken              string        `env:"SERVICE_TOKEN" toml:"SERVICE_TOKEN"`
+	HoldTTL                   time.Duration `env:"HOLD_TTL" toml:"HOLD_TTL"`
+	HoldReleaseInterval       time.Duration `env:"HOLD_RELEASE_INTERVAL" toml:"HOLD_RELEASE_INTERVAL"`
+	ConfigFile                string        `env:"CONFIG" toml:"CONFIG"`
+	JWTAlgorithm              string        `env:"JWT_ALGORITHM" toml:"JWT_ALGORITHM"`
+	JWTSigningKey             string        `env:"JWT_SIGNING_KEY" toml:"JWT_SIGNING_KEY"`
+	JWTPrivateKeyFile         string        `env:"JWT_PRIVATE_KEY_FILE" toml:"JWT_PRIVATE_KEY_FILE"`
+	JWTKeyID                  string        `env:"JWT_KEY_ID" toml:"JWT_KEY_ID"`
+	JWTVerificationKeys       string        `env:"JWT_VERIFICATION_KEYS" toml:"JWT_VERIFICATION_KEYS"`
+	JWTIssuer                 string        `env:"JWT_ISSUER" toml:"JWT_ISSUER"`
+	JWTAudience               string        `env:"JWT_AUDIENCE" toml:"JWT_AUDIENCE"`
+	JWTAccessTokenTTL         time.Duration `env:"JWT_ACCESS_TOKEN_TTL" toml:"JWT_ACCESS_TOKEN_TTL"`
+	JWTRefreshTokenTTL        time.Duration `env:"JWT_REFRESH_TOKEN_TTL" toml:"JWT_REFRESH_TOKEN_TTL"`
+}
+
 func (c *Config) parseFromEnv() (err error) {
 
-	envConfig := struct {
-		ServAPIAddr               string        `env:"RUN_ADDRESS" toml:"RUN_ADDRESS"`
-		PgConnString              string        `env:"DATABASE_URI" toml:"DATABASE_URI"`
-		AccrualAPIAddr            string        `env:"ACCRUAL_SYSTEM_ADDRESS" toml:"ACCRUAL_SYSTEM_ADDRESS"`
-		LogLevel                  string        `env:"LOG_LEVEL" toml:"LOG_LEVEL"`
-		OrderStatusUpdateInterval time.Duration `env:"ORDER_STATUS_UPDATE_INTERVAL" toml:"ORDER_STATUS_UPDATE_INTERVAL"`
-	}{}
+	envConfig := configValues{}
 
 	if err = env.Parse(&envConfig); err != nil {
 		return fmt.Errorf(`parsing config from env: %w`, err)
@@ -49,9 +104,284 @@ func (c *Config) parseFromEnv() (err error) {
 		c.orderStatusUpdateInterval = envConfig.OrderStatusUpdateInterval
 	}
 
+	if envConfig.AccrualWorkersCount != 0 {
+		c.accrualWorkersCount = envConfig.AccrualWorkersCount
+	}
+
+	if envConfig.AccrualRateLimit != 0 {
+		c.accrualRateLimit = envConfig.AccrualRateLimit
+	}
+
+	if envConfig.AccrualPollBatchSize != 0 {
+		c.accrualPollBatchSize = envConfig.AccrualPollBatchSize
+	}
+
+	if envConfig.AccrualMaxPollBackoff != 0 {
+		c.accrualMaxPollBackoff = envConfig.AccrualMaxPollBackoff
+	}
+
+	if envConfig.AccrualLeaseTTL != 0 {
+		c.accrualLeaseTTL = envConfig.AccrualLeaseTTL
+	}
+
+	if envConfig.AccrualFailureThreshold != 0 {
+		c.accrualFailureThreshold = envConfig.AccrualFailureThreshold
+	}
+
+	if envConfig.AccrualRequestTimeout != 0 {
+		c.accrualRequestTimeout = envConfig.AccrualRequestTimeout
+	}
+
+	if envConfig.AccrualBreakerThreshold != 0 {
+		c.accrualBreakerThreshold = envConfig.AccrualBreakerThreshold
+	}
+
+	if envConfig.AccrualBreakerOpenTimeout != 0 {
+		c.accrualBreakerOpenTimeout = envConfig.AccrualBreakerOpenTimeout
+	}
+
+	if envConfig.AccrualNotRegisteredTTL != 0 {
+		c.accrualNotRegisteredTTL = envConfig.AccrualNotRegisteredTTL
+	}
+
+	if envConfig.PointsExpiryMonths != 0 {
+		c.pointsExpiryMonths = envConfig.PointsExpiryMonths
+	}
+
+	if envConfig.PointsExpiryInterval != 0 {
+		c.pointsExpiryInterval = envConfig.PointsExpiryInterval
+	}
+
+	if envConfig.ServiceToken != "" {
+		c.serviceToken = envConfig.ServiceToken
+	}
+
+	if envConfig.HoldTTL != 0 {
+		c.holdTTL = envConfig.HoldTTL
+	}
+
+	if envConfig.HoldReleaseInterval != 0 {
+		c.holdReleaseInterval = envConfig.HoldReleaseInterval
+	}
+
 	if envConfig.LogLevel != "" {
 		c.logLevel = envConfig.LogLevel
 	}
 
+	if envConfig.ConfigFile != "" {
+		c.configFile = envConfig.ConfigFile
+	}
+
+	if envConfig.JWTAlgorithm != "" {
+		c.jwtAlgorithm = envConfig.JWTAlgorithm
+	}
+
+	if envConfig.JWTSigningKey != "" {
+		c.jwtSigningKey = envConfig.JWTSigningKey
+	}
+
+	if envConfig.JWTPrivateKeyFile != "" {
+		c.jwtPrivateKeyFile = envConfig.JWTPrivateKeyFile
+	}
+
+	if envConfig.JWTKeyID != "" {
+		c.jwtKeyID = envConfig.JWTKeyID
+	}
+
+	if envConfig.JWTVerificationKeys != "" {
+		c.jwtVerificationKeysList = envConfig.JWTVerificationKeys
+	}
+
+	if envConfig.JWTIssuer != "" {
+		c.jwtIssuer = envConfig.JWTIssuer
+	}
+
+	if envConfig.JWTAudience != "" {
+		c.jwtAudience = envConfig.JWTAudience
+	}
+
+	if envConfig.JWTAccessTokenTTL != 0 {
+		c.jwtAccessTokenTTL = envConfig.JWTAccessTokenTTL
+	}
+
+	if envConfig.JWTRefreshTokenTTL != 0 {
+		c.jwtRefreshTokenTTL = envConfig.JWTRefreshTokenTTL
+	}
+
+	return nil
+}
+
+// parseFromFile sets the options which are not configured by the flags and the env from the config file.
+// The file has the same keys as the env, one KEY=VALUE per line, the empty lines and the lines
+// starting with # are skipped.
+func (c *Config) parseFromFile() (err error) {
+
+	if c.configFile == "" {
+		return nil
+	}
+
+	content, err := os.ReadFile(c.configFile)
+	if err != nil {
+		return fmt.Errorf(`reading config file: %w`, err)
+	}
+
+	environment := make(map[string]string)
+	for i, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf(`parsing config file: line %d: %w`, i+1, ErrInvalidConfigFileLine)
+		}
+		environment[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+
+	fileConfig := configValues{}
+
+	if err = env.Parse(&fileConfig, env.Options{Environment: environment}); err != nil {
+		return fmt.Errorf(`parsing config file: %w`, err)
+	}
+
+	c.setIfNotConfigured(&fileConfig)
+
 	return nil
 }
+
+func (c *Config) setIfNotConfigured(values *configValues) {
+
+	if c.servAPIAddr == "" {
+		c.servAPIAddr = values.ServAPIAddr
+	}
+
+	if c.pgConnString == "" {
+		c.pgConnString = values.PgConnString
+	}
+
+	if c.accrualAPIAddr == "" {
+		c.accrualAPIAddr = values.AccrualAPIAddr
+	}
+
+	if c.orderStatusUpdateInterval == 0 {
+		c.orderStatusUpdateInterval = values.OrderStatusUpdateInterval
+	}
+
+	if c.accrualWorkersCount == 0 {
+		c.accrualWorkersCount = values.AccrualWorkersCount
+	}
+
+	if c.accrualRateLimit == 0 {
+		c.accrualRateLimit = values.AccrualRateLimit
+	}
+
+	if c.accrualPollBatchSize == 0 {
+		c.accrualPollBatchSize = values.AccrualPollBatchSize
+	}
+
+	if c.accrualMaxPollBackoff == 0 {
+		c.accrualMaxPollBackoff = values.AccrualMaxPollBackoff
+	}
+
+	if c.accrualLeaseTTL == 0 {
+		c.accrualLeaseTTL = values.AccrualLeaseTTL
+	}
+
+	if c.accrualFailureThreshold == 0 {
+		c.accrualFailureThreshold = values.AccrualFailureThreshold
+	}
+
+	if c.accrualRequestTimeout == 0 {
+		c.accrualRequestTimeout = values.AccrualRequestTimeout
+	}
+
+	if c.accrualBreakerThreshold == 0 {
+		c.accrualBreakerThreshold = values.AccrualBreakerThreshold
+	}
+
+	if c.accrualBreakerOpenTimeout == 0 {
+		c.accrualBreakerOpenTimeout = values.AccrualBreakerOpenTimeout
+	}
+
+	if c.accrualNotRegisteredTTL == 0 {
+		c.accrualNotRegisteredTTL = values.AccrualNotRegisteredTTL
+	}
+
+	if c.pointsExpiryMonths == 0 {
+		c.pointsExpiryMonths = values.PointsExpiryMonths
+	}
+
+	if c.pointsExpiryInterval == 0 {
+		c.pointsExpiryInterval = values.PointsExpiryInterval
+	}
+
+	if c.serviceToken == "" {
+		c.serviceToken = values.ServiceToken
+	}
+
+	if c.holdTTL == 0 {
+		c.holdTTL = values.HoldTTL
+	}
+
+	if c.holdReleaseInterval == 0 {
+		c.holdReleaseInterval = values.HoldReleaseInterval
+	}
+
+	if c.logLevel == "" {
+		c.logLevel = values.LogLevel
+	}
+
+	if c.jwtAlgorithm == "" {
+		c.jwtAlgorithm = values.JWTAlgorithm
+	}
+
+	if c.jwtSigningKey == "" {
+		c.jwtSigningKey = values.JWTSigningKey
+	}
+
+	if c.jwtPrivateKeyFile == "" {
+		c.jwtPrivateKeyFile = values.JWTPrivateKeyFile
+	}
+
+	if c.jwtKeyID == "" {
+		c.jwtKeyID = values.JWTKeyID
+	}
+
+	if c.jwtVerificationKeysList == "" {
+		c.jwtVerificationKeysList = values.JWTVerificationKeys
+	}
+
+	if c.jwtIssuer == "" {
+		c.jwtIssuer = values.JWTIssuer
+	}
+
+	if c.jwtAudience == "" {
+		c.jwtAudience = values.JWTAudience
+	}
+
+	if c.jwtAccessTokenTTL == 0 {
+		c.jwtAccessTokenTTL = values.JWTAccessTokenTTL
+	}
+
+	if c.jwtRefreshTokenTTL == 0 {
+		c.jwtRefreshTokenTTL = values.JWTRefreshTokenTTL
+	}
+}
+
+// parseKeyFiles parses the list of the key files as kid:file,kid:file.
+func parseKeyFiles(list string) (map[string]string, error) {
+	keyFiles := make(map[string]string)
+	if list == "" {
+		return keyFiles, nil
+	}
+
+	for _, item := range strings.Split(list, ",") {
+		keyID, file, ok := strings.Cut(strings.TrimSpace(item), ":")
+		if !ok || keyID == "" || file == "" {
+			return nil, fmt.Errorf(`parsing jwt verification keys: %q: %w`, item, ErrInvalidKeyFiles)
+		}
+		keyFiles[keyID] = file
+	}
+
+	return keyFiles, nil
+}
@@ -0,0 +1,15 @@
+package expiry
+
+import (
+	"context"
+	"time"
+
+	"practicum-gophermart/internal/config"
+	"practicum-gophermart/internal/model"
+)
+
+type Application interface {
+	ExpirePointLots(c context.Context, expiredAt time.Time, limit int) ([]model.PointLot, error)
+	ReleaseExpiredHolds(c context.Context, expiredAt time.Time, limit int) ([]model.Hold, error)
+	Config() *config.Config
+}
@@ -0,0 +1,155 @@
+package expiry
+
+//go:generate mockery --name Application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"practicum-gophermart/internal/model"
+)
+
+var (
+	ErrEmptyApplication    = errors.New("empty application")
+	ErrInvalidInterval     = errors.New("invalid points expiry interval")
+	ErrInvalidExpiryMonths = errors.New("invalid points expiry period")
+	ErrInvalidHoldInterval = errors.New("invalid expired holds release interval")
+)
+
+const defaultBatchSize = 100
+
+// Job expires the points which were accrued more than the configured number of months ago
+// and releases the points holds which were neither captured nor released in time.
+// Lots and holds are expired in batches, so several instances of the service can run the job at once.
+type Job struct {
+	app          Application
+	interval     time.Duration
+	holdInterval time.Duration
+	batchSize    int
+}
+
+// New returns new Job.
+func New(application Application) (newJob *Job, err error) {
+	log.Debug().Msg("expiry.New START")
+	defer func() {
+		logMethodEnd("expiry.New", err)
+	}()
+
+	if application == nil {
+		return nil, ErrEmptyApplication
+	}
+
+	config := application.Config()
+
+	if config.PointsExpiryInterval() <= 0 {
+		return nil, ErrInvalidInterval
+	}
+	if config.PointsExpiryMonths() <= 0 {
+		return nil, ErrInvalidExpiryMonths
+	}
+	if config.HoldReleaseInterval() <= 0 {
+		return nil, ErrInvalidHoldInterval
+	}
+
+	newJob = &Job{
+		app:          application,
+		interval:     config.PointsExpiryInterval(),
+		holdInterval: config.HoldReleaseInterval(),
+		batchSize:    defaultBatchSize,
+	}
+
+	return newJob, nil
+}
+
+// Run starts expiring points and releasing expired holds and blocks until ctx is done.
+// Failed runs are logged and retried on the next tick.
+func (j *Job) Run(ctx context.Context) (err error) {
+	log.Debug().Msg("Job.Run START")
+	defer func() {
+		logMethodEnd("Job.Run", err)
+	}()
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	holdTicker := time.NewTicker(j.holdInterval)
+	defer holdTicker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if errExpiring := j.expirePoints(ctx); errExpiring != nil && ctx.Err() != nil {
+				return nil
+			}
+		case <-holdTicker.C:
+			if errReleasing := j.releaseHolds(ctx); errReleasing != nil && ctx.Err() != nil {
+				return nil
+			}
+		}
+	}
+}
+
+// expirePoints expires all the lots expired by now batch by batch.
+func (j *Job) expirePoints(ctx context.Context) (err error) {
+	log.Debug().Msg("Job.expirePoints START")
+	defer func() {
+		logMethodEnd("Job.expirePoints", err)
+	}()
+
+	expiredAt := time.Now()
+	for {
+		var lots []model.PointLot
+		lots, err = j.app.ExpirePointLots(ctx, expiredAt, j.batchSize)
+		if err != nil {
+			return fmt.Errorf("expiring point lots : %w", err)
+		}
+
+		for _, lot := range lots {
+			log.Info().Int64("user_id", lot.UserID).Str("order_number", lot.OrderNumber).
+				Str("expired", lot.Remaining.String()).Time("expires_at", lot.ExpiresAt).Msg("points expired")
+		}
+
+		if len(lots) < j.batchSize {
+			return nil
+		}
+	}
+}
+
+// releaseHolds releases all the holds expired by now batch by batch.
+func (j *Job) releaseHolds(ctx context.Context) (err error) {
+	log.Debug().Msg("Job.releaseHolds START")
+	defer func() {
+		logMethodEnd("Job.releaseHolds", err)
+	}()
+
+	expiredAt := time.Now()
+	for {
+		var holds []model.Hold
+		holds, err = j.app.ReleaseExpiredHolds(ctx, expiredAt, j.batchSize)
+		if err != nil {
+			return fmt.Errorf("releasing expired holds : %w", err)
+		}
+
+		for _, hold := range holds {
+			log.Info().Int64("user_id", hold.UserID).Str("order_number", hold.Order).
+				Str("released", hold.Sum.String()).Time("expires_at", hold.ExpiresAt).Msg("points hold expired")
+		}
+
+		if len(holds) < j.batchSize {
+			return nil
+		}
+	}
+}
+
+func logMethodEnd(method string, err error) {
+	msg := method + " END"
+	if err != nil {
+		log.Error().Err(err).Msg(msg)
+	} else {
+		log.Debug().Msg(msg)
+	}
+}
@@ -0,0 +1,137 @@
+package expiry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"practicum-gophermart/internal/config"
+	"practicum-gophermart/internal/expiry/mocks"
+	"practicum-gophermart/internal/model"
+)
+
+func TestNew(t *testing.T) {
+	testConfig, err := config.New()
+	assert.NoError(t, err)
+
+	testApp := &mocks.Application{}
+	testApp.On("Config").Return(testConfig)
+
+	j, err := New(testApp)
+	assert.NoError(t, err)
+	assert.Equal(t, testConfig.PointsExpiryInterval(), j.interval)
+	assert.Equal(t, testConfig.HoldReleaseInterval(), j.holdInterval)
+
+	_, err = New(nil)
+	assert.ErrorIs(t, err, ErrEmptyApplication)
+}
+
+func TestJob_expirePoints(t *testing.T) {
+	tests := []struct {
+		name         string
+		mockBehavior func(testApp *mocks.Application)
+		wantErr      bool
+	}{
+		{
+			name: "nothing to expire",
+			mockBehavior: func(testApp *mocks.Application) {
+				testApp.On("ExpirePointLots", mock.Anything, mock.AnythingOfType("time.Time"), 2).
+					Return(nil, nil).
+					Once()
+			},
+		},
+		{
+			name: "expires batches until the last one is not full",
+			mockBehavior: func(testApp *mocks.Application) {
+				testApp.On("ExpirePointLots", mock.Anything, mock.AnythingOfType("time.Time"), 2).
+					Return([]model.PointLot{{ID: 1, Remaining: 100}, {ID: 2, Remaining: 200}}, nil).
+					Once()
+				testApp.On("ExpirePointLots", mock.Anything, mock.AnythingOfType("time.Time"), 2).
+					Return([]model.PointLot{{ID: 3, Remaining: 300}}, nil).
+					Once()
+			},
+		},
+		{
+			name: "unexpected error",
+			mockBehavior: func(testApp *mocks.Application) {
+				testApp.On("ExpirePointLots", mock.Anything, mock.AnythingOfType("time.Time"), 2).
+					Return(nil, errors.New("unexpected error")).
+					Once()
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testApp := &mocks.Application{}
+			tt.mockBehavior(testApp)
+
+			j := Job{app: testApp, interval: time.Hour, batchSize: 2}
+			err := j.expirePoints(context.Background())
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			testApp.AssertExpectations(t)
+		})
+	}
+}
+
+func TestJob_releaseHolds(t *testing.T) {
+	tests := []struct {
+		name         string
+		mockBehavior func(testApp *mocks.Application)
+		wantErr      bool
+	}{
+		{
+			name: "nothing to release",
+			mockBehavior: func(testApp *mocks.Application) {
+				testApp.On("ReleaseExpiredHolds", mock.Anything, mock.AnythingOfType("time.Time"), 2).
+					Return(nil, nil).
+					Once()
+			},
+		},
+		{
+			name: "releases batches until the last one is not full",
+			mockBehavior: func(testApp *mocks.Application) {
+				testApp.On("ReleaseExpiredHolds", mock.Anything, mock.AnythingOfType("time.Time"), 2).
+					Return([]model.Hold{{Order: "12345678903", Sum: 100}, {Order: "9278923470", Sum: 200}}, nil).
+					Once()
+				testApp.On("ReleaseExpiredHolds", mock.Anything, mock.AnythingOfType("time.Time"), 2).
+					Return([]model.Hold{{Order: "346436439", Sum: 300}}, nil).
+					Once()
+			},
+		},
+		{
+			name: "unexpected error",
+			mockBehavior: func(testApp *mocks.Application) {
+				testApp.On("ReleaseExpiredHolds", mock.Anything, mock.AnythingOfType("time.Time"), 2).
+					Return(nil, errors.New("unexpected error")).
+					Once()
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testApp := &mocks.Application{}
+			tt.mockBehavior(testApp)
+
+			j := Job{app: testApp, holdInterval: time.Minute, batchSize: 2}
+			err := j.releaseHolds(context.Background())
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			testApp.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,95 @@
+// Code generated by mockery v2.14.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	config "practicum-gophermart/internal/config"
+
+	model "practicum-gophermart/internal/model"
+	time "time"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// Application is an autogenerated mock type for the Application type
+type Application struct {
+	mock.Mock
+}
+
+// Config provides a mock function with given fields:
+func (_m *Application) Config() *config.Config {
+	ret := _m.Called()
+
+	var r0 *config.Config
+	if rf, ok := ret.Get(0).(func() *config.Config); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*config.Config)
+		}
+	}
+
+	return r0
+}
+
+// ExpirePointLots provides a mock function with given fields: c, expiredAt, limit
+func (_m *Application) ExpirePointLots(c context.Context, expiredAt time.Time, limit int) ([]model.PointLot, error) {
+	ret := _m.Called(c, expiredAt, limit)
+
+	var r0 []model.PointLot
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, int) []model.PointLot); ok {
+		r0 = rf(c, expiredAt, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.PointLot)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time, int) error); ok {
+		r1 = rf(c, expiredAt, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ReleaseExpiredHolds provides a mock function with given fields: c, expiredAt, limit
+func (_m *Application) ReleaseExpiredHolds(c context.Context, expiredAt time.Time, limit int) ([]model.Hold, error) {
+	ret := _m.Called(c, expiredAt, limit)
+
+	var r0 []model.Hold
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, int) []model.Hold); ok {
+		r0 = rf(c, expiredAt, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Hold)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time, int) error); ok {
+		r1 = rf(c, expiredAt, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+type mockConstructorTestingTNewApplication interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+// NewApplication creates a new instance of Application. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+func NewApplication(t mockConstructorTestingTNewApplication) *Application {
+	mock := &Application{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+// BalanceOperation is a credit or a debit of the user balance.
+// Amount is positive for credits and negative for debits, Balance is the balance after the operation.
+type BalanceOperation struct {
+	ProcessedAt time.Time `json:"processed_at"`
+	Type        string    `json:"type"`
+	Order       string    `json:"order"`
+	Amount      Money     `json:"amount"`
+	Balance     Money     `json:"balance"`
+}
+
+type BalanceOperationType int
+
+const (
+	BalanceOperationAccrual BalanceOperationType = iota
+	BalanceOperationWithdrawal
+	BalanceOperationExpiry
+	BalanceOperationReversal
+	BalanceOperationAdjustment
+)
+
+func (t BalanceOperationType) String() string {
+	return [...]string{"ACCRUAL", "WITHDRAWAL", "EXPIRY", "REVERSAL", "ADJUSTMENT"}[t]
+}
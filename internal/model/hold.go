@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+// Hold is the points reserved for the order while its payment is pending.
+// The held points are not available for withdrawals until the hold is released, captured or expires.
+type Hold struct {
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Order     string    `json:"order"`
+	Status    string    `json:"status"`
+	Sum       Money     `json:"sum"`
+	UserID    int64     `json:"-"`
+}
+
+type HoldStatus int
+
+const (
+	HoldStatusHeld HoldStatus = iota
+	HoldStatusCaptured
+	HoldStatusReleased
+	HoldStatusExpired
+)
+
+func (s HoldStatus) String() string {
+	return [...]string{"HELD", "CAPTURED", "RELEASED", "EXPIRED"}[s]
+}
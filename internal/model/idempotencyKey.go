@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// IdempotencyKey is the key the client sent with a request which must not be applied twice,
+// the response to the request is replayed for retries with the same key.
+// ResponseCode is zero while the request is in progress.
+type IdempotencyKey struct {
+	CreatedAt    time.Time
+	Key          string
+	RequestHash  string
+	ResponseBody []byte
+	UserID       int64
+	ResponseCode int
+}
@@ -0,0 +1,58 @@
+package model
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor is the position of the last returned row in a listing sorted by time and id.
+// It is passed to clients as an opaque string.
+type Cursor struct {
+	Time time.Time
+	ID   int64
+}
+
+func (c Cursor) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(c.Time.UnixNano(), 10) + ":" + strconv.FormatInt(c.ID, 10)))
+}
+
+// ParseCursor parses the cursor returned by Cursor.String.
+func ParseCursor(s string) (*Cursor, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	nanos, id, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+
+	cursor := Cursor{}
+	unixNano, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	cursor.Time = time.Unix(0, unixNano).UTC()
+
+	if cursor.ID, err = strconv.ParseInt(id, 10, 64); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &cursor, nil
+}
+
+// ListFilter selects the page of a listing.
+// Zero From and To don't limit the time range, empty Statuses don't limit the status, zero Limit returns all the rows.
+type ListFilter struct {
+	From     time.Time
+	To       time.Time
+	After    *Cursor
+	Statuses []string
+	Limit    int
+}
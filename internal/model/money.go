@@ -0,0 +1,142 @@
+package model
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidMoney = errors.New("invalid money amount")
+
+// Money is an amount of loyalty points in hundredths of a point.
+// It is marshalled to JSON as a decimal number, e.g. 729.98, and stored as numeric(20,2).
+type Money int64
+
+const moneyScale = 100
+
+// NewMoneyFromFloat returns the amount rounded to hundredths of a point.
+func NewMoneyFromFloat(f float64) Money {
+	return Money(math.Round(f * moneyScale))
+}
+
+// ParseMoney parses decimal amount like "729.98".
+// Digits after hundredths are rounded half away from zero.
+// The error reports the amount as it was given.
+func ParseMoney(value string) (Money, error) {
+	s := strings.TrimSpace(value)
+	if strings.ContainsAny(s, "eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidMoney, value)
+		}
+		return NewMoneyFromFloat(f), nil
+	}
+
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+
+	intPart, fracPart, hasPoint := strings.Cut(s, ".")
+	if intPart == "" || (hasPoint && fracPart == "") || !isDigits(intPart) || !isDigits(fracPart) {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidMoney, value)
+	}
+
+	units, err := strconv.ParseInt(intPart, 10, 64)
+	if err != nil || units > math.MaxInt64/moneyScale-1 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidMoney, value)
+	}
+
+	fracPart += "000"
+	hundredths, _ := strconv.ParseInt(fracPart[:2], 10, 64)
+	if fracPart[2] >= '5' {
+		hundredths++
+	}
+
+	m := Money(units*moneyScale + hundredths)
+	if negative {
+		m = -m
+	}
+	return m, nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the amount as a decimal number without trailing zeros, e.g. 729.98, 500.5 or 500.
+func (m Money) String() string {
+	sign := ""
+	abs := int64(m)
+	if abs < 0 {
+		sign = "-"
+		abs = -abs
+	}
+
+	units, hundredths := abs/moneyScale, abs%moneyScale
+	switch {
+	case hundredths == 0:
+		return sign + strconv.FormatInt(units, 10)
+	case hundredths%10 == 0:
+		return fmt.Sprintf("%s%d.%d", sign, units, hundredths/10)
+	default:
+		return fmt.Sprintf("%s%d.%02d", sign, units, hundredths)
+	}
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+
+	parsed, err := ParseMoney(strings.Trim(s, `"`))
+	if err != nil {
+		return err
+	}
+
+	*m = parsed
+	return nil
+}
+
+// Value stores the amount as a decimal string, so numeric columns keep it exact.
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+func (m *Money) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*m = 0
+	case int64:
+		*m = Money(v * moneyScale)
+	case float64:
+		*m = NewMoneyFromFloat(v)
+	case []byte:
+		return m.scanString(string(v))
+	case string:
+		return m.scanString(v)
+	default:
+		return fmt.Errorf("%w: unsupported type %T", ErrInvalidMoney, src)
+	}
+	return nil
+}
+
+func (m *Money) scanString(s string) error {
+	parsed, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
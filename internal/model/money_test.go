@@ -0,0 +1,86 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected Money
+		wantErr  bool
+	}{
+		{name: "integer", value: "500", expected: 50000},
+		{name: "hundredths", value: "729.98", expected: 72998},
+		{name: "tenths", value: "0.1", expected: 10},
+		{name: "rounded down", value: "1.004", expected: 100},
+		{name: "rounded up", value: "1.005", expected: 101},
+		{name: "rounded up to units", value: "0.995", expected: 100},
+		{name: "negative", value: "-12.3", expected: -1230},
+		{name: "exponent", value: "1.5e2", expected: 15000},
+		{name: "empty", value: "", wantErr: true},
+		{name: "not a number", value: "12,3", wantErr: true},
+		{name: "no fractional digits", value: "5.", wantErr: true},
+		{name: "no integer digits", value: ".5", wantErr: true},
+		{name: "negative with too many points", value: "-1.2.3", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := ParseMoney(tt.value)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidMoney)
+				assert.ErrorContains(t, err, fmt.Sprintf("%q", tt.value))
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, m)
+			}
+		})
+	}
+}
+
+func TestMoney_String(t *testing.T) {
+	assert.Equal(t, "500", Money(50000).String())
+	assert.Equal(t, "729.98", Money(72998).String())
+	assert.Equal(t, "0.5", Money(50).String())
+	assert.Equal(t, "0.05", Money(5).String())
+	assert.Equal(t, "-12.3", Money(-1230).String())
+}
+
+func TestMoney_JSON(t *testing.T) {
+	withdraw := struct {
+		Sum Money `json:"sum"`
+	}{}
+
+	assert.NoError(t, json.Unmarshal([]byte(`{"sum": 751.1}`), &withdraw))
+	assert.Equal(t, Money(75110), withdraw.Sum)
+
+	data, err := json.Marshal(withdraw)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"sum": 751.1}`, string(data))
+}
+
+func TestMoney_Scan(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      any
+		expected Money
+	}{
+		{name: "numeric as string", src: "729.98", expected: 72998},
+		{name: "numeric as bytes", src: []byte("0.10"), expected: 10},
+		{name: "double precision", src: 0.1 + 0.2, expected: 30},
+		{name: "integer", src: int64(5), expected: 500},
+		{name: "null", src: nil, expected: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := Money(1)
+			assert.NoError(t, m.Scan(tt.src))
+			assert.Equal(t, tt.expected, m)
+		})
+	}
+}
@@ -3,11 +3,15 @@ package model
 import "time"
 
 type Order struct {
-	UploadedAt time.Time `json:"uploaded_at"`
-	Number     string    `json:"number"`
-	Status     string    `json:"status"`
-	Accrual    float64   `json:"accrual"`
-	UserID     int64     `json:"-"`
+	UploadedAt   time.Time `json:"uploaded_at"`
+	LastPolledAt time.Time `json:"-"`
+	NextPollAt   time.Time `json:"-"`
+	Number       string    `json:"number"`
+	Status       string    `json:"status"`
+	PollResult   string    `json:"-"`
+	Accrual      Money     `json:"accrual"`
+	UserID       int64     `json:"-"`
+	Attempts     int       `json:"-"`
 }
 
 type OrderStatus int
@@ -22,3 +26,18 @@ const (
 func (o OrderStatus) String() string {
 	return [...]string{"NEW", "PROCESSING", "INVALID", "PROCESSED"}[o]
 }
+
+// OrderPollResult is the outcome of the last poll of the order in the accrual system.
+type OrderPollResult int
+
+const (
+	OrderPollResultFound OrderPollResult = iota
+	OrderPollResultNotRegistered
+	OrderPollResultAccrualUnavailable
+	OrderPollResultError
+	OrderPollResultSkipped
+)
+
+func (r OrderPollResult) String() string {
+	return [...]string{"FOUND", "NOT_REGISTERED", "ACCRUAL_UNAVAILABLE", "ERROR", "SKIPPED"}[r]
+}
@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// PointLot is the points accrued for one order.
+// Withdrawals consume the remainder of the oldest lots first, the remainder left by ExpiresAt expires.
+type PointLot struct {
+	AccruedAt   time.Time `json:"accrued_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	OrderNumber string    `json:"order"`
+	Amount      Money     `json:"amount"`
+	Remaining   Money     `json:"remaining"`
+	ID          int64     `json:"id"`
+	UserID      int64     `json:"-"`
+}
@@ -0,0 +1,100 @@
+package model
+
+import "time"
+
+// Posting is an entry of the append-only points ledger.
+// Amount moves from the debit account to the credit account and is always positive.
+type Posting struct {
+	CreatedAt     time.Time `json:"created_at"`
+	Kind          string    `json:"kind"`
+	DebitAccount  string    `json:"debit_account"`
+	CreditAccount string    `json:"credit_account"`
+	OrderNumber   string    `json:"order,omitempty"`
+	Amount        Money     `json:"amount"`
+	ID            int64     `json:"id"`
+	UserID        int64     `json:"-"`
+	ReversesID    int64     `json:"reverses_id,omitempty"`
+}
+
+type PostingKind int
+
+const (
+	PostingKindAccrual PostingKind = iota
+	PostingKindWithdrawal
+	PostingKindAdjustment
+	PostingKindReversal
+	PostingKindExpiry
+)
+
+func (k PostingKind) String() string {
+	return [...]string{"ACCRUAL", "WITHDRAWAL", "ADJUSTMENT", "REVERSAL", "EXPIRY"}[k]
+}
+
+// LedgerAccount is an account of the ledger.
+// LedgerAccountUser is the points balance of the user, the others are the counterparties of its postings.
+type LedgerAccount int
+
+const (
+	LedgerAccountUser LedgerAccount = iota
+	LedgerAccountAccruals
+	LedgerAccountWithdrawals
+	LedgerAccountAdjustments
+	LedgerAccountExpired
+)
+
+func (a LedgerAccount) String() string {
+	return [...]string{"USER", "ACCRUALS", "WITHDRAWALS", "ADJUSTMENTS", "EXPIRED"}[a]
+}
+
+// NewAccrualPosting returns posting crediting the user with the accrual for the order.
+func NewAccrualPosting(userID int64, orderNumber string, amount Money, createdAt time.Time) Posting {
+	return Posting{
+		UserID:        userID,
+		Kind:          PostingKindAccrual.String(),
+		DebitAccount:  LedgerAccountAccruals.String(),
+		CreditAccount: LedgerAccountUser.String(),
+		OrderNumber:   orderNumber,
+		Amount:        amount,
+		CreatedAt:     createdAt,
+	}
+}
+
+// NewWithdrawalPosting returns posting debiting the user with the points withdrawn for the order.
+func NewWithdrawalPosting(userID int64, orderNumber string, amount Money, createdAt time.Time) Posting {
+	return Posting{
+		UserID:        userID,
+		Kind:          PostingKindWithdrawal.String(),
+		DebitAccount:  LedgerAccountUser.String(),
+		CreditAccount: LedgerAccountWithdrawals.String(),
+		OrderNumber:   orderNumber,
+		Amount:        amount,
+		CreatedAt:     createdAt,
+	}
+}
+
+// NewExpiryPosting returns posting debiting the user with the expired remainder of the points accrued for the order.
+func NewExpiryPosting(userID int64, orderNumber string, amount Money, createdAt time.Time) Posting {
+	return Posting{
+		UserID:        userID,
+		Kind:          PostingKindExpiry.String(),
+		DebitAccount:  LedgerAccountUser.String(),
+		CreditAccount: LedgerAccountExpired.String(),
+		OrderNumber:   orderNumber,
+		Amount:        amount,
+		CreatedAt:     createdAt,
+	}
+}
+
+// NewWithdrawalReversalPosting returns posting crediting the user back with the points of the cancelled withdrawal.
+func NewWithdrawalReversalPosting(userID int64, orderNumber string, amount Money, reversesID int64, createdAt time.Time) Posting {
+	return Posting{
+		UserID:        userID,
+		Kind:          PostingKindReversal.String(),
+		DebitAccount:  LedgerAccountWithdrawals.String(),
+		CreditAccount: LedgerAccountUser.String(),
+		OrderNumber:   orderNumber,
+		Amount:        amount,
+		ReversesID:    reversesID,
+		CreatedAt:     createdAt,
+	}
+}
@@ -2,8 +2,14 @@ package model
 
 import "time"
 
+// RefreshSession is the session of the refresh token.
+// The sessions rotated one from another belong to the same family, the user agent and the ip
+// are of the device which has signed in or rotated the token.
 type RefreshSession struct {
 	ExpiresIn time.Time
+	FamilyID  string
 	Token     string
+	UserAgent string
+	IP        string
 	UserID    int64
 }
@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// Session is the sign in of the user on a device.
+// The session lasts while its refresh tokens are rotated one from another, so the session is the token family.
+type Session struct {
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	UserID     int64     `json:"-"`
+}
@@ -1,7 +1,6 @@
 package model
 
 import (
-	"fmt"
 	"strings"
 	"time"
 
@@ -11,7 +10,19 @@ import (
 type Withdraw struct {
 	ProcessedAt time.Time `json:"processed_at"`
 	Order       string    `json:"order"`
-	Sum         float64   `json:"sum"`
+	Status      string    `json:"status,omitempty"`
+	Sum         Money     `json:"sum"`
+}
+
+type WithdrawStatus int
+
+const (
+	WithdrawStatusProcessed WithdrawStatus = iota
+	WithdrawStatusCancelled
+)
+
+func (s WithdrawStatus) String() string {
+	return [...]string{"PROCESSED", "CANCELLED"}[s]
 }
 
 func (w Withdraw) MarshalJSON() ([]byte, error) {
@@ -19,6 +30,10 @@ func (w Withdraw) MarshalJSON() ([]byte, error) {
 	defer log.Debug().Msg("w.MarshalJSON END")
 
 	var strJSONWithdraw strings.Builder
-	strJSONWithdraw.WriteString("{\"order\": \"" + w.Order + "\", \"sum\": " + fmt.Sprint(w.Sum) + ", \"processed_at\": \"" + w.ProcessedAt.Format(time.RFC3339) + "\"}")
+	strJSONWithdraw.WriteString("{\"order\": \"" + w.Order + "\", \"sum\": " + w.Sum.String() + ", \"processed_at\": \"" + w.ProcessedAt.Format(time.RFC3339) + "\"")
+	if w.Status != "" {
+		strJSONWithdraw.WriteString(", \"status\": \"" + w.Status + "\"")
+	}
+	strJSONWithdraw.WriteString("}")
 	return []byte(strJSONWithdraw.String()), nil
 }
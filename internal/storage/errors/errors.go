@@ -9,6 +9,8 @@ var (
 
 var (
 	ErrRefreshSessionIsNotExists = errors.New("refresh session is not exists")
+	ErrRefreshTokenIsReused      = errors.New("refresh token is already rotated")
+	ErrSessionIsNotExists        = errors.New("session is not exists")
 )
 
 var (
@@ -20,3 +22,15 @@ var (
 var (
 	ErrNegativeBalance = errors.New("negative balance")
 )
+
+var (
+	ErrWithdrawalIsNotExists        = errors.New("withdrawal is not exist")
+	ErrWithdrawalIsAlreadyCancelled = errors.New("withdrawal is already cancelled")
+	ErrWithdrawalAlreadyExists      = errors.New("withdrawal for the order already exists")
+)
+
+var (
+	ErrHoldIsNotExists   = errors.New("hold is not exist")
+	ErrHoldIsNotActive   = errors.New("hold is captured, released or expired")
+	ErrHoldAlreadyExists = errors.New("hold for the order already exists")
+)
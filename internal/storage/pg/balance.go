@@ -4,13 +4,19 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+
+	"github.com/rs/zerolog/log"
+
+	"practicum-gophermart/internal/model"
 )
 
 type balanceStmts struct {
 	stmtCreateStartingBalance *sql.Stmt
 	stmtGetBalance            *sql.Stmt
 	stmtIncreaseBalance       *sql.Stmt
-	stmtReduceBalance         *sql.Stmt
+	stmtExpireBalance         *sql.Stmt
+	stmtDebitBalance          *sql.Stmt
+	stmtGetBalanceHistory     *sql.Stmt
 }
 
 func prepareBalanceStmts(ctx context.Context, p *Pg) error {
@@ -31,7 +37,15 @@ func prepareBalanceStmts(ctx context.Context, p *Pg) error {
 		return err
 	}
 
-	if newBalanceStmts.stmtReduceBalance, err = p.db.PrepareContext(ctx, queryReduceBalance); err != nil {
+	if newBalanceStmts.stmtExpireBalance, err = p.db.PrepareContext(ctx, queryExpireBalance); err != nil {
+		return err
+	}
+
+	if newBalanceStmts.stmtDebitBalance, err = p.db.PrepareContext(ctx, queryDebitBalance); err != nil {
+		return err
+	}
+
+	if newBalanceStmts.stmtGetBalanceHistory, err = p.db.PrepareContext(ctx, queryGetBalanceHistory); err != nil {
 		return err
 	}
 
@@ -40,12 +54,63 @@ func prepareBalanceStmts(ctx context.Context, p *Pg) error {
 	return nil
 }
 
-func (p *Pg) GetBalance(ctx context.Context, userID int64) (balance, withdrawn float64, err error) {
-	err = p.balanceStmts.stmtGetBalance.QueryRowContext(ctx, userID).Scan(&balance, &withdrawn)
+// GetBalance returns the available balance of the user, the points held for the pending payments
+// and the sum of the processed withdrawals.
+func (p *Pg) GetBalance(ctx context.Context, userID int64) (balance, held, withdrawn model.Money, err error) {
+	err = p.balanceStmts.stmtGetBalance.QueryRowContext(ctx, userID).Scan(&balance, &held, &withdrawn)
+	if err != nil {
+		return -1, -1, -1, err
+	}
+	return balance, held, withdrawn, nil
+}
+
+// GetBalanceHistory returns the page of the ledger postings of the user selected by the filter in chronological order
+// with the balance after each of them and the cursor of the next page. The cursor is nil on the last page.
+func (p *Pg) GetBalanceHistory(ctx context.Context, userID int64, filter model.ListFilter) (history []model.BalanceOperation,
+	next *model.Cursor, err error) {
+	log.Debug().Msg("Pg.GetBalanceHistory START")
+	defer func() {
+		if err != nil {
+			log.Error().Err(err).Msg("Pg.GetBalanceHistory END")
+		} else {
+			log.Debug().Msg("Pg.GetBalanceHistory END")
+		}
+	}()
+
+	afterTime, afterID := cursorArgs(filter.After)
+	rows, err := p.balanceStmts.stmtGetBalanceHistory.QueryContext(ctx, userID, nullTime(filter.From), nullTime(filter.To),
+		afterTime, afterID, limitArg(filter.Limit))
 	if err != nil {
-		return -1, -1, err
+		return nil, nil, err
+	}
+	defer func() {
+		if errRowsClose := rows.Close(); errRowsClose != nil {
+			log.Error().Err(errRowsClose).Msg("closing sql rows")
+		}
+	}()
+
+	var last model.Cursor
+	rowsCount := 0
+	for rows.Next() {
+		rowsCount++
+		if filter.Limit > 0 && rowsCount > filter.Limit {
+			break
+		}
+
+		operation := model.BalanceOperation{}
+		if err = rows.Scan(&last.ID, &operation.Type, &operation.Order, &operation.Amount, &operation.Balance,
+			&operation.ProcessedAt); err != nil {
+			return nil, nil, err
+		}
+		last.Time = operation.ProcessedAt
+		history = append(history, operation)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
 	}
-	return balance, withdrawn, nil
+
+	return history, nextCursor(rowsCount, filter.Limit, last), nil
 }
 
 func (b *balanceStmts) Close() (err error) {
@@ -62,8 +127,16 @@ func (b *balanceStmts) Close() (err error) {
 		return fmt.Errorf("closing stmt 'IncreaseBalance' : %w", err)
 	}
 
-	if err = b.stmtReduceBalance.Close(); err != nil {
-		return fmt.Errorf("closing stmt 'ReduceBalance' : %w", err)
+	if err = b.stmtExpireBalance.Close(); err != nil {
+		return fmt.Errorf("closing stmt 'ExpireBalance' : %w", err)
+	}
+
+	if err = b.stmtDebitBalance.Close(); err != nil {
+		return fmt.Errorf("closing stmt 'DebitBalance' : %w", err)
+	}
+
+	if err = b.stmtGetBalanceHistory.Close(); err != nil {
+		return fmt.Errorf("closing stmt 'GetBalanceHistory' : %w", err)
 	}
 
 	return nil
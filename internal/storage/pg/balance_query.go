@@ -4,11 +4,12 @@ const queryCreateStartingBalance = `INSERT INTO balance (user_id, sum) VALUES ($
 
 const queryGetBalance = `
 SELECT
-	MAX(balance.sum) AS current,
+	MAX(GREATEST(balance.sum - balance.held, 0)) AS current,
+	MAX(balance.held) AS held,
 	SUM(COALESCE(withdrawals.sum, 0)) AS withdrawn
 FROM
 	balance LEFT JOIN withdrawals ON
-		balance.user_id = withdrawals.user_id
+		balance.user_id = withdrawals.user_id AND withdrawals.status = 'PROCESSED'
 WHERE
 	balance.user_id = $1
 GROUP BY
@@ -17,4 +18,56 @@ GROUP BY
 
 const queryIncreaseBalance = `UPDATE balance SET sum = sum + $2 WHERE user_id=$1`
 
-const queryReduceBalance = `UPDATE balance SET sum = sum - $2 WHERE user_id=$1 RETURNING sum`
+// queryExpireBalance reduces the balance by no more than $2 of its available part not held for the pending payments
+// and returns the reduced amount, so the expiry never takes the held points away from the captures.
+const queryExpireBalance = `
+WITH old AS (
+	SELECT user_id, LEAST($2, GREATEST(sum - held, 0)) AS expired
+	FROM balance
+	WHERE user_id = $1
+	FOR UPDATE
+)
+UPDATE balance SET sum = sum - old.expired
+FROM old
+WHERE balance.user_id = old.user_id
+RETURNING old.expired
+`
+
+// queryDebitBalance reduces the balance only if its available part not held for the pending payments covers the sum,
+// nothing is returned otherwise.
+// Concurrent debits of the user wait for the row lock and recheck the condition against the committed balance,
+// so the balance never goes negative.
+const queryDebitBalance = `UPDATE balance SET sum = sum - $2 WHERE user_id = $1 AND sum - held >= $2 RETURNING sum`
+
+// queryGetBalanceHistory returns the postings of the user balance in the ledger in chronological order:
+// accruals, withdrawals, their reversals, expiries and the adjustments of the migration to the ledger.
+// The postings crediting the user are positive, the debiting ones are negative.
+// The running balance is calculated over the whole history before the page after the cursor ($4, $5) is cut.
+// Null filters and limit are not applied.
+const queryGetBalanceHistory = `
+SELECT
+	id, type, order_number, amount, balance, processed_at
+FROM (
+	SELECT
+		id,
+		kind::varchar AS type,
+		COALESCE(order_number, '') AS order_number,
+		amount,
+		created_at AS processed_at,
+		SUM(amount) OVER (ORDER BY created_at, id ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW) AS balance
+	FROM (
+		SELECT
+			id, kind, order_number, created_at,
+			CASE WHEN credit_account = 'USER' THEN amount ELSE -amount END AS amount
+		FROM ledger
+		WHERE user_id = $1
+	) AS postings
+) AS history
+WHERE
+	($2::timestamp IS NULL OR processed_at >= $2)
+	AND ($3::timestamp IS NULL OR processed_at < $3)
+	AND ($4::timestamp IS NULL OR (processed_at, id) > ($4, $5))
+ORDER BY
+	processed_at, id
+LIMIT $6
+`
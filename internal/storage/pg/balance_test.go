@@ -2,13 +2,17 @@ package pg
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+
+	"practicum-gophermart/internal/model"
 )
 
 func TestPg_GetBalance(t *testing.T) {
@@ -32,8 +36,9 @@ func TestPg_GetBalance(t *testing.T) {
 		name              string
 		mockBehavior      func(userID int64)
 		userID            int64
-		expectedBalance   float64
-		expectedWithdrawn float64
+		expectedBalance   model.Money
+		expectedHeld      model.Money
+		expectedWithdrawn model.Money
 		err               string
 		wantErr           bool
 	}{
@@ -42,11 +47,12 @@ func TestPg_GetBalance(t *testing.T) {
 			mockBehavior: func(userID int64) {
 				mock.ExpectQuery(queryGetBalance).
 					WithArgs(userID).
-					WillReturnRows(sqlmock.NewRows([]string{"current", "withdrawn"}).AddRow(1.33, 11.44))
+					WillReturnRows(sqlmock.NewRows([]string{"current", "held", "withdrawn"}).AddRow("1.33", "0.50", "11.44"))
 			},
 			userID:            1,
-			expectedBalance:   1.33,
-			expectedWithdrawn: 11.44,
+			expectedBalance:   133,
+			expectedHeld:      50,
+			expectedWithdrawn: 1144,
 		},
 		{
 			name: "unexpected error",
@@ -64,13 +70,14 @@ func TestPg_GetBalance(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.mockBehavior(tt.userID)
 			gCtx, _ := gin.CreateTestContext(httptest.NewRecorder())
-			balance, withdrawn, err := testPg.GetBalance(gCtx, tt.userID)
+			balance, held, withdrawn, err := testPg.GetBalance(gCtx, tt.userID)
 			if tt.wantErr {
 				assert.Error(t, err)
 				assert.ErrorContains(t, err, tt.err)
 			} else {
 				assert.NoError(t, err)
 				assert.Equal(t, tt.expectedBalance, balance)
+				assert.Equal(t, tt.expectedHeld, held)
 				assert.Equal(t, tt.expectedWithdrawn, withdrawn)
 			}
 			if err = mock.ExpectationsWereMet(); err != nil {
@@ -79,3 +86,114 @@ func TestPg_GetBalance(t *testing.T) {
 		})
 	}
 }
+
+func TestPg_GetBalanceHistory(t *testing.T) {
+	testPg := Pg{}
+	testPg.balanceStmts = &balanceStmts{}
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	testPg.db = db
+	mock.ExpectPrepare(queryGetBalanceHistory)
+	if testPg.balanceStmts.stmtGetBalanceHistory, err = testPg.db.PrepareContext(context.Background(), queryGetBalanceHistory); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing create user statement", err)
+	}
+
+	historyColumns := []string{"id", "type", "order_number", "amount", "balance", "processed_at"}
+
+	tests := []struct {
+		name         string
+		mockBehavior func(userID int64)
+		userID       int64
+		filter       model.ListFilter
+		expected     []model.BalanceOperation
+		expectedNext *model.Cursor
+		err          string
+		wantErr      bool
+	}{
+		{
+			name: "OK",
+			mockBehavior: func(userID int64) {
+				mock.ExpectQuery(queryGetBalanceHistory).
+					WithArgs(userID, sql.NullTime{}, sql.NullTime{}, sql.NullTime{}, int64(0), 11).
+					WillReturnRows(sqlmock.NewRows(historyColumns).
+						AddRow(1, "ACCRUAL", "123", "500.00", "500.00", time.Unix(1, 0)).
+						AddRow(2, "WITHDRAWAL", "321", "-120.50", "379.50", time.Unix(2, 0)))
+			},
+			userID: 1,
+			filter: model.ListFilter{Limit: 10},
+			expected: []model.BalanceOperation{
+				{Type: "ACCRUAL", Order: "123", Amount: 50000, Balance: 50000, ProcessedAt: time.Unix(1, 0)},
+				{Type: "WITHDRAWAL", Order: "321", Amount: -12050, Balance: 37950, ProcessedAt: time.Unix(2, 0)},
+			},
+		},
+		{
+			name: "page with next page",
+			mockBehavior: func(userID int64) {
+				mock.ExpectQuery(queryGetBalanceHistory).
+					WithArgs(userID, sql.NullTime{Time: time.Unix(1, 0), Valid: true}, sql.NullTime{},
+						sql.NullTime{Time: time.Unix(1, 0), Valid: true}, int64(1), 2).
+					WillReturnRows(sqlmock.NewRows(historyColumns).
+						AddRow(2, "WITHDRAWAL", "321", "-120.50", "379.50", time.Unix(2, 0)).
+						AddRow(3, "ACCRUAL", "456", "100.00", "479.50", time.Unix(3, 0)))
+			},
+			userID: 1,
+			filter: model.ListFilter{Limit: 1, From: time.Unix(1, 0), After: &model.Cursor{Time: time.Unix(1, 0), ID: 1}},
+			expected: []model.BalanceOperation{
+				{Type: "WITHDRAWAL", Order: "321", Amount: -12050, Balance: 37950, ProcessedAt: time.Unix(2, 0)},
+			},
+			expectedNext: &model.Cursor{Time: time.Unix(2, 0), ID: 2},
+		},
+		{
+			name: "migrated adjustment",
+			mockBehavior: func(userID int64) {
+				mock.ExpectQuery(queryGetBalanceHistory).
+					WithArgs(userID, sql.NullTime{}, sql.NullTime{}, sql.NullTime{}, int64(0), 11).
+					WillReturnRows(sqlmock.NewRows(historyColumns).
+						AddRow(1, "ACCRUAL", "123", "500.00", "500.00", time.Unix(1, 0)).
+						AddRow(2, "ADJUSTMENT", "", "-20.00", "480.00", time.Unix(2, 0)).
+						AddRow(3, "ACCRUAL", "456", "100.00", "580.00", time.Unix(3, 0)))
+			},
+			userID: 1,
+			filter: model.ListFilter{Limit: 10},
+			expected: []model.BalanceOperation{
+				{Type: "ACCRUAL", Order: "123", Amount: 50000, Balance: 50000, ProcessedAt: time.Unix(1, 0)},
+				{Type: "ADJUSTMENT", Amount: -2000, Balance: 48000, ProcessedAt: time.Unix(2, 0)},
+				{Type: "ACCRUAL", Order: "456", Amount: 10000, Balance: 58000, ProcessedAt: time.Unix(3, 0)},
+			},
+		},
+		{
+			name: "unexpected error",
+			mockBehavior: func(userID int64) {
+				mock.ExpectQuery(queryGetBalanceHistory).
+					WithArgs(userID, sql.NullTime{}, sql.NullTime{}, sql.NullTime{}, int64(0), 11).
+					WillReturnError(errors.New("unexpected error"))
+			},
+			userID:  1,
+			filter:  model.ListFilter{Limit: 10},
+			err:     "unexpected error",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockBehavior(tt.userID)
+			history, next, err := testPg.GetBalanceHistory(context.Background(), tt.userID, tt.filter)
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.ErrorContains(t, err, tt.err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, history)
+				assert.Equal(t, tt.expectedNext, next)
+			}
+			if err = mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
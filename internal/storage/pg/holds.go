@@ -0,0 +1,365 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/rs/zerolog/log"
+
+	"practicum-gophermart/internal/model"
+	dberr "practicum-gophermart/internal/storage/errors"
+)
+
+// holdsStmts are statements of the points held for the pending payments.
+// The sum of the active holds of the user equals the held sum of the user balance, so the holds are changed
+// in the same transaction as the balance.
+type holdsStmts struct {
+	stmtAddHold            *sql.Stmt
+	stmtHoldBalance        *sql.Stmt
+	stmtReleaseHeldBalance *sql.Stmt
+	stmtCaptureHeldBalance *sql.Stmt
+	stmtCloseHold          *sql.Stmt
+	stmtGetHoldStatus      *sql.Stmt
+	stmtExpireHolds        *sql.Stmt
+}
+
+func prepareHoldsStmts(ctx context.Context, p *Pg) error {
+
+	newHoldsStmts := holdsStmts{}
+
+	var err error
+
+	if newHoldsStmts.stmtAddHold, err = p.db.PrepareContext(ctx, queryAddHold); err != nil {
+		return err
+	}
+
+	if newHoldsStmts.stmtHoldBalance, err = p.db.PrepareContext(ctx, queryHoldBalance); err != nil {
+		return err
+	}
+
+	if newHoldsStmts.stmtReleaseHeldBalance, err = p.db.PrepareContext(ctx, queryReleaseHeldBalance); err != nil {
+		return err
+	}
+
+	if newHoldsStmts.stmtCaptureHeldBalance, err = p.db.PrepareContext(ctx, queryCaptureHeldBalance); err != nil {
+		return err
+	}
+
+	if newHoldsStmts.stmtCloseHold, err = p.db.PrepareContext(ctx, queryCloseHold); err != nil {
+		return err
+	}
+
+	if newHoldsStmts.stmtGetHoldStatus, err = p.db.PrepareContext(ctx, queryGetHoldStatus); err != nil {
+		return err
+	}
+
+	if newHoldsStmts.stmtExpireHolds, err = p.db.PrepareContext(ctx, queryExpireHolds); err != nil {
+		return err
+	}
+
+	p.holdsStmts = &newHoldsStmts
+
+	return nil
+}
+
+// AddHold holds the sum of the available balance of the user for the order.
+// The sum is held only if the available balance covers it, otherwise nothing is changed and ErrNegativeBalance is returned.
+// The order can't be held if the points are already withdrawn for it or it has another active hold.
+func (p *Pg) AddHold(ctx context.Context, hold model.Hold) (err error) {
+	log.Debug().Msg("Pg.AddHold START")
+	defer func() {
+		if err != nil {
+			log.Error().Err(err).Msg("Pg.AddHold END")
+		} else {
+			log.Debug().Msg("Pg.AddHold END")
+		}
+	}()
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if errTxRollback := tx.Rollback(); errTxRollback != nil && !errors.Is(errTxRollback, sql.ErrTxDone) {
+			log.Error().Err(errTxRollback).Msg("tx rollback")
+		}
+	}()
+
+	result, err := tx.StmtContext(ctx, p.holdsStmts.stmtAddHold).ExecContext(ctx, hold.UserID, hold.Order, hold.Sum,
+		hold.CreatedAt, hold.ExpiresAt)
+	if err != nil {
+		if pgError, ok := err.(*pgconn.PgError); ok &&
+			pgError.Code == pgerrcode.UniqueViolation &&
+			pgError.ConstraintName == "holds_active_order_number_key" {
+			return fmt.Errorf(`pg: %w: %s`, dberr.ErrHoldAlreadyExists, err)
+		}
+		return err
+	}
+	added, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if added == 0 {
+		return dberr.ErrWithdrawalAlreadyExists
+	}
+
+	var held model.Money
+	err = tx.StmtContext(ctx, p.holdsStmts.stmtHoldBalance).QueryRowContext(ctx, hold.UserID, hold.Sum).Scan(&held)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = nil
+			return dberr.ErrNegativeBalance
+		}
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// CaptureHold withdraws the points held for the order from the balance of the user.
+// The withdrawal consumes the lots of accrued points and is posted to the ledger like any other withdrawal.
+// ErrNegativeBalance is returned if the balance does not cover the held points anymore, the hold stays active then.
+// If the points are withdrawn for the order after it was held, the hold is released
+// and ErrWithdrawalAlreadyExists is returned.
+func (p *Pg) CaptureHold(ctx context.Context, userID int64, orderNumber string, capturedAt time.Time) (
+	hold model.Hold, err error) {
+	log.Debug().Msg("Pg.CaptureHold START")
+	defer func() {
+		if err != nil {
+			log.Error().Err(err).Msg("Pg.CaptureHold END")
+		} else {
+			log.Debug().Msg("Pg.CaptureHold END")
+		}
+	}()
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Hold{}, err
+	}
+	defer func() {
+		if errTxRollback := tx.Rollback(); errTxRollback != nil && !errors.Is(errTxRollback, sql.ErrTxDone) {
+			log.Error().Err(errTxRollback).Msg("tx rollback")
+		}
+	}()
+
+	if hold, err = p.closeHold(ctx, tx, userID, orderNumber, model.HoldStatusCaptured, capturedAt); err != nil {
+		return model.Hold{}, err
+	}
+
+	err = p.addWithdrawal(ctx, tx, userID, model.Withdraw{Order: orderNumber, Sum: hold.Sum, ProcessedAt: capturedAt})
+	if err != nil {
+		if pgError, ok := err.(*pgconn.PgError); ok &&
+			pgError.Code == pgerrcode.UniqueViolation &&
+			pgError.ConstraintName == "withdrawals_order_number_key" {
+			return model.Hold{}, p.releaseWithdrawnHold(ctx, tx, userID, orderNumber, capturedAt)
+		}
+		return model.Hold{}, err
+	}
+
+	var newBalance model.Money
+	err = tx.StmtContext(ctx, p.holdsStmts.stmtCaptureHeldBalance).QueryRowContext(ctx, userID, hold.Sum).Scan(&newBalance)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = nil
+			return model.Hold{}, dberr.ErrNegativeBalance
+		}
+		return model.Hold{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return model.Hold{}, err
+	}
+
+	return hold, nil
+}
+
+// ReleaseHold returns the points held for the order to the available balance of the user.
+func (p *Pg) ReleaseHold(ctx context.Context, userID int64, orderNumber string, releasedAt time.Time) (
+	hold model.Hold, err error) {
+	log.Debug().Msg("Pg.ReleaseHold START")
+	defer func() {
+		if err != nil {
+			log.Error().Err(err).Msg("Pg.ReleaseHold END")
+		} else {
+			log.Debug().Msg("Pg.ReleaseHold END")
+		}
+	}()
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Hold{}, err
+	}
+	defer func() {
+		if errTxRollback := tx.Rollback(); errTxRollback != nil && !errors.Is(errTxRollback, sql.ErrTxDone) {
+			log.Error().Err(errTxRollback).Msg("tx rollback")
+		}
+	}()
+
+	if hold, err = p.closeHold(ctx, tx, userID, orderNumber, model.HoldStatusReleased, releasedAt); err != nil {
+		return model.Hold{}, err
+	}
+
+	if _, err = tx.StmtContext(ctx, p.holdsStmts.stmtReleaseHeldBalance).ExecContext(ctx, userID, hold.Sum); err != nil {
+		return model.Hold{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return model.Hold{}, err
+	}
+
+	return hold, nil
+}
+
+// ReleaseExpiredHolds releases no more than limit holds expired by expiredAt and returns them.
+func (p *Pg) ReleaseExpiredHolds(ctx context.Context, expiredAt time.Time, limit int) (holds []model.Hold, err error) {
+	log.Debug().Msg("Pg.ReleaseExpiredHolds START")
+	defer func() {
+		if err != nil {
+			log.Error().Err(err).Msg("Pg.ReleaseExpiredHolds END")
+		} else {
+			log.Debug().Msg("Pg.ReleaseExpiredHolds END")
+		}
+	}()
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if errTxRollback := tx.Rollback(); errTxRollback != nil && !errors.Is(errTxRollback, sql.ErrTxDone) {
+			log.Error().Err(errTxRollback).Msg("tx rollback")
+		}
+	}()
+
+	if holds, err = p.expireHolds(ctx, tx, expiredAt, limit); err != nil {
+		return nil, err
+	}
+
+	for _, hold := range holds {
+		if _, err = tx.StmtContext(ctx, p.holdsStmts.stmtReleaseHeldBalance).ExecContext(ctx, hold.UserID, hold.Sum); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return holds, nil
+}
+
+// releaseWithdrawnHold releases the hold of the order which points are already withdrawn,
+// the failed transaction of the capture is rolled back first.
+func (p *Pg) releaseWithdrawnHold(ctx context.Context, tx *sql.Tx, userID int64, orderNumber string, releasedAt time.Time) error {
+	if err := tx.Rollback(); err != nil {
+		return err
+	}
+
+	if _, err := p.ReleaseHold(ctx, userID, orderNumber, releasedAt); err != nil {
+		return err
+	}
+
+	return fmt.Errorf(`pg: %w`, dberr.ErrWithdrawalAlreadyExists)
+}
+
+// closeHold sets the status of the active hold of the user within the transaction.
+func (p *Pg) closeHold(ctx context.Context, tx *sql.Tx, userID int64, orderNumber string, status model.HoldStatus,
+	closedAt time.Time) (hold model.Hold, err error) {
+	err = tx.StmtContext(ctx, p.holdsStmts.stmtCloseHold).QueryRowContext(ctx, userID, orderNumber, status.String(), closedAt).
+		Scan(&hold.Sum, &hold.CreatedAt, &hold.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.Hold{}, p.holdNotClosedErr(ctx, tx, userID, orderNumber)
+		}
+		return model.Hold{}, err
+	}
+
+	hold.UserID = userID
+	hold.Order = orderNumber
+	hold.Status = status.String()
+
+	return hold, nil
+}
+
+// holdNotClosedErr explains why the hold of the user for the order was not closed.
+func (p *Pg) holdNotClosedErr(ctx context.Context, tx *sql.Tx, userID int64, orderNumber string) error {
+	var status string
+	err := tx.StmtContext(ctx, p.holdsStmts.stmtGetHoldStatus).QueryRowContext(ctx, userID, orderNumber).Scan(&status)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return dberr.ErrHoldIsNotExists
+		}
+		return err
+	}
+	return dberr.ErrHoldIsNotActive
+}
+
+// expireHolds closes the expired holds within the transaction.
+// The rows are read up before the balances are changed, the transaction runs one statement at a time.
+func (p *Pg) expireHolds(ctx context.Context, tx *sql.Tx, expiredAt time.Time, limit int) (holds []model.Hold, err error) {
+	rows, err := tx.StmtContext(ctx, p.holdsStmts.stmtExpireHolds).QueryContext(ctx, expiredAt, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if errRowsClose := rows.Close(); errRowsClose != nil {
+			log.Error().Err(errRowsClose).Msg("closing sql rows")
+		}
+	}()
+
+	for rows.Next() {
+		hold := model.Hold{Status: model.HoldStatusExpired.String()}
+		if err = rows.Scan(&hold.UserID, &hold.Order, &hold.Sum, &hold.CreatedAt, &hold.ExpiresAt); err != nil {
+			return nil, err
+		}
+		holds = append(holds, hold)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return holds, nil
+}
+
+func (h *holdsStmts) Close() (err error) {
+
+	if err = h.stmtAddHold.Close(); err != nil {
+		return fmt.Errorf("closing stmt 'AddHold' : %w", err)
+	}
+
+	if err = h.stmtHoldBalance.Close(); err != nil {
+		return fmt.Errorf("closing stmt 'HoldBalance' : %w", err)
+	}
+
+	if err = h.stmtReleaseHeldBalance.Close(); err != nil {
+		return fmt.Errorf("closing stmt 'ReleaseHeldBalance' : %w", err)
+	}
+
+	if err = h.stmtCaptureHeldBalance.Close(); err != nil {
+		return fmt.Errorf("closing stmt 'CaptureHeldBalance' : %w", err)
+	}
+
+	if err = h.stmtCloseHold.Close(); err != nil {
+		return fmt.Errorf("closing stmt 'CloseHold' : %w", err)
+	}
+
+	if err = h.stmtGetHoldStatus.Close(); err != nil {
+		return fmt.Errorf("closing stmt 'GetHoldStatus' : %w", err)
+	}
+
+	if err = h.stmtExpireHolds.Close(); err != nil {
+		return fmt.Errorf("closing stmt 'ExpireHolds' : %w", err)
+	}
+
+	return nil
+}
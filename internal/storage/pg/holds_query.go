@@ -0,0 +1,50 @@
+package pg
+
+// queryAddHold adds the hold only if the points are not withdrawn for the order yet, nothing is added otherwise.
+const queryAddHold = `
+INSERT INTO holds (user_id, order_number, sum, created_at, expires_at)
+SELECT $1, $2, $3, $4, $5
+WHERE NOT EXISTS (SELECT 1 FROM withdrawals WHERE order_number = $2)
+`
+
+// queryHoldBalance moves the sum to the held points only if the available balance covers it,
+// nothing is returned otherwise.
+const queryHoldBalance = `UPDATE balance SET held = held + $2 WHERE user_id = $1 AND sum - held >= $2 RETURNING held`
+
+const queryReleaseHeldBalance = `UPDATE balance SET held = held - $2 WHERE user_id = $1`
+
+// queryCaptureHeldBalance debits the held points from the balance only if the balance still covers them,
+// nothing is returned otherwise. The points expiry keeps the held points, the condition only guards the balance.
+const queryCaptureHeldBalance = `UPDATE balance SET sum = sum - $2, held = held - $2 WHERE user_id = $1 AND sum >= $2 RETURNING sum`
+
+// queryCloseHold closes the active hold of the user which is not expired yet and returns its sum.
+const queryCloseHold = `
+UPDATE holds SET
+	status = $3,
+	closed_at = $4
+WHERE
+	user_id = $1 AND order_number = $2 AND status = 'HELD' AND expires_at > $4
+RETURNING
+	sum, created_at, expires_at
+`
+
+// queryGetHoldStatus returns the status of the last hold of the user for the order.
+const queryGetHoldStatus = `SELECT status FROM holds WHERE user_id = $1 AND order_number = $2 ORDER BY id DESC LIMIT 1`
+
+// queryExpireHolds closes the batch of the expired active holds.
+// Holds locked by the concurrent capture or release are skipped.
+const queryExpireHolds = `
+UPDATE holds SET
+	status = 'EXPIRED',
+	closed_at = $1
+WHERE id IN (
+	SELECT id
+	FROM holds
+	WHERE status = 'HELD' AND expires_at <= $1
+	ORDER BY expires_at
+	LIMIT $2
+	FOR UPDATE SKIP LOCKED
+)
+RETURNING
+	user_id, order_number, sum, created_at, expires_at
+`
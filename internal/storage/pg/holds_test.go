@@ -0,0 +1,472 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/stretchr/testify/assert"
+
+	"practicum-gophermart/internal/model"
+	dberr "practicum-gophermart/internal/storage/errors"
+)
+
+func TestPg_AddHold(t *testing.T) {
+	testPg := Pg{}
+	testPg.holdsStmts = &holdsStmts{}
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	testPg.db = db
+	mock.ExpectPrepare(queryAddHold)
+	if testPg.holdsStmts.stmtAddHold, err = testPg.db.PrepareContext(context.Background(), queryAddHold); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing add hold statement", err)
+	}
+	mock.ExpectPrepare(queryHoldBalance)
+	if testPg.holdsStmts.stmtHoldBalance, err = testPg.db.PrepareContext(context.Background(), queryHoldBalance); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing hold balance statement", err)
+	}
+
+	createdAt := time.Now()
+	hold := model.Hold{UserID: 1, Order: "123", Sum: 11000, CreatedAt: createdAt, ExpiresAt: createdAt.Add(time.Minute)}
+
+	tests := []struct {
+		name         string
+		mockBehavior func()
+		err          error
+		wantErr      bool
+	}{
+		{
+			name: "OK",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(queryAddHold).
+					WithArgs(int64(1), "123", model.Money(11000), hold.CreatedAt, hold.ExpiresAt).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(queryHoldBalance).
+					WithArgs(int64(1), model.Money(11000)).
+					WillReturnRows(sqlmock.NewRows([]string{"held"}).AddRow("110"))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "hold already exists",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(queryAddHold).
+					WithArgs(int64(1), "123", model.Money(11000), hold.CreatedAt, hold.ExpiresAt).
+					WillReturnError(&pgconn.PgError{
+						Code:           pgerrcode.UniqueViolation,
+						ConstraintName: "holds_active_order_number_key",
+					})
+				mock.ExpectRollback()
+			},
+			err:     dberr.ErrHoldAlreadyExists,
+			wantErr: true,
+		},
+		{
+			name: "points are already withdrawn for the order",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(queryAddHold).
+					WithArgs(int64(1), "123", model.Money(11000), hold.CreatedAt, hold.ExpiresAt).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+			},
+			err:     dberr.ErrWithdrawalAlreadyExists,
+			wantErr: true,
+		},
+		{
+			name: "available balance is less than sum",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(queryAddHold).
+					WithArgs(int64(1), "123", model.Money(11000), hold.CreatedAt, hold.ExpiresAt).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(queryHoldBalance).
+					WithArgs(int64(1), model.Money(11000)).
+					WillReturnRows(sqlmock.NewRows([]string{"held"}))
+				mock.ExpectRollback()
+			},
+			err:     dberr.ErrNegativeBalance,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockBehavior()
+			err := testPg.AddHold(context.Background(), hold)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, tt.err)
+			} else {
+				assert.NoError(t, err)
+			}
+			if err = mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestPg_CaptureHold(t *testing.T) {
+	testPg := Pg{}
+	testPg.holdsStmts = &holdsStmts{}
+	testPg.withdrawalsStmts = &withdrawalsStmts{}
+	testPg.ledgerStmts = &ledgerStmts{}
+	testPg.pointLotsStmts = &pointLotsStmts{}
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	testPg.db = db
+	mock.ExpectPrepare(queryCloseHold)
+	if testPg.holdsStmts.stmtCloseHold, err = testPg.db.PrepareContext(context.Background(), queryCloseHold); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing close hold statement", err)
+	}
+	mock.ExpectPrepare(queryGetHoldStatus)
+	if testPg.holdsStmts.stmtGetHoldStatus, err = testPg.db.PrepareContext(context.Background(), queryGetHoldStatus); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing get hold status statement", err)
+	}
+	mock.ExpectPrepare(queryCaptureHeldBalance)
+	if testPg.holdsStmts.stmtCaptureHeldBalance, err = testPg.db.PrepareContext(context.Background(), queryCaptureHeldBalance); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing capture held balance statement", err)
+	}
+	mock.ExpectPrepare(queryReleaseHeldBalance)
+	if testPg.holdsStmts.stmtReleaseHeldBalance, err = testPg.db.PrepareContext(context.Background(), queryReleaseHeldBalance); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing release held balance statement", err)
+	}
+	mock.ExpectPrepare(queryAddWithdrawal)
+	if testPg.withdrawalsStmts.stmtAddWithdrawal, err = testPg.db.PrepareContext(context.Background(), queryAddWithdrawal); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing add withdrawal statement", err)
+	}
+	mock.ExpectPrepare(queryConsumePointLots)
+	if testPg.pointLotsStmts.stmtConsumePointLots, err = testPg.db.PrepareContext(context.Background(), queryConsumePointLots); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing consume point lots statement", err)
+	}
+	mock.ExpectPrepare(queryAddPosting)
+	if testPg.ledgerStmts.stmtAddPosting, err = testPg.db.PrepareContext(context.Background(), queryAddPosting); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing add posting statement", err)
+	}
+
+	capturedAt := time.Now()
+	createdAt := capturedAt.Add(-time.Minute)
+	expiresAt := createdAt.Add(time.Hour)
+
+	expectCapture := func() {
+		mock.ExpectBegin()
+		mock.ExpectQuery(queryCloseHold).
+			WithArgs(int64(1), "123", "CAPTURED", capturedAt).
+			WillReturnRows(sqlmock.NewRows([]string{"sum", "created_at", "expires_at"}).AddRow("110", createdAt, expiresAt))
+		mock.ExpectExec(queryAddWithdrawal).
+			WithArgs(int64(1), "123", model.Money(11000), capturedAt).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(queryConsumePointLots).
+			WithArgs(int64(1), model.Money(11000)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(queryAddPosting).
+			WithArgs(int64(1), "WITHDRAWAL", "USER", "WITHDRAWALS", model.Money(11000), "123", int64(0), capturedAt).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	}
+
+	tests := []struct {
+		name         string
+		mockBehavior func()
+		expected     model.Hold
+		err          error
+		wantErr      bool
+	}{
+		{
+			name: "OK",
+			mockBehavior: func() {
+				expectCapture()
+				mock.ExpectQuery(queryCaptureHeldBalance).
+					WithArgs(int64(1), model.Money(11000)).
+					WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow("0"))
+				mock.ExpectCommit()
+			},
+			expected: model.Hold{UserID: 1, Order: "123", Sum: 11000, Status: "CAPTURED", CreatedAt: createdAt, ExpiresAt: expiresAt},
+		},
+		{
+			name: "hold is not exists",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(queryCloseHold).
+					WithArgs(int64(1), "123", "CAPTURED", capturedAt).
+					WillReturnRows(sqlmock.NewRows([]string{"sum", "created_at", "expires_at"}))
+				mock.ExpectQuery(queryGetHoldStatus).
+					WithArgs(int64(1), "123").
+					WillReturnRows(sqlmock.NewRows([]string{"status"}))
+				mock.ExpectRollback()
+			},
+			err:     dberr.ErrHoldIsNotExists,
+			wantErr: true,
+		},
+		{
+			name: "hold is not active",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(queryCloseHold).
+					WithArgs(int64(1), "123", "CAPTURED", capturedAt).
+					WillReturnRows(sqlmock.NewRows([]string{"sum", "created_at", "expires_at"}))
+				mock.ExpectQuery(queryGetHoldStatus).
+					WithArgs(int64(1), "123").
+					WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("RELEASED"))
+				mock.ExpectRollback()
+			},
+			err:     dberr.ErrHoldIsNotActive,
+			wantErr: true,
+		},
+		{
+			name: "balance does not cover held points",
+			mockBehavior: func() {
+				expectCapture()
+				mock.ExpectQuery(queryCaptureHeldBalance).
+					WithArgs(int64(1), model.Money(11000)).
+					WillReturnRows(sqlmock.NewRows([]string{"sum"}))
+				mock.ExpectRollback()
+			},
+			err:     dberr.ErrNegativeBalance,
+			wantErr: true,
+		},
+		{
+			name: "points are already withdrawn for the order",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(queryCloseHold).
+					WithArgs(int64(1), "123", "CAPTURED", capturedAt).
+					WillReturnRows(sqlmock.NewRows([]string{"sum", "created_at", "expires_at"}).AddRow("110", createdAt, expiresAt))
+				mock.ExpectExec(queryAddWithdrawal).
+					WithArgs(int64(1), "123", model.Money(11000), capturedAt).
+					WillReturnError(&pgconn.PgError{
+						Code:           pgerrcode.UniqueViolation,
+						ConstraintName: "withdrawals_order_number_key",
+					})
+				mock.ExpectRollback()
+				mock.ExpectBegin()
+				mock.ExpectQuery(queryCloseHold).
+					WithArgs(int64(1), "123", "RELEASED", capturedAt).
+					WillReturnRows(sqlmock.NewRows([]string{"sum", "created_at", "expires_at"}).AddRow("110", createdAt, expiresAt))
+				mock.ExpectExec(queryReleaseHeldBalance).
+					WithArgs(int64(1), model.Money(11000)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			err:     dberr.ErrWithdrawalAlreadyExists,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockBehavior()
+			hold, err := testPg.CaptureHold(context.Background(), 1, "123", capturedAt)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, tt.err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, hold)
+			}
+			if err = mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestPg_ReleaseHold(t *testing.T) {
+	testPg := Pg{}
+	testPg.holdsStmts = &holdsStmts{}
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	testPg.db = db
+	mock.ExpectPrepare(queryCloseHold)
+	if testPg.holdsStmts.stmtCloseHold, err = testPg.db.PrepareContext(context.Background(), queryCloseHold); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing close hold statement", err)
+	}
+	mock.ExpectPrepare(queryGetHoldStatus)
+	if testPg.holdsStmts.stmtGetHoldStatus, err = testPg.db.PrepareContext(context.Background(), queryGetHoldStatus); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing get hold status statement", err)
+	}
+	mock.ExpectPrepare(queryReleaseHeldBalance)
+	if testPg.holdsStmts.stmtReleaseHeldBalance, err = testPg.db.PrepareContext(context.Background(), queryReleaseHeldBalance); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing release held balance statement", err)
+	}
+
+	releasedAt := time.Now()
+	createdAt := releasedAt.Add(-time.Minute)
+	expiresAt := createdAt.Add(time.Hour)
+
+	tests := []struct {
+		name         string
+		mockBehavior func()
+		expected     model.Hold
+		err          error
+		wantErr      bool
+	}{
+		{
+			name: "OK",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(queryCloseHold).
+					WithArgs(int64(1), "123", "RELEASED", releasedAt).
+					WillReturnRows(sqlmock.NewRows([]string{"sum", "created_at", "expires_at"}).AddRow("110", createdAt, expiresAt))
+				mock.ExpectExec(queryReleaseHeldBalance).
+					WithArgs(int64(1), model.Money(11000)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			expected: model.Hold{UserID: 1, Order: "123", Sum: 11000, Status: "RELEASED", CreatedAt: createdAt, ExpiresAt: expiresAt},
+		},
+		{
+			name: "hold is not active",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(queryCloseHold).
+					WithArgs(int64(1), "123", "RELEASED", releasedAt).
+					WillReturnRows(sqlmock.NewRows([]string{"sum", "created_at", "expires_at"}))
+				mock.ExpectQuery(queryGetHoldStatus).
+					WithArgs(int64(1), "123").
+					WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("EXPIRED"))
+				mock.ExpectRollback()
+			},
+			err:     dberr.ErrHoldIsNotActive,
+			wantErr: true,
+		},
+		{
+			name: "unexpected err on releasing held balance",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(queryCloseHold).
+					WithArgs(int64(1), "123", "RELEASED", releasedAt).
+					WillReturnRows(sqlmock.NewRows([]string{"sum", "created_at", "expires_at"}).AddRow("110", createdAt, expiresAt))
+				mock.ExpectExec(queryReleaseHeldBalance).
+					WithArgs(int64(1), model.Money(11000)).
+					WillReturnError(errors.New("unexpected error"))
+				mock.ExpectRollback()
+			},
+			err:     errors.New("unexpected error"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockBehavior()
+			hold, err := testPg.ReleaseHold(context.Background(), 1, "123", releasedAt)
+			if tt.wantErr {
+				assert.ErrorContains(t, err, tt.err.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, hold)
+			}
+			if err = mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestPg_ReleaseExpiredHolds(t *testing.T) {
+	testPg := Pg{}
+	testPg.holdsStmts = &holdsStmts{}
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	testPg.db = db
+	mock.ExpectPrepare(queryExpireHolds)
+	if testPg.holdsStmts.stmtExpireHolds, err = testPg.db.PrepareContext(context.Background(), queryExpireHolds); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing expire holds statement", err)
+	}
+	mock.ExpectPrepare(queryReleaseHeldBalance)
+	if testPg.holdsStmts.stmtReleaseHeldBalance, err = testPg.db.PrepareContext(context.Background(), queryReleaseHeldBalance); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing release held balance statement", err)
+	}
+
+	expiredAt := time.Now()
+	createdAt := expiredAt.Add(-time.Hour)
+	expiresAt := expiredAt.Add(-time.Minute)
+
+	tests := []struct {
+		name         string
+		mockBehavior func()
+		expected     []model.Hold
+		wantErr      bool
+	}{
+		{
+			name: "OK",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(queryExpireHolds).
+					WithArgs(expiredAt, 10).
+					WillReturnRows(sqlmock.NewRows([]string{"user_id", "order_number", "sum", "created_at", "expires_at"}).
+						AddRow(1, "123", "110", createdAt, expiresAt).
+						AddRow(2, "456", "5.5", createdAt, expiresAt))
+				mock.ExpectExec(queryReleaseHeldBalance).
+					WithArgs(int64(1), model.Money(11000)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(queryReleaseHeldBalance).
+					WithArgs(int64(2), model.Money(550)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			expected: []model.Hold{
+				{UserID: 1, Order: "123", Sum: 11000, Status: "EXPIRED", CreatedAt: createdAt, ExpiresAt: expiresAt},
+				{UserID: 2, Order: "456", Sum: 550, Status: "EXPIRED", CreatedAt: createdAt, ExpiresAt: expiresAt},
+			},
+		},
+		{
+			name: "nothing to release",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(queryExpireHolds).
+					WithArgs(expiredAt, 10).
+					WillReturnRows(sqlmock.NewRows([]string{"user_id", "order_number", "sum", "created_at", "expires_at"}))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "unexpected err on expiring holds",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(queryExpireHolds).
+					WithArgs(expiredAt, 10).
+					WillReturnError(errors.New("unexpected error"))
+				mock.ExpectRollback()
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockBehavior()
+			holds, err := testPg.ReleaseExpiredHolds(context.Background(), expiredAt, 10)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, holds)
+			}
+			if err = mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
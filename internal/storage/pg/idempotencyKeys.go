@@ -0,0 +1,148 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"practicum-gophermart/internal/model"
+)
+
+// idempotencyKeyClaimAttempts is how many times the key deleted between its claim and its read is claimed.
+const idempotencyKeyClaimAttempts = 2
+
+type idempotencyKeysStmts struct {
+	stmtClaimIdempotencyKey    *sql.Stmt
+	stmtGetIdempotencyKey      *sql.Stmt
+	stmtSaveIdempotentResponse *sql.Stmt
+	stmtDeleteIdempotencyKey   *sql.Stmt
+}
+
+func prepareIdempotencyKeysStmts(ctx context.Context, p *Pg) error {
+
+	newIdempotencyKeysStmts := idempotencyKeysStmts{}
+
+	var err error
+
+	if newIdempotencyKeysStmts.stmtClaimIdempotencyKey, err = p.db.PrepareContext(ctx, queryClaimIdempotencyKey); err != nil {
+		return err
+	}
+
+	if newIdempotencyKeysStmts.stmtGetIdempotencyKey, err = p.db.PrepareContext(ctx, queryGetIdempotencyKey); err != nil {
+		return err
+	}
+
+	if newIdempotencyKeysStmts.stmtSaveIdempotentResponse, err = p.db.PrepareContext(ctx, querySaveIdempotentResponse); err != nil {
+		return err
+	}
+
+	if newIdempotencyKeysStmts.stmtDeleteIdempotencyKey, err = p.db.PrepareContext(ctx, queryDeleteIdempotencyKey); err != nil {
+		return err
+	}
+
+	p.idempotencyKeysStmts = &newIdempotencyKeysStmts
+
+	return nil
+}
+
+// ClaimIdempotencyKey adds the key of the user unless the user already has the key created after expiredBefore
+// or the key of the request in progress claimed after claimExpiredBefore.
+// claimed is false when the key exists, then stored is the existing key.
+// The key deleted after the failed request between the claim and the read of the key is claimed again once.
+func (p *Pg) ClaimIdempotencyKey(ctx context.Context, key model.IdempotencyKey, expiredBefore, claimExpiredBefore time.Time) (
+	stored model.IdempotencyKey, claimed bool, err error) {
+	log.Debug().Msg("Pg.ClaimIdempotencyKey START")
+	defer func() {
+		if err != nil {
+			log.Error().Err(err).Msg("Pg.ClaimIdempotencyKey END")
+		} else {
+			log.Debug().Msg("Pg.ClaimIdempotencyKey END")
+		}
+	}()
+
+	for attempt := 1; ; attempt++ {
+		var userID int64
+		err = p.idempotencyKeysStmts.stmtClaimIdempotencyKey.QueryRowContext(ctx, key.UserID, key.Key, key.RequestHash,
+			key.CreatedAt, expiredBefore, claimExpiredBefore).Scan(&userID)
+		if err == nil {
+			return key, true, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return model.IdempotencyKey{}, false, fmt.Errorf("pg: %w", err)
+		}
+
+		stored = model.IdempotencyKey{UserID: key.UserID, Key: key.Key}
+		err = p.idempotencyKeysStmts.stmtGetIdempotencyKey.QueryRowContext(ctx, key.UserID, key.Key).
+			Scan(&stored.RequestHash, &stored.ResponseCode, &stored.ResponseBody, &stored.CreatedAt)
+		if errors.Is(err, sql.ErrNoRows) && attempt < idempotencyKeyClaimAttempts {
+			log.Debug().Int64("user_id", key.UserID).Msg("idempotency key is deleted, claiming it again")
+			continue
+		}
+		if err != nil {
+			return model.IdempotencyKey{}, false, fmt.Errorf("pg: %w", err)
+		}
+
+		return stored, false, nil
+	}
+}
+
+// SaveIdempotentResponse saves the response to the request with the key of the user.
+func (p *Pg) SaveIdempotentResponse(ctx context.Context, userID int64, key string, code int, body []byte) (err error) {
+	log.Debug().Msg("Pg.SaveIdempotentResponse START")
+	defer func() {
+		if err != nil {
+			log.Error().Err(err).Msg("Pg.SaveIdempotentResponse END")
+		} else {
+			log.Debug().Msg("Pg.SaveIdempotentResponse END")
+		}
+	}()
+
+	if _, err = p.idempotencyKeysStmts.stmtSaveIdempotentResponse.ExecContext(ctx, userID, key, code, body); err != nil {
+		return fmt.Errorf("pg: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteIdempotencyKey deletes the key of the user, so the request with the key can be retried.
+func (p *Pg) DeleteIdempotencyKey(ctx context.Context, userID int64, key string) (err error) {
+	log.Debug().Msg("Pg.DeleteIdempotencyKey START")
+	defer func() {
+		if err != nil {
+			log.Error().Err(err).Msg("Pg.DeleteIdempotencyKey END")
+		} else {
+			log.Debug().Msg("Pg.DeleteIdempotencyKey END")
+		}
+	}()
+
+	if _, err = p.idempotencyKeysStmts.stmtDeleteIdempotencyKey.ExecContext(ctx, userID, key); err != nil {
+		return fmt.Errorf("pg: %w", err)
+	}
+
+	return nil
+}
+
+func (i *idempotencyKeysStmts) Close() (err error) {
+
+	if err = i.stmtClaimIdempotencyKey.Close(); err != nil {
+		return fmt.Errorf("closing stmt 'ClaimIdempotencyKey' : %w", err)
+	}
+
+	if err = i.stmtGetIdempotencyKey.Close(); err != nil {
+		return fmt.Errorf("closing stmt 'GetIdempotencyKey' : %w", err)
+	}
+
+	if err = i.stmtSaveIdempotentResponse.Close(); err != nil {
+		return fmt.Errorf("closing stmt 'SaveIdempotentResponse' : %w", err)
+	}
+
+	if err = i.stmtDeleteIdempotencyKey.Close(); err != nil {
+		return fmt.Errorf("closing stmt 'DeleteIdempotencyKey' : %w", err)
+	}
+
+	return nil
+}
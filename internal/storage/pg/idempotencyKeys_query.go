@@ -0,0 +1,32 @@
+package pg
+
+// queryClaimIdempotencyKey adds the key or takes over the key created before $5
+// and the key of the request in progress claimed before $6, which was left by the crashed request.
+// Nothing is returned when the user already has the key created after $5 or claimed after $6.
+const queryClaimIdempotencyKey = `
+INSERT INTO idempotency_keys (user_id, key, request_hash, created_at)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (user_id, key) DO UPDATE SET
+	request_hash = EXCLUDED.request_hash,
+	response_code = NULL,
+	response_body = NULL,
+	created_at = EXCLUDED.created_at
+WHERE
+	idempotency_keys.created_at < $5 OR (idempotency_keys.response_code IS NULL AND idempotency_keys.created_at < $6)
+RETURNING user_id
+`
+
+const queryGetIdempotencyKey = `
+SELECT
+	request_hash, COALESCE(response_code, 0), response_body, created_at
+FROM
+	idempotency_keys
+WHERE
+	user_id = $1 AND key = $2
+`
+
+const querySaveIdempotentResponse = `
+UPDATE idempotency_keys SET response_code = $3, response_body = $4 WHERE user_id = $1 AND key = $2
+`
+
+const queryDeleteIdempotencyKey = `DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2`
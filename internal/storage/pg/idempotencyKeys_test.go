@@ -0,0 +1,206 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"practicum-gophermart/internal/model"
+)
+
+func TestPg_ClaimIdempotencyKey(t *testing.T) {
+	testPg := Pg{}
+	testPg.idempotencyKeysStmts = &idempotencyKeysStmts{}
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	testPg.db = db
+	mock.ExpectPrepare(queryClaimIdempotencyKey)
+	if testPg.idempotencyKeysStmts.stmtClaimIdempotencyKey, err = testPg.db.PrepareContext(context.Background(), queryClaimIdempotencyKey); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing create user statement", err)
+	}
+	mock.ExpectPrepare(queryGetIdempotencyKey)
+	if testPg.idempotencyKeysStmts.stmtGetIdempotencyKey, err = testPg.db.PrepareContext(context.Background(), queryGetIdempotencyKey); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing create user statement", err)
+	}
+
+	createdAt := time.Now()
+	expiredBefore := createdAt.Add(-time.Hour * 24)
+	claimExpiredBefore := createdAt.Add(-time.Minute)
+	key := model.IdempotencyKey{UserID: 1, Key: "key", RequestHash: "hash", CreatedAt: createdAt}
+	keyColumns := []string{"request_hash", "response_code", "response_body", "created_at"}
+
+	tests := []struct {
+		name            string
+		mockBehavior    func()
+		expected        model.IdempotencyKey
+		expectedClaimed bool
+		wantErr         bool
+	}{
+		{
+			name: "new key",
+			mockBehavior: func() {
+				mock.ExpectQuery(queryClaimIdempotencyKey).
+					WithArgs(int64(1), "key", "hash", createdAt, expiredBefore, claimExpiredBefore).
+					WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(1))
+			},
+			expected:        key,
+			expectedClaimed: true,
+		},
+		{
+			name: "completed request",
+			mockBehavior: func() {
+				mock.ExpectQuery(queryClaimIdempotencyKey).
+					WithArgs(int64(1), "key", "hash", createdAt, expiredBefore, claimExpiredBefore).
+					WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+				mock.ExpectQuery(queryGetIdempotencyKey).
+					WithArgs(int64(1), "key").
+					WillReturnRows(sqlmock.NewRows(keyColumns).AddRow("hash", 200, []byte("null"), createdAt.Add(-time.Hour)))
+			},
+			expected: model.IdempotencyKey{UserID: 1, Key: "key", RequestHash: "hash", ResponseCode: 200,
+				ResponseBody: []byte("null"), CreatedAt: createdAt.Add(-time.Hour)},
+		},
+		{
+			name: "request in progress",
+			mockBehavior: func() {
+				mock.ExpectQuery(queryClaimIdempotencyKey).
+					WithArgs(int64(1), "key", "hash", createdAt, expiredBefore, claimExpiredBefore).
+					WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+				mock.ExpectQuery(queryGetIdempotencyKey).
+					WithArgs(int64(1), "key").
+					WillReturnRows(sqlmock.NewRows(keyColumns).AddRow("other hash", 0, nil, createdAt))
+			},
+			expected: model.IdempotencyKey{UserID: 1, Key: "key", RequestHash: "other hash", CreatedAt: createdAt},
+		},
+		{
+			name: "request in progress left by crash",
+			mockBehavior: func() {
+				mock.ExpectQuery(queryClaimIdempotencyKey).
+					WithArgs(int64(1), "key", "hash", createdAt, expiredBefore, claimExpiredBefore).
+					WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(1))
+			},
+			expected:        key,
+			expectedClaimed: true,
+		},
+		{
+			name: "key deleted after failed request is claimed again",
+			mockBehavior: func() {
+				mock.ExpectQuery(queryClaimIdempotencyKey).
+					WithArgs(int64(1), "key", "hash", createdAt, expiredBefore, claimExpiredBefore).
+					WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+				mock.ExpectQuery(queryGetIdempotencyKey).
+					WithArgs(int64(1), "key").
+					WillReturnRows(sqlmock.NewRows(keyColumns))
+				mock.ExpectQuery(queryClaimIdempotencyKey).
+					WithArgs(int64(1), "key", "hash", createdAt, expiredBefore, claimExpiredBefore).
+					WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(1))
+			},
+			expected:        key,
+			expectedClaimed: true,
+		},
+		{
+			name: "key deleted again",
+			mockBehavior: func() {
+				for i := 0; i < idempotencyKeyClaimAttempts; i++ {
+					mock.ExpectQuery(queryClaimIdempotencyKey).
+						WithArgs(int64(1), "key", "hash", createdAt, expiredBefore, claimExpiredBefore).
+						WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+					mock.ExpectQuery(queryGetIdempotencyKey).
+						WithArgs(int64(1), "key").
+						WillReturnRows(sqlmock.NewRows(keyColumns))
+				}
+			},
+			wantErr: true,
+		},
+		{
+			name: "unexpected error",
+			mockBehavior: func() {
+				mock.ExpectQuery(queryClaimIdempotencyKey).
+					WithArgs(int64(1), "key", "hash", createdAt, expiredBefore, claimExpiredBefore).
+					WillReturnError(errors.New("unexpected error"))
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockBehavior()
+			stored, claimed, err := testPg.ClaimIdempotencyKey(context.Background(), key, expiredBefore, claimExpiredBefore)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, stored)
+				assert.Equal(t, tt.expectedClaimed, claimed)
+			}
+			if err = mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestPg_SaveIdempotentResponse(t *testing.T) {
+	testPg := Pg{}
+	testPg.idempotencyKeysStmts = &idempotencyKeysStmts{}
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	testPg.db = db
+	mock.ExpectPrepare(querySaveIdempotentResponse)
+	if testPg.idempotencyKeysStmts.stmtSaveIdempotentResponse, err = testPg.db.PrepareContext(context.Background(), querySaveIdempotentResponse); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing create user statement", err)
+	}
+
+	mock.ExpectExec(querySaveIdempotentResponse).
+		WithArgs(int64(1), "key", 202, []byte("null")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, testPg.SaveIdempotentResponse(context.Background(), 1, "key", 202, []byte("null")))
+
+	mock.ExpectExec(querySaveIdempotentResponse).
+		WithArgs(int64(1), "key", 202, []byte("null")).
+		WillReturnError(errors.New("unexpected error"))
+	assert.Error(t, testPg.SaveIdempotentResponse(context.Background(), 1, "key", 202, []byte("null")))
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPg_DeleteIdempotencyKey(t *testing.T) {
+	testPg := Pg{}
+	testPg.idempotencyKeysStmts = &idempotencyKeysStmts{}
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	testPg.db = db
+	mock.ExpectPrepare(queryDeleteIdempotencyKey)
+	if testPg.idempotencyKeysStmts.stmtDeleteIdempotencyKey, err = testPg.db.PrepareContext(context.Background(), queryDeleteIdempotencyKey); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing create user statement", err)
+	}
+
+	mock.ExpectExec(queryDeleteIdempotencyKey).
+		WithArgs(int64(1), "key").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, testPg.DeleteIdempotencyKey(context.Background(), 1, "key"))
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,74 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"practicum-gophermart/internal/model"
+)
+
+// ledgerStmts are statements of the append-only points ledger.
+// Postings are never updated or deleted, the balance table is a cache of the sum of the user postings
+// and is changed in the same transaction as the posting is added.
+type ledgerStmts struct {
+	stmtAddPosting   *sql.Stmt
+	stmtGetPostingID *sql.Stmt
+}
+
+func prepareLedgerStmts(ctx context.Context, p *Pg) error {
+
+	newLedgerStmts := ledgerStmts{}
+
+	var err error
+
+	if newLedgerStmts.stmtAddPosting, err = p.db.PrepareContext(ctx, queryAddPosting); err != nil {
+		return err
+	}
+
+	if newLedgerStmts.stmtGetPostingID, err = p.db.PrepareContext(ctx, queryGetPostingID); err != nil {
+		return err
+	}
+
+	p.ledgerStmts = &newLedgerStmts
+
+	return nil
+}
+
+// addPosting adds the posting to the ledger within the transaction and returns its id.
+func (p *Pg) addPosting(ctx context.Context, tx *sql.Tx, posting model.Posting) (id int64, err error) {
+	err = tx.StmtContext(ctx, p.ledgerStmts.stmtAddPosting).QueryRowContext(ctx, posting.UserID, posting.Kind,
+		posting.DebitAccount, posting.CreditAccount, posting.Amount, posting.OrderNumber, posting.ReversesID,
+		posting.CreatedAt).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("adding %s posting: %w", posting.Kind, err)
+	}
+	return id, nil
+}
+
+// getPostingID returns within the transaction the id of the last posting of the kind for the order
+// or 0 if there is no such posting.
+func (p *Pg) getPostingID(ctx context.Context, tx *sql.Tx, kind, orderNumber string) (id int64, err error) {
+	err = tx.StmtContext(ctx, p.ledgerStmts.stmtGetPostingID).QueryRowContext(ctx, kind, orderNumber).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("getting %s posting: %w", kind, err)
+	}
+	return id, nil
+}
+
+func (l *ledgerStmts) Close() (err error) {
+
+	if err = l.stmtAddPosting.Close(); err != nil {
+		return fmt.Errorf("closing stmt 'AddPosting' : %w", err)
+	}
+
+	if err = l.stmtGetPostingID.Close(); err != nil {
+		return fmt.Errorf("closing stmt 'GetPostingID' : %w", err)
+	}
+
+	return nil
+}
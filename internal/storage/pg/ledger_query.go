@@ -0,0 +1,10 @@
+package pg
+
+const queryAddPosting = `
+INSERT INTO ledger (user_id, kind, debit_account, credit_account, amount, order_number, reverses_id, created_at)
+VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, 0), $8)
+RETURNING id
+`
+
+// queryGetPostingID returns the id of the last posting of the kind for the order.
+const queryGetPostingID = `SELECT id FROM ledger WHERE kind = $1 AND order_number = $2 ORDER BY id DESC LIMIT 1`
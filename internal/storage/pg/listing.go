@@ -0,0 +1,36 @@
+package pg
+
+import (
+	"database/sql"
+	"time"
+
+	"practicum-gophermart/internal/model"
+)
+
+// nullTime returns null for zero time, so the filter is not applied.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// cursorArgs returns time and id of the cursor as query args.
+func cursorArgs(after *model.Cursor) (sql.NullTime, int64) {
+	if after == nil {
+		return sql.NullTime{}, 0
+	}
+	return sql.NullTime{Time: after.Time, Valid: true}, after.ID
+}
+
+// limitArg returns the limit of the listing as query arg, one row over the limit is queried to know
+// that there are more rows. Zero limit is NULL, so all the rows are returned.
+func limitArg(limit int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(limit) + 1, Valid: limit > 0}
+}
+
+// nextCursor returns the cursor of the last row of the page if there are more rows after it.
+// Listings query one row over the limit to know that.
+func nextCursor(rowsCount, limit int, last model.Cursor) *model.Cursor {
+	if limit == 0 || rowsCount <= limit {
+		return nil
+	}
+	return &last
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/jackc/pgconn"
 	"github.com/jackc/pgerrcode"
@@ -16,11 +17,12 @@ import (
 )
 
 type ordersStmts struct {
-	stmtAddOrder            *sql.Stmt
-	stmtGetOrder            *sql.Stmt
-	stmtGetUserOrders       *sql.Stmt
-	stmtGetOrdersByStatuses *sql.Stmt
-	stmtUpdateOrderStatus   *sql.Stmt
+	stmtAddOrder                *sql.Stmt
+	stmtGetOrder                *sql.Stmt
+	stmtGetUserOrders           *sql.Stmt
+	stmtClaimOrdersToPoll       *sql.Stmt
+	stmtUpdateOrderStatus       *sql.Stmt
+	stmtUpdateOrderPollingState *sql.Stmt
 }
 
 func prepareOrdersStmts(ctx context.Context, p *Pg) error {
@@ -41,7 +43,7 @@ func prepareOrdersStmts(ctx context.Context, p *Pg) error {
 		return err
 	}
 
-	if newOrdersStmts.stmtGetOrdersByStatuses, err = p.db.PrepareContext(ctx, queryGetOrdersByStatuses); err != nil {
+	if newOrdersStmts.stmtClaimOrdersToPoll, err = p.db.PrepareContext(ctx, queryClaimOrdersToPoll); err != nil {
 		return err
 	}
 
@@ -49,6 +51,10 @@ func prepareOrdersStmts(ctx context.Context, p *Pg) error {
 		return err
 	}
 
+	if newOrdersStmts.stmtUpdateOrderPollingState, err = p.db.PrepareContext(ctx, queryUpdateOrderPollingState); err != nil {
+		return err
+	}
+
 	p.ordersStmts = &newOrdersStmts
 
 	return nil
@@ -86,7 +92,9 @@ func (p *Pg) AddOrder(ctx context.Context, order *model.Order) error {
 	return nil
 }
 
-func (p *Pg) GetOrdersByUser(ctx context.Context, userID int64) ([]model.Order, error) {
+// GetOrdersByUser returns the page of the user orders selected by the filter and the cursor of the next page.
+// The cursor is nil on the last page.
+func (p *Pg) GetOrdersByUser(ctx context.Context, userID int64, filter model.ListFilter) ([]model.Order, *model.Cursor, error) {
 	log.Debug().Msg("Pg.GetOrdersByUser START")
 	var err error
 	defer func() {
@@ -97,26 +105,37 @@ func (p *Pg) GetOrdersByUser(ctx context.Context, userID int64) ([]model.Order,
 		}
 	}()
 
-	rows, err := p.ordersStmts.stmtGetUserOrders.QueryContext(ctx, userID)
+	afterTime, afterID := cursorArgs(filter.After)
+	rows, err := p.ordersStmts.stmtGetUserOrders.QueryContext(ctx, userID, pq.Array(filter.Statuses), nullTime(filter.From),
+		nullTime(filter.To), afterTime, afterID, limitArg(filter.Limit))
 	if err != nil {
-		return nil, fmt.Errorf("pg: %w", err)
+		return nil, nil, fmt.Errorf("pg: %w", err)
 	}
 	defer rows.Close()
 
 	var orders []model.Order
+	var last model.Cursor
+	rowsCount := 0
 	for rows.Next() {
+		rowsCount++
+		if filter.Limit > 0 && rowsCount > filter.Limit {
+			break
+		}
+
 		currOrder := model.Order{}
-		if err = rows.Scan(&currOrder.UserID, &currOrder.Number, &currOrder.Status, &currOrder.Accrual, &currOrder.UploadedAt); err != nil {
-			return nil, fmt.Errorf("pg: %w", err)
+		if err = rows.Scan(&last.ID, &currOrder.UserID, &currOrder.Number, &currOrder.Status, &currOrder.Accrual,
+			&currOrder.UploadedAt); err != nil {
+			return nil, nil, fmt.Errorf("pg: %w", err)
 		}
+		last.Time = currOrder.UploadedAt
 		orders = append(orders, currOrder)
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf(`pg: %w`, err)
+		return nil, nil, fmt.Errorf(`pg: %w`, err)
 	}
 
-	return orders, nil
+	return orders, nextCursor(rowsCount, filter.Limit, last), nil
 }
 
 func (p *Pg) GetOrder(ctx context.Context, number string) (*model.Order, error) {
@@ -144,17 +163,21 @@ func (p *Pg) GetOrder(ctx context.Context, number string) (*model.Order, error)
 	return &order, nil
 }
 
-func (p *Pg) GetOrdersByStatuses(statuses []string) (orders []model.Order, err error) {
-	log.Debug().Msg("Pg.GetOrdersByStatuses START")
+// ClaimOrdersToPoll leases to the owner until leaseExpiresAt no more than limit orders with given statuses
+// which are due to be polled in the accrual system at dueAt.
+// An order is leased by one owner at a time.
+func (p *Pg) ClaimOrdersToPoll(ctx context.Context, owner string, leaseExpiresAt time.Time, statuses []string, dueAt time.Time,
+	limit int) (orders []model.Order, err error) {
+	log.Debug().Msg("Pg.ClaimOrdersToPoll START")
 	defer func() {
 		if err != nil {
-			log.Error().Err(err).Msg("Pg.GetOrdersByStatuses END")
+			log.Error().Err(err).Msg("Pg.ClaimOrdersToPoll END")
 		} else {
-			log.Debug().Msg("Pg.GetOrdersByStatuses END")
+			log.Debug().Msg("Pg.ClaimOrdersToPoll END")
 		}
 	}()
 
-	rows, err := p.ordersStmts.stmtGetOrdersByStatuses.Query(pq.Array(statuses))
+	rows, err := p.ordersStmts.stmtClaimOrdersToPoll.QueryContext(ctx, owner, leaseExpiresAt, pq.Array(statuses), dueAt, limit)
 	if err != nil {
 		return nil, fmt.Errorf(`pg: %w`, err)
 	}
@@ -162,8 +185,9 @@ func (p *Pg) GetOrdersByStatuses(statuses []string) (orders []model.Order, err e
 
 	for rows.Next() {
 		currOrder := model.Order{}
-		if err = rows.Scan(&currOrder.UserID, &currOrder.Number, &currOrder.Status, &currOrder.Accrual, &currOrder.UploadedAt); err != nil {
-			return nil, err
+		if err = rows.Scan(&currOrder.UserID, &currOrder.Number, &currOrder.Status, &currOrder.Accrual, &currOrder.UploadedAt,
+			&currOrder.Attempts, &currOrder.PollResult); err != nil {
+			return nil, fmt.Errorf(`pg: %w`, err)
 		}
 		orders = append(orders, currOrder)
 	}
@@ -175,7 +199,50 @@ func (p *Pg) GetOrdersByStatuses(statuses []string) (orders []model.Order, err e
 	return orders, nil
 }
 
-func (p *Pg) UpdateOrderStatuses(newOrderStatuses []model.Order) error {
+// UpdateOrdersPollingState saves attempts count, polling times and result of the last poll
+// of the orders leased by the owner and releases their leases.
+func (p *Pg) UpdateOrdersPollingState(ctx context.Context, owner string, orders []model.Order) error {
+	log.Debug().Msg("Pg.UpdateOrdersPollingState START")
+	var err error
+	defer func() {
+		if err != nil {
+			log.Error().Err(err).Msg("Pg.UpdateOrdersPollingState END")
+		} else {
+			log.Debug().Msg("Pg.UpdateOrdersPollingState END")
+		}
+	}()
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if errTxRollback := tx.Rollback(); errTxRollback != nil && !errors.Is(errTxRollback, sql.ErrTxDone) {
+			log.Error().Err(errTxRollback).Msg("tx rollback")
+		}
+	}()
+
+	stmt := tx.StmtContext(ctx, p.ordersStmts.stmtUpdateOrderPollingState)
+	for _, order := range orders {
+		if _, err = stmt.ExecContext(ctx, order.Attempts, order.LastPolledAt, order.NextPollAt, order.PollResult,
+			order.Number, owner); err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UpdateOrderStatuses saves new statuses of the orders which move the orders forward,
+// the transitions to the same or the previous status are skipped.
+// The accrual is posted to the ledger and added to the balance of the user only when the order transitions
+// to processed, so delivering the same status twice is harmless.
+// The accrued points are put into a lot which expires at pointsExpireAt.
+func (p *Pg) UpdateOrderStatuses(newOrderStatuses []model.Order, pointsExpireAt time.Time) error {
 	log.Debug().Msg("Pg.UpdateOrderStatuses START")
 	var err error
 	defer func() {
@@ -186,21 +253,49 @@ func (p *Pg) UpdateOrderStatuses(newOrderStatuses []model.Order) error {
 		}
 	}()
 
+	ctx := context.Background()
+
 	tx, err := p.db.Begin()
 	if err != nil {
 		return err
 	}
 	defer func() {
-		if errTxRollback := tx.Rollback(); errTxRollback != nil {
+		if errTxRollback := tx.Rollback(); errTxRollback != nil && !errors.Is(errTxRollback, sql.ErrTxDone) {
 			log.Error().Err(errTxRollback).Msg("tx rollback")
 		}
 	}()
 
 	for _, order := range newOrderStatuses {
-		if _, err = tx.Stmt(p.ordersStmts.stmtUpdateOrderStatus).Exec(order.Status, order.Accrual, order.Number); err != nil {
+		var userID int64
+		err = tx.StmtContext(ctx, p.ordersStmts.stmtUpdateOrderStatus).QueryRowContext(ctx, order.Status, order.Accrual, order.Number).
+			Scan(&userID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				err = nil
+				log.Debug().Str("order_number", order.Number).Str("status", order.Status).Msg("order status transition skipped")
+				continue
+			}
 			return err
 		}
-		if _, err = tx.Stmt(p.balanceStmts.stmtIncreaseBalance).Exec(order.UserID, order.Accrual); err != nil {
+
+		if order.Status != model.OrderStatusProcessed.String() || order.Accrual <= 0 {
+			continue
+		}
+		accruedAt := time.Now()
+		if _, err = p.addPosting(ctx, tx, model.NewAccrualPosting(userID, order.Number, order.Accrual, accruedAt)); err != nil {
+			return err
+		}
+		err = p.addPointLot(ctx, tx, model.PointLot{
+			UserID:      userID,
+			OrderNumber: order.Number,
+			Amount:      order.Accrual,
+			AccruedAt:   accruedAt,
+			ExpiresAt:   pointsExpireAt,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err = tx.StmtContext(ctx, p.balanceStmts.stmtIncreaseBalance).ExecContext(ctx, userID, order.Accrual); err != nil {
 			return err
 		}
 	}
@@ -227,13 +322,17 @@ func (o *ordersStmts) Close() (err error) {
 		return fmt.Errorf("closing stmt 'GetUserOrders ' : %w", err)
 	}
 
-	if err = o.stmtGetOrdersByStatuses.Close(); err != nil {
-		return fmt.Errorf("closing stmt 'GetOrdersByStatuses ' : %w", err)
+	if err = o.stmtClaimOrdersToPoll.Close(); err != nil {
+		return fmt.Errorf("closing stmt 'ClaimOrdersToPoll' : %w", err)
 	}
 
 	if err = o.stmtUpdateOrderStatus.Close(); err != nil {
 		return fmt.Errorf("closing stmt 'UpdateOrderStatus ' : %w", err)
 	}
 
+	if err = o.stmtUpdateOrderPollingState.Close(); err != nil {
+		return fmt.Errorf("closing stmt 'UpdateOrderPollingState' : %w", err)
+	}
+
 	return nil
 }
@@ -1,9 +1,60 @@
 package pg
 
 const (
-	queryAddOrder            = `INSERT INTO orders (user_id, number, status, accrual, uploaded_at) VALUES ($1, $2, $3, $4, $5)`
-	queryGetOrder            = `SELECT user_id, number, status, accrual, uploaded_at FROM orders WHERE number=$1`
-	queryGetOrdersByUser     = `SELECT user_id, number, status, accrual, uploaded_at FROM orders WHERE user_id=$1 ORDER BY uploaded_at`
-	queryGetOrdersByStatuses = `SELECT user_id, number, status, accrual, uploaded_at FROM orders WHERE status = any($1)`
-	queryUpdateOrderStatus   = `UPDATE orders SET status = $1, accrual = $2 WHERE number = $3`
+	queryAddOrder = `INSERT INTO orders (user_id, number, status, accrual, uploaded_at) VALUES ($1, $2, $3, $4, $5)`
+	queryGetOrder = `SELECT user_id, number, status, accrual, uploaded_at FROM orders WHERE number=$1`
 )
+
+// queryGetOrdersByUser returns the page of the user orders after the cursor ($5, $6) sorted by upload time.
+// Null filters and limit are not applied.
+const queryGetOrdersByUser = `
+SELECT
+	id, user_id, number, status, accrual, uploaded_at
+FROM
+	orders
+WHERE
+	user_id = $1
+	AND (COALESCE(cardinality($2::varchar[]), 0) = 0 OR status::varchar = any($2))
+	AND ($3::timestamp IS NULL OR uploaded_at >= $3)
+	AND ($4::timestamp IS NULL OR uploaded_at < $4)
+	AND ($5::timestamp IS NULL OR (uploaded_at, id) > ($5, $6))
+ORDER BY
+	uploaded_at, id
+LIMIT $7
+`
+
+// queryClaimOrdersToPoll leases due orders to the owner.
+// Orders locked or leased by other instances are skipped, expired leases are taken over.
+const queryClaimOrdersToPoll = `
+UPDATE orders SET lease_owner = $1, lease_expires_at = $2
+WHERE id IN (
+	SELECT
+		id
+	FROM
+		orders
+	WHERE
+		status = any($3) AND next_poll_at <= $4 AND (lease_expires_at IS NULL OR lease_expires_at <= $4)
+	ORDER BY
+		next_poll_at
+	LIMIT $5
+	FOR UPDATE SKIP LOCKED
+)
+RETURNING user_id, number, status, accrual, uploaded_at, attempts, COALESCE(poll_result, '')
+`
+
+// queryUpdateOrderStatus changes the status only forward: NEW -> PROCESSING -> PROCESSED or INVALID,
+// so the late status of the accrual system does not move the order back.
+// It returns no rows if the transition did not happen.
+const queryUpdateOrderStatus = `
+UPDATE orders SET status = $1, accrual = $2
+WHERE number = $3 AND (
+	(status = 'NEW' AND $1 IN ('PROCESSING', 'PROCESSED', 'INVALID')) OR
+	(status = 'PROCESSING' AND $1 IN ('PROCESSED', 'INVALID'))
+)
+RETURNING user_id
+`
+
+const queryUpdateOrderPollingState = `
+UPDATE orders SET attempts = $1, last_polled_at = $2, next_poll_at = $3, poll_result = $4, lease_owner = NULL, lease_expires_at = NULL
+WHERE number = $5 AND lease_owner = $6
+`
@@ -108,11 +108,16 @@ func TestPg_GetOrdersByUser(t *testing.T) {
 	}
 	testPg.db = db
 
+	from := time.Unix(1, 0)
+	to := time.Unix(100, 0)
+
 	tests := []struct {
 		name         string
 		mockBehavior func(userID int64)
 		userID       int64
+		filter       model.ListFilter
 		expected     []model.Order
+		expectedNext *model.Cursor
 		err          error
 		wantErr      bool
 	}{
@@ -120,11 +125,12 @@ func TestPg_GetOrdersByUser(t *testing.T) {
 			name: "OK",
 			mockBehavior: func(userID int64) {
 				mock.ExpectQuery(queryGetOrdersByUser).
-					WithArgs(userID).
-					WillReturnRows(sqlmock.NewRows([]string{"user_id", "number", "status", "accrual", "uploaded_at"}).
-						AddRow(1, "123", "NEW", 0, time.Unix(1, 1)))
+					WithArgs(userID, pq.Array([]string(nil)), sql.NullTime{}, sql.NullTime{}, sql.NullTime{}, int64(0), 2).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "number", "status", "accrual", "uploaded_at"}).
+						AddRow(7, 1, "123", "NEW", "0", time.Unix(1, 1)))
 			},
 			userID: 1,
+			filter: model.ListFilter{Limit: 1},
 			expected: []model.Order{
 				{
 					UserID:     1,
@@ -135,14 +141,70 @@ func TestPg_GetOrdersByUser(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "page with next page",
+			mockBehavior: func(userID int64) {
+				mock.ExpectQuery(queryGetOrdersByUser).
+					WithArgs(userID, pq.Array([]string{"NEW", "PROCESSED"}), sql.NullTime{Time: from, Valid: true},
+						sql.NullTime{Time: to, Valid: true}, sql.NullTime{Time: time.Unix(1, 0), Valid: true}, int64(5), 2).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "number", "status", "accrual", "uploaded_at"}).
+						AddRow(7, 1, "123", "NEW", "0", time.Unix(2, 0)).
+						AddRow(9, 1, "321", "PROCESSED", "500", time.Unix(3, 0)))
+			},
+			userID: 1,
+			filter: model.ListFilter{
+				Statuses: []string{"NEW", "PROCESSED"},
+				From:     from,
+				To:       to,
+				After:    &model.Cursor{Time: time.Unix(1, 0), ID: 5},
+				Limit:    1,
+			},
+			expected: []model.Order{
+				{
+					UserID:     1,
+					Number:     "123",
+					Status:     "NEW",
+					UploadedAt: time.Unix(2, 0),
+				},
+			},
+			expectedNext: &model.Cursor{Time: time.Unix(2, 0), ID: 7},
+		},
+		{
+			name: "without limit",
+			mockBehavior: func(userID int64) {
+				mock.ExpectQuery(queryGetOrdersByUser).
+					WithArgs(userID, pq.Array([]string(nil)), sql.NullTime{}, sql.NullTime{}, sql.NullTime{}, int64(0), sql.NullInt64{}).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "number", "status", "accrual", "uploaded_at"}).
+						AddRow(7, 1, "123", "NEW", "0", time.Unix(2, 0)).
+						AddRow(9, 1, "321", "PROCESSED", "500", time.Unix(3, 0)))
+			},
+			userID: 1,
+			filter: model.ListFilter{},
+			expected: []model.Order{
+				{
+					UserID:     1,
+					Number:     "123",
+					Status:     "NEW",
+					UploadedAt: time.Unix(2, 0),
+				},
+				{
+					UserID:     1,
+					Number:     "321",
+					Status:     "PROCESSED",
+					Accrual:    50000,
+					UploadedAt: time.Unix(3, 0),
+				},
+			},
+		},
 		{
 			name: "unexpected error",
 			mockBehavior: func(userID int64) {
 				mock.ExpectQuery(queryGetOrdersByUser).
-					WithArgs(userID).
+					WithArgs(userID, pq.Array([]string(nil)), sql.NullTime{}, sql.NullTime{}, sql.NullTime{}, int64(0), 2).
 					WillReturnError(errors.New("unexpected error"))
 			},
 			userID:  1,
+			filter:  model.ListFilter{Limit: 1},
 			wantErr: true,
 			err:     errors.New("unexpected error"),
 		},
@@ -151,12 +213,13 @@ func TestPg_GetOrdersByUser(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.mockBehavior(tt.userID)
 			gCtx, _ := gin.CreateTestContext(httptest.NewRecorder())
-			orders, err := testPg.GetOrdersByUser(gCtx, tt.userID)
+			orders, next, err := testPg.GetOrdersByUser(gCtx, tt.userID, tt.filter)
 			if tt.wantErr {
 				assert.Error(t, err)
 			} else {
 				assert.NoError(t, err)
 				assert.Equal(t, tt.expected, orders)
+				assert.Equal(t, tt.expectedNext, next)
 			}
 			if err = mock.ExpectationsWereMet(); err != nil {
 				t.Errorf("there were unfulfilled expectations: %s", err)
@@ -249,7 +312,7 @@ func TestPg_GetOrder(t *testing.T) {
 	}
 }
 
-func TestPg_GetOrdersByStatuses(t *testing.T) {
+func TestPg_ClaimOrdersToPoll(t *testing.T) {
 	testPg := Pg{}
 	testPg.ordersStmts = &ordersStmts{}
 
@@ -260,12 +323,15 @@ func TestPg_GetOrdersByStatuses(t *testing.T) {
 	defer db.Close()
 
 	testPg.db = db
-	mock.ExpectPrepare(queryGetOrdersByStatuses)
-	if testPg.ordersStmts.stmtGetOrdersByStatuses, err = testPg.db.PrepareContext(context.Background(), queryGetOrdersByStatuses); err != nil {
+	mock.ExpectPrepare(queryClaimOrdersToPoll)
+	if testPg.ordersStmts.stmtClaimOrdersToPoll, err = testPg.db.PrepareContext(context.Background(), queryClaimOrdersToPoll); err != nil {
 		t.Fatalf("an error '%s' was not expected when preparing create user statement", err)
 	}
 	testPg.db = db
 
+	dueAt := time.Unix(10, 0)
+	leaseExpiresAt := time.Unix(70, 0)
+
 	tests := []struct {
 		name         string
 		mockBehavior func()
@@ -276,11 +342,11 @@ func TestPg_GetOrdersByStatuses(t *testing.T) {
 		{
 			name: "OK",
 			mockBehavior: func() {
-				mock.ExpectQuery(queryGetOrdersByStatuses).
-					WithArgs(pq.Array([]string{"NEW", "PROCESSING"})).
-					WillReturnRows(sqlmock.NewRows([]string{"user_id", "number", "status", "accrual", "uploaded_at"}).
-						AddRow(1, "123", "NEW", 11.1, time.Unix(1, 1)).
-						AddRow(2, "321", "NEW", 22.7, time.Unix(1, 2)))
+				mock.ExpectQuery(queryClaimOrdersToPoll).
+					WithArgs("owner", leaseExpiresAt, pq.Array([]string{"NEW", "PROCESSING"}), dueAt, 2).
+					WillReturnRows(sqlmock.NewRows([]string{"user_id", "number", "status", "accrual", "uploaded_at", "attempts", "poll_result"}).
+						AddRow(1, "123", "NEW", "11.10", time.Unix(1, 1), 0, "").
+						AddRow(2, "321", "NEW", "22.70", time.Unix(1, 2), 3, "NOT_REGISTERED"))
 
 			},
 			expected: []model.Order{
@@ -288,23 +354,25 @@ func TestPg_GetOrdersByStatuses(t *testing.T) {
 					UserID:     1,
 					Number:     "123",
 					Status:     "NEW",
-					Accrual:    11.1,
+					Accrual:    1110,
 					UploadedAt: time.Unix(1, 1),
 				},
 				{
 					UserID:     2,
 					Number:     "321",
 					Status:     "NEW",
-					Accrual:    22.7,
+					Accrual:    2270,
 					UploadedAt: time.Unix(1, 2),
+					Attempts:   3,
+					PollResult: "NOT_REGISTERED",
 				},
 			},
 		},
 		{
 			name: "unexpected error",
 			mockBehavior: func() {
-				mock.ExpectQuery(queryGetOrdersByStatuses).
-					WithArgs(pq.Array([]string{"NEW", "PROCESSING"})).
+				mock.ExpectQuery(queryClaimOrdersToPoll).
+					WithArgs("owner", leaseExpiresAt, pq.Array([]string{"NEW", "PROCESSING"}), dueAt, 2).
 					WillReturnError(errors.New("unexpected error"))
 			},
 			err:     "unexpected error",
@@ -314,7 +382,7 @@ func TestPg_GetOrdersByStatuses(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.mockBehavior()
-			orders, err := testPg.GetOrdersByStatuses([]string{"NEW", "PROCESSING"})
+			orders, err := testPg.ClaimOrdersToPoll(context.Background(), "owner", leaseExpiresAt, []string{"NEW", "PROCESSING"}, dueAt, 2)
 			if tt.wantErr {
 				assert.Error(t, err)
 				assert.ErrorContains(t, err, tt.err)
@@ -329,10 +397,84 @@ func TestPg_GetOrdersByStatuses(t *testing.T) {
 	}
 }
 
+func TestPg_UpdateOrdersPollingState(t *testing.T) {
+	testPg := Pg{}
+	testPg.ordersStmts = &ordersStmts{}
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	testPg.db = db
+	mock.ExpectPrepare(queryUpdateOrderPollingState)
+	if testPg.ordersStmts.stmtUpdateOrderPollingState, err = testPg.db.PrepareContext(context.Background(), queryUpdateOrderPollingState); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing create user statement", err)
+	}
+	testPg.db = db
+
+	orders := []model.Order{
+		{Number: "123", Attempts: 1, LastPolledAt: time.Unix(1, 0), NextPollAt: time.Unix(6, 0), PollResult: "FOUND"},
+		{Number: "321", Attempts: 4, LastPolledAt: time.Unix(1, 0), NextPollAt: time.Unix(41, 0), PollResult: "ACCRUAL_UNAVAILABLE"},
+	}
+
+	tests := []struct {
+		name         string
+		mockBehavior func()
+		err          string
+		wantErr      bool
+	}{
+		{
+			name: "OK",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				for _, order := range orders {
+					mock.ExpectExec(queryUpdateOrderPollingState).
+						WithArgs(order.Attempts, order.LastPolledAt, order.NextPollAt, order.PollResult, order.Number, "owner").
+						WillReturnResult(sqlmock.NewResult(0, 1))
+				}
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "err on updating order",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(queryUpdateOrderPollingState).
+					WithArgs(orders[0].Attempts, orders[0].LastPolledAt, orders[0].NextPollAt, orders[0].PollResult, orders[0].Number,
+						"owner").
+					WillReturnError(errors.New("unexpected error"))
+				mock.ExpectRollback()
+			},
+			err:     "unexpected error",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockBehavior()
+			err := testPg.UpdateOrdersPollingState(context.Background(), "owner", orders)
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.ErrorContains(t, err, tt.err)
+			} else {
+				assert.NoError(t, err)
+			}
+			if err = mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
 func TestPg_UpdateOrderStatuses(t *testing.T) {
 	testPg := Pg{}
 	testPg.ordersStmts = &ordersStmts{}
 	testPg.balanceStmts = &balanceStmts{}
+	testPg.ledgerStmts = &ledgerStmts{}
+	testPg.pointLotsStmts = &pointLotsStmts{}
+	pointsExpireAt := time.Now().AddDate(1, 0, 0)
 
 	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 	if err != nil {
@@ -349,72 +491,228 @@ func TestPg_UpdateOrderStatuses(t *testing.T) {
 	if testPg.balanceStmts.stmtIncreaseBalance, err = testPg.db.PrepareContext(context.Background(), queryIncreaseBalance); err != nil {
 		t.Fatalf("an error '%s' was not expected when preparing create user statement", err)
 	}
+	mock.ExpectPrepare(queryAddPosting)
+	if testPg.ledgerStmts.stmtAddPosting, err = testPg.db.PrepareContext(context.Background(), queryAddPosting); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing create user statement", err)
+	}
+	mock.ExpectPrepare(queryAddPointLot)
+	if testPg.pointLotsStmts.stmtAddPointLot, err = testPg.db.PrepareContext(context.Background(), queryAddPointLot); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing create user statement", err)
+	}
 	testPg.db = db
 
 	tests := []struct {
-		name                            string
-		mockBehavior                    func(newOrderStatuses []model.Order, wantErrOnUpdatingThisOrderIndex int)
-		newOrderStatuses                []model.Order
-		wantErrOnUpdatingThisOrderIndex int
-		err                             string
-		wantErr                         bool
+		name             string
+		mockBehavior     func(newOrderStatuses []model.Order)
+		newOrderStatuses []model.Order
+		err              string
+		wantErr          bool
 	}{
 		{
 			name: "OK",
-			mockBehavior: func(newOrderStatuses []model.Order, wantErrOnUpdatingThisOrderIndex int) {
+			mockBehavior: func(newOrderStatuses []model.Order) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(queryUpdateOrderStatus).
+					WithArgs("PROCESSED", model.Money(2233), "123").
+					WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(1))
+				mock.ExpectQuery(queryAddPosting).
+					WithArgs(int64(1), "ACCRUAL", "ACCRUALS", "USER", model.Money(2233), "123", int64(0), sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+				mock.ExpectExec(queryAddPointLot).
+					WithArgs(int64(1), "123", model.Money(2233), sqlmock.AnyArg(), pointsExpireAt).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(queryIncreaseBalance).
+					WithArgs(int64(1), model.Money(2233)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(queryUpdateOrderStatus).
+					WithArgs("PROCESSING", model.Money(0), "321").
+					WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(2))
+				mock.ExpectCommit()
+			},
+			newOrderStatuses: []model.Order{
+				{Status: "PROCESSED", Accrual: 2233, Number: "123", UserID: 1},
+				{Status: "PROCESSING", Number: "321", UserID: 2},
+			},
+		},
+		{
+			name: "balance is not increased for non processed statuses",
+			mockBehavior: func(newOrderStatuses []model.Order) {
 				mock.ExpectBegin()
 				for _, order := range newOrderStatuses {
-					mock.ExpectExec(queryUpdateOrderStatus).
+					mock.ExpectQuery(queryUpdateOrderStatus).
 						WithArgs(order.Status, order.Accrual, order.Number).
-						WillReturnResult(sqlmock.NewResult(0, 0))
-					mock.ExpectExec(queryIncreaseBalance).
-						WithArgs(order.UserID, order.Accrual).
-						WillReturnResult(sqlmock.NewResult(0, 0))
+						WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(1))
 				}
 				mock.ExpectCommit()
 			},
 			newOrderStatuses: []model.Order{
-				{Status: "PROCESSED", Accrual: 22.33, Number: "123"},
-				{Status: "PROCESSING", Accrual: 33.22, Number: "321"},
+				{Status: "PROCESSING", Number: "123"},
+				{Status: "INVALID", Number: "456"},
+			},
+		},
+		{
+			name: "late registered status does not move processing order back",
+			mockBehavior: func(newOrderStatuses []model.Order) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(queryUpdateOrderStatus).
+					WithArgs("NEW", model.Money(0), "123").
+					WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+				mock.ExpectQuery(queryUpdateOrderStatus).
+					WithArgs("PROCESSED", model.Money(50000), "123").
+					WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(1))
+				mock.ExpectQuery(queryAddPosting).
+					WithArgs(int64(1), "ACCRUAL", "ACCRUALS", "USER", model.Money(50000), "123", int64(0), sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+				mock.ExpectExec(queryAddPointLot).
+					WithArgs(int64(1), "123", model.Money(50000), sqlmock.AnyArg(), pointsExpireAt).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(queryIncreaseBalance).
+					WithArgs(int64(1), model.Money(50000)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			newOrderStatuses: []model.Order{
+				{Status: "NEW", Number: "123", UserID: 1},
+				{Status: "PROCESSED", Accrual: 50000, Number: "123", UserID: 1},
+			},
+		},
+		{
+			name: "double delivery in one batch credits once",
+			mockBehavior: func(newOrderStatuses []model.Order) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(queryUpdateOrderStatus).
+					WithArgs("PROCESSED", model.Money(50000), "123").
+					WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(1))
+				mock.ExpectQuery(queryAddPosting).
+					WithArgs(int64(1), "ACCRUAL", "ACCRUALS", "USER", model.Money(50000), "123", int64(0), sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+				mock.ExpectExec(queryAddPointLot).
+					WithArgs(int64(1), "123", model.Money(50000), sqlmock.AnyArg(), pointsExpireAt).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(queryIncreaseBalance).
+					WithArgs(int64(1), model.Money(50000)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(queryUpdateOrderStatus).
+					WithArgs("PROCESSED", model.Money(50000), "123").
+					WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+				mock.ExpectCommit()
+			},
+			newOrderStatuses: []model.Order{
+				{Status: "PROCESSED", Accrual: 50000, Number: "123", UserID: 1},
+				{Status: "PROCESSED", Accrual: 50000, Number: "123", UserID: 1},
+			},
+		},
+		{
+			name: "redelivery of already processed order is harmless",
+			mockBehavior: func(newOrderStatuses []model.Order) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(queryUpdateOrderStatus).
+					WithArgs("PROCESSED", model.Money(50000), "123").
+					WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+				mock.ExpectCommit()
+			},
+			newOrderStatuses: []model.Order{
+				{Status: "PROCESSED", Accrual: 50000, Number: "123", UserID: 1},
 			},
 		},
 		{
 			name: "err on begin tx",
-			mockBehavior: func(newOrderStatuses []model.Order, wantErrOnUpdatingThisOrderIndex int) {
+			mockBehavior: func(newOrderStatuses []model.Order) {
 				mock.ExpectBegin().WillReturnError(errors.New("unexpected err"))
 			},
 			newOrderStatuses: []model.Order{
-				{Status: "PROCESSED", Accrual: 22.33, Number: "123"},
-				{Status: "PROCESSING", Accrual: 33.22, Number: "321"},
+				{Status: "PROCESSED", Accrual: 2233, Number: "123"},
 			},
 			err:     "unexpected err",
 			wantErr: true,
 		},
 		{
-			name: "err on updating order on index [N]",
-			mockBehavior: func(newOrderStatuses []model.Order, wantErrOnUpdatingThisOrderIndex int) {
+			name: "err on updating order",
+			mockBehavior: func(newOrderStatuses []model.Order) {
 				mock.ExpectBegin()
-				for i, order := range newOrderStatuses {
-					if i == wantErrOnUpdatingThisOrderIndex {
-						mock.ExpectExec(queryUpdateOrderStatus).
-							WithArgs(order.Status, order.Accrual, order.Number).
-							WillReturnError(errors.New("unexpected error"))
-						mock.ExpectRollback()
-						return
-					}
-					mock.ExpectExec(queryUpdateOrderStatus).
-						WithArgs(order.Status, order.Accrual, order.Number).
-						WillReturnResult(sqlmock.NewResult(0, 0))
-					mock.ExpectExec(queryIncreaseBalance).
-						WithArgs(order.UserID, order.Accrual).
-						WillReturnResult(sqlmock.NewResult(0, 0))
-				}
-				mock.ExpectCommit()
+				mock.ExpectQuery(queryUpdateOrderStatus).
+					WithArgs("PROCESSED", model.Money(2233), "123").
+					WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(1))
+				mock.ExpectQuery(queryAddPosting).
+					WithArgs(int64(1), "ACCRUAL", "ACCRUALS", "USER", model.Money(2233), "123", int64(0), sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+				mock.ExpectExec(queryAddPointLot).
+					WithArgs(int64(1), "123", model.Money(2233), sqlmock.AnyArg(), pointsExpireAt).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(queryIncreaseBalance).
+					WithArgs(int64(1), model.Money(2233)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(queryUpdateOrderStatus).
+					WithArgs("PROCESSING", model.Money(0), "321").
+					WillReturnError(errors.New("unexpected error"))
+				mock.ExpectRollback()
+			},
+			newOrderStatuses: []model.Order{
+				{Status: "PROCESSED", Accrual: 2233, Number: "123"},
+				{Status: "PROCESSING", Number: "321"},
+			},
+			err:     "unexpected error",
+			wantErr: true,
+		},
+		{
+			name: "err on adding posting",
+			mockBehavior: func(newOrderStatuses []model.Order) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(queryUpdateOrderStatus).
+					WithArgs("PROCESSED", model.Money(2233), "123").
+					WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(1))
+				mock.ExpectQuery(queryAddPosting).
+					WithArgs(int64(1), "ACCRUAL", "ACCRUALS", "USER", model.Money(2233), "123", int64(0), sqlmock.AnyArg()).
+					WillReturnError(errors.New("unexpected error"))
+				mock.ExpectRollback()
 			},
-			wantErrOnUpdatingThisOrderIndex: 1,
 			newOrderStatuses: []model.Order{
-				{Status: "PROCESSED", Accrual: 22.33, Number: "123"},
-				{Status: "PROCESSING", Accrual: 33.22, Number: "321"},
+				{Status: "PROCESSED", Accrual: 2233, Number: "123"},
+			},
+			err:     "unexpected error",
+			wantErr: true,
+		},
+		{
+			name: "err on adding point lot",
+			mockBehavior: func(newOrderStatuses []model.Order) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(queryUpdateOrderStatus).
+					WithArgs("PROCESSED", model.Money(2233), "123").
+					WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(1))
+				mock.ExpectQuery(queryAddPosting).
+					WithArgs(int64(1), "ACCRUAL", "ACCRUALS", "USER", model.Money(2233), "123", int64(0), sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+				mock.ExpectExec(queryAddPointLot).
+					WithArgs(int64(1), "123", model.Money(2233), sqlmock.AnyArg(), pointsExpireAt).
+					WillReturnError(errors.New("unexpected error"))
+				mock.ExpectRollback()
+			},
+			newOrderStatuses: []model.Order{
+				{Status: "PROCESSED", Accrual: 2233, Number: "123"},
+			},
+			err:     "adding point lot: unexpected error",
+			wantErr: true,
+		},
+		{
+			name: "err on increasing balance",
+			mockBehavior: func(newOrderStatuses []model.Order) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(queryUpdateOrderStatus).
+					WithArgs("PROCESSED", model.Money(2233), "123").
+					WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(1))
+				mock.ExpectQuery(queryAddPosting).
+					WithArgs(int64(1), "ACCRUAL", "ACCRUALS", "USER", model.Money(2233), "123", int64(0), sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+				mock.ExpectExec(queryAddPointLot).
+					WithArgs(int64(1), "123", model.Money(2233), sqlmock.AnyArg(), pointsExpireAt).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(queryIncreaseBalance).
+					WithArgs(int64(1), model.Money(2233)).
+					WillReturnError(errors.New("unexpected error"))
+				mock.ExpectRollback()
+			},
+			newOrderStatuses: []model.Order{
+				{Status: "PROCESSED", Accrual: 2233, Number: "123"},
 			},
 			err:     "unexpected error",
 			wantErr: true,
@@ -422,13 +720,9 @@ func TestPg_UpdateOrderStatuses(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if tt.wantErrOnUpdatingThisOrderIndex == 0 {
-				tt.mockBehavior(tt.newOrderStatuses, -1)
-			} else {
-				tt.mockBehavior(tt.newOrderStatuses, tt.wantErrOnUpdatingThisOrderIndex)
-			}
+			tt.mockBehavior(tt.newOrderStatuses)
 
-			err := testPg.UpdateOrderStatuses(tt.newOrderStatuses)
+			err := testPg.UpdateOrderStatuses(tt.newOrderStatuses, pointsExpireAt)
 			if tt.wantErr {
 				assert.Error(t, err)
 				assert.ErrorContains(t, err, tt.err)
@@ -21,12 +21,17 @@ const (
 )
 
 type Pg struct {
-	db                  *sql.DB
-	usersStmts          *usersStmts
-	ordersStmts         *ordersStmts
-	refreshSessionStmts *refreshSessionStmts
-	balanceStmts        *balanceStmts
-	withdrawalsStmts    *withdrawalsStmts
+	db                   *sql.DB
+	usersStmts           *usersStmts
+	ordersStmts          *ordersStmts
+	refreshSessionStmts  *refreshSessionStmts
+	balanceStmts         *balanceStmts
+	withdrawalsStmts     *withdrawalsStmts
+	ledgerStmts          *ledgerStmts
+	pointLotsStmts       *pointLotsStmts
+	idempotencyKeysStmts *idempotencyKeysStmts
+	holdsStmts           *holdsStmts
+	sessionsStmts        *sessionsStmts
 }
 
 func New(pgConn string) (*Pg, error) {
@@ -79,6 +84,26 @@ func New(pgConn string) (*Pg, error) {
 		return nil, err
 	}
 
+	if err = prepareLedgerStmts(ctx, &newPg); err != nil {
+		return nil, err
+	}
+
+	if err = preparePointLotsStmts(ctx, &newPg); err != nil {
+		return nil, err
+	}
+
+	if err = prepareIdempotencyKeysStmts(ctx, &newPg); err != nil {
+		return nil, err
+	}
+
+	if err = prepareHoldsStmts(ctx, &newPg); err != nil {
+		return nil, err
+	}
+
+	if err = prepareSessionsStmts(ctx, &newPg); err != nil {
+		return nil, err
+	}
+
 	return &newPg, nil
 }
 
@@ -113,6 +138,26 @@ func (p *Pg) Close() error {
 		return fmt.Errorf("closing withdrawals stmts: %w", err)
 	}
 
+	if err = p.ledgerStmts.Close(); err != nil {
+		return fmt.Errorf("closing ledger stmts: %w", err)
+	}
+
+	if err = p.pointLotsStmts.Close(); err != nil {
+		return fmt.Errorf("closing point lots stmts: %w", err)
+	}
+
+	if err = p.idempotencyKeysStmts.Close(); err != nil {
+		return fmt.Errorf("closing idempotency keys stmts: %w", err)
+	}
+
+	if err = p.holdsStmts.Close(); err != nil {
+		return fmt.Errorf("closing holds stmts: %w", err)
+	}
+
+	if err = p.sessionsStmts.Close(); err != nil {
+		return fmt.Errorf("closing sessions stmts: %w", err)
+	}
+
 	err = p.db.Close()
 	if err != nil {
 		return fmt.Errorf("closing db connection: %w", err)
@@ -0,0 +1,181 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"practicum-gophermart/internal/model"
+)
+
+// pointLotsStmts are statements of the lots of accrued points.
+// The sum of the remaining of the user lots equals the balance of the user, so the lots are changed
+// in the same transaction as the balance.
+type pointLotsStmts struct {
+	stmtAddPointLot      *sql.Stmt
+	stmtConsumePointLots *sql.Stmt
+	stmtExpirePointLots  *sql.Stmt
+	stmtRestorePointLot  *sql.Stmt
+}
+
+func preparePointLotsStmts(ctx context.Context, p *Pg) error {
+
+	newPointLotsStmts := pointLotsStmts{}
+
+	var err error
+
+	if newPointLotsStmts.stmtAddPointLot, err = p.db.PrepareContext(ctx, queryAddPointLot); err != nil {
+		return err
+	}
+
+	if newPointLotsStmts.stmtConsumePointLots, err = p.db.PrepareContext(ctx, queryConsumePointLots); err != nil {
+		return err
+	}
+
+	if newPointLotsStmts.stmtExpirePointLots, err = p.db.PrepareContext(ctx, queryExpirePointLots); err != nil {
+		return err
+	}
+
+	if newPointLotsStmts.stmtRestorePointLot, err = p.db.PrepareContext(ctx, queryRestorePointLot); err != nil {
+		return err
+	}
+
+	p.pointLotsStmts = &newPointLotsStmts
+
+	return nil
+}
+
+// addPointLot adds the lot of accrued points within the transaction.
+func (p *Pg) addPointLot(ctx context.Context, tx *sql.Tx, lot model.PointLot) error {
+	_, err := tx.StmtContext(ctx, p.pointLotsStmts.stmtAddPointLot).ExecContext(ctx, lot.UserID, lot.OrderNumber, lot.Amount,
+		lot.AccruedAt, lot.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("adding point lot: %w", err)
+	}
+	return nil
+}
+
+// consumePointLots consumes the amount from the user lots within the transaction, the oldest lots first.
+func (p *Pg) consumePointLots(ctx context.Context, tx *sql.Tx, userID int64, amount model.Money) error {
+	_, err := tx.StmtContext(ctx, p.pointLotsStmts.stmtConsumePointLots).ExecContext(ctx, userID, amount)
+	if err != nil {
+		return fmt.Errorf("consuming point lots: %w", err)
+	}
+	return nil
+}
+
+// ExpirePointLots expires the remaining of no more than limit lots expired by expiredAt
+// and returns the expired lots with their expired remaining.
+// The remaining is posted to the ledger as an expiry and is deducted from the balance of the user.
+// The points held for the pending payments are not expired: the lot keeps them until the hold is captured
+// or the next run expires them after the hold is released. The lots with nothing expired are not returned.
+func (p *Pg) ExpirePointLots(ctx context.Context, expiredAt time.Time, limit int) (lots []model.PointLot, err error) {
+	log.Debug().Msg("Pg.ExpirePointLots START")
+	defer func() {
+		if err != nil {
+			log.Error().Err(err).Msg("Pg.ExpirePointLots END")
+		} else {
+			log.Debug().Msg("Pg.ExpirePointLots END")
+		}
+	}()
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if errTxRollback := tx.Rollback(); errTxRollback != nil && !errors.Is(errTxRollback, sql.ErrTxDone) {
+			log.Error().Err(errTxRollback).Msg("tx rollback")
+		}
+	}()
+
+	if lots, err = p.expirePointLots(ctx, tx, expiredAt, limit); err != nil {
+		return nil, err
+	}
+
+	var expiredLots []model.PointLot
+	for _, lot := range lots {
+		var expired model.Money
+		err = tx.StmtContext(ctx, p.balanceStmts.stmtExpireBalance).QueryRowContext(ctx, lot.UserID, lot.Remaining).
+			Scan(&expired)
+		if err != nil {
+			return nil, err
+		}
+
+		if expired < lot.Remaining {
+			_, err = tx.StmtContext(ctx, p.pointLotsStmts.stmtRestorePointLot).ExecContext(ctx, lot.ID, lot.Remaining-expired)
+			if err != nil {
+				return nil, fmt.Errorf("restoring point lot: %w", err)
+			}
+		}
+		if expired == 0 {
+			continue
+		}
+
+		if _, err = p.addPosting(ctx, tx, model.NewExpiryPosting(lot.UserID, lot.OrderNumber, expired, expiredAt)); err != nil {
+			return nil, err
+		}
+		lot.Remaining = expired
+		expiredLots = append(expiredLots, lot)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return expiredLots, nil
+}
+
+// expirePointLots zeroes the remaining of the expired lots within the transaction.
+// The rows are read up before the postings are added, the transaction runs one statement at a time.
+func (p *Pg) expirePointLots(ctx context.Context, tx *sql.Tx, expiredAt time.Time, limit int) (lots []model.PointLot, err error) {
+	rows, err := tx.StmtContext(ctx, p.pointLotsStmts.stmtExpirePointLots).QueryContext(ctx, expiredAt, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if errRowsClose := rows.Close(); errRowsClose != nil {
+			log.Error().Err(errRowsClose).Msg("closing sql rows")
+		}
+	}()
+
+	for rows.Next() {
+		lot := model.PointLot{}
+		if err = rows.Scan(&lot.ID, &lot.UserID, &lot.OrderNumber, &lot.Amount, &lot.Remaining, &lot.AccruedAt,
+			&lot.ExpiresAt); err != nil {
+			return nil, err
+		}
+		lots = append(lots, lot)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return lots, nil
+}
+
+func (l *pointLotsStmts) Close() (err error) {
+
+	if err = l.stmtAddPointLot.Close(); err != nil {
+		return fmt.Errorf("closing stmt 'AddPointLot' : %w", err)
+	}
+
+	if err = l.stmtConsumePointLots.Close(); err != nil {
+		return fmt.Errorf("closing stmt 'ConsumePointLots' : %w", err)
+	}
+
+	if err = l.stmtExpirePointLots.Close(); err != nil {
+		return fmt.Errorf("closing stmt 'ExpirePointLots' : %w", err)
+	}
+
+	if err = l.stmtRestorePointLot.Close(); err != nil {
+		return fmt.Errorf("closing stmt 'RestorePointLot' : %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package pg
+
+const queryAddPointLot = `
+INSERT INTO point_lots (user_id, order_number, amount, remaining, accrued_at, expires_at)
+VALUES ($1, $2, $3, $3, $4, $5)
+`
+
+// queryConsumePointLots consumes the amount from the remaining of the user lots, the oldest lots first.
+// Every lot gives the part of the amount which is not covered by the older lots.
+const queryConsumePointLots = `
+WITH locked AS (
+	SELECT id, remaining, accrued_at
+	FROM point_lots
+	WHERE user_id = $1 AND remaining > 0
+	ORDER BY accrued_at, id
+	FOR UPDATE
+), consumed AS (
+	SELECT
+		id,
+		LEAST(remaining, GREATEST($2 - (SUM(remaining) OVER (ORDER BY accrued_at, id) - remaining), 0)) AS amount
+	FROM locked
+)
+UPDATE point_lots SET remaining = point_lots.remaining - consumed.amount
+FROM consumed
+WHERE point_lots.id = consumed.id AND consumed.amount > 0
+`
+
+// queryRestorePointLot sets the remaining of the lot back, it keeps the points of the lot which are not expired.
+const queryRestorePointLot = `UPDATE point_lots SET remaining = $2 WHERE id = $1`
+
+// queryExpirePointLots zeroes the remaining of no more than $2 lots expired by $1 and returns the expired remaining.
+// The lots locked by withdrawals are skipped until the next run.
+const queryExpirePointLots = `
+UPDATE point_lots SET remaining = 0
+FROM (
+	SELECT id, remaining
+	FROM point_lots
+	WHERE remaining > 0 AND expires_at <= $1
+	ORDER BY expires_at, id
+	LIMIT $2
+	FOR UPDATE SKIP LOCKED
+) AS expired
+WHERE point_lots.id = expired.id
+RETURNING
+	point_lots.id, point_lots.user_id, COALESCE(point_lots.order_number, ''), point_lots.amount, expired.remaining,
+	point_lots.accrued_at, point_lots.expires_at
+`
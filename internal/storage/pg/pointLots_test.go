@@ -0,0 +1,198 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"practicum-gophermart/internal/model"
+)
+
+func TestPg_ExpirePointLots(t *testing.T) {
+	testPg := Pg{}
+	testPg.pointLotsStmts = &pointLotsStmts{}
+	testPg.balanceStmts = &balanceStmts{}
+	testPg.ledgerStmts = &ledgerStmts{}
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	testPg.db = db
+	mock.ExpectPrepare(queryExpirePointLots)
+	if testPg.pointLotsStmts.stmtExpirePointLots, err = testPg.db.PrepareContext(context.Background(), queryExpirePointLots); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing create user statement", err)
+	}
+	mock.ExpectPrepare(queryRestorePointLot)
+	if testPg.pointLotsStmts.stmtRestorePointLot, err = testPg.db.PrepareContext(context.Background(), queryRestorePointLot); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing create user statement", err)
+	}
+	mock.ExpectPrepare(queryExpireBalance)
+	if testPg.balanceStmts.stmtExpireBalance, err = testPg.db.PrepareContext(context.Background(), queryExpireBalance); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing create user statement", err)
+	}
+	mock.ExpectPrepare(queryAddPosting)
+	if testPg.ledgerStmts.stmtAddPosting, err = testPg.db.PrepareContext(context.Background(), queryAddPosting); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing create user statement", err)
+	}
+
+	expiredAt := time.Now()
+	accruedAt := expiredAt.AddDate(-1, 0, 0)
+	lotsColumns := []string{"id", "user_id", "order_number", "amount", "remaining", "accrued_at", "expires_at"}
+
+	tests := []struct {
+		name         string
+		mockBehavior func()
+		expected     []model.PointLot
+		err          string
+		wantErr      bool
+	}{
+		{
+			name: "OK",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(queryExpirePointLots).
+					WithArgs(expiredAt, 100).
+					WillReturnRows(sqlmock.NewRows(lotsColumns).
+						AddRow(1, 1, "123", "500", "120.5", accruedAt, expiredAt).
+						AddRow(2, 2, "", "10", "10", accruedAt, expiredAt))
+				mock.ExpectQuery(queryExpireBalance).
+					WithArgs(int64(1), model.Money(12050)).
+					WillReturnRows(sqlmock.NewRows([]string{"expired"}).AddRow("120.5"))
+				mock.ExpectQuery(queryAddPosting).
+					WithArgs(int64(1), "EXPIRY", "USER", "EXPIRED", model.Money(12050), "123", int64(0), expiredAt).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+				mock.ExpectQuery(queryExpireBalance).
+					WithArgs(int64(2), model.Money(1000)).
+					WillReturnRows(sqlmock.NewRows([]string{"expired"}).AddRow("10"))
+				mock.ExpectQuery(queryAddPosting).
+					WithArgs(int64(2), "EXPIRY", "USER", "EXPIRED", model.Money(1000), "", int64(0), expiredAt).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+				mock.ExpectCommit()
+			},
+			expected: []model.PointLot{
+				{ID: 1, UserID: 1, OrderNumber: "123", Amount: 50000, Remaining: 12050, AccruedAt: accruedAt, ExpiresAt: expiredAt},
+				{ID: 2, UserID: 2, Amount: 1000, Remaining: 1000, AccruedAt: accruedAt, ExpiresAt: expiredAt},
+			},
+		},
+		{
+			name: "points are held",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(queryExpirePointLots).
+					WithArgs(expiredAt, 100).
+					WillReturnRows(sqlmock.NewRows(lotsColumns).
+						AddRow(1, 1, "123", "500", "120.5", accruedAt, expiredAt))
+				mock.ExpectQuery(queryExpireBalance).
+					WithArgs(int64(1), model.Money(12050)).
+					WillReturnRows(sqlmock.NewRows([]string{"expired"}).AddRow("20.5"))
+				mock.ExpectExec(queryRestorePointLot).
+					WithArgs(int64(1), model.Money(10000)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(queryAddPosting).
+					WithArgs(int64(1), "EXPIRY", "USER", "EXPIRED", model.Money(2050), "123", int64(0), expiredAt).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+				mock.ExpectCommit()
+			},
+			expected: []model.PointLot{
+				{ID: 1, UserID: 1, OrderNumber: "123", Amount: 50000, Remaining: 2050, AccruedAt: accruedAt, ExpiresAt: expiredAt},
+			},
+		},
+		{
+			name: "all the points are held",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(queryExpirePointLots).
+					WithArgs(expiredAt, 100).
+					WillReturnRows(sqlmock.NewRows(lotsColumns).
+						AddRow(1, 1, "123", "500", "120.5", accruedAt, expiredAt))
+				mock.ExpectQuery(queryExpireBalance).
+					WithArgs(int64(1), model.Money(12050)).
+					WillReturnRows(sqlmock.NewRows([]string{"expired"}).AddRow("0"))
+				mock.ExpectExec(queryRestorePointLot).
+					WithArgs(int64(1), model.Money(12050)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "nothing to expire",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(queryExpirePointLots).
+					WithArgs(expiredAt, 100).
+					WillReturnRows(sqlmock.NewRows(lotsColumns))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "err on expiring lots",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(queryExpirePointLots).
+					WithArgs(expiredAt, 100).
+					WillReturnError(errors.New("unexpected error"))
+				mock.ExpectRollback()
+			},
+			err:     "unexpected error",
+			wantErr: true,
+		},
+		{
+			name: "err on adding posting",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(queryExpirePointLots).
+					WithArgs(expiredAt, 100).
+					WillReturnRows(sqlmock.NewRows(lotsColumns).
+						AddRow(1, 1, "123", "500", "120.5", accruedAt, expiredAt))
+				mock.ExpectQuery(queryExpireBalance).
+					WithArgs(int64(1), model.Money(12050)).
+					WillReturnRows(sqlmock.NewRows([]string{"expired"}).AddRow("120.5"))
+				mock.ExpectQuery(queryAddPosting).
+					WithArgs(int64(1), "EXPIRY", "USER", "EXPIRED", model.Money(12050), "123", int64(0), expiredAt).
+					WillReturnError(errors.New("unexpected error"))
+				mock.ExpectRollback()
+			},
+			err:     "adding EXPIRY posting: unexpected error",
+			wantErr: true,
+		},
+		{
+			name: "err on reducing balance",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(queryExpirePointLots).
+					WithArgs(expiredAt, 100).
+					WillReturnRows(sqlmock.NewRows(lotsColumns).
+						AddRow(1, 1, "123", "500", "120.5", accruedAt, expiredAt))
+				mock.ExpectQuery(queryExpireBalance).
+					WithArgs(int64(1), model.Money(12050)).
+					WillReturnError(errors.New("unexpected error"))
+				mock.ExpectRollback()
+			},
+			err:     "unexpected error",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockBehavior()
+			lots, err := testPg.ExpirePointLots(context.Background(), expiredAt, 100)
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.ErrorContains(t, err, tt.err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, lots)
+			}
+			if err = mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
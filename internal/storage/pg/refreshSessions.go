@@ -2,9 +2,12 @@ package pg
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/rs/zerolog/log"
 
@@ -12,10 +15,16 @@ import (
 	dberr "practicum-gophermart/internal/storage/errors"
 )
 
+// refreshSessionStmts are statements of the refresh sessions.
+// Only the hash of the refresh token is stored. The rotated sessions are kept in their family
+// until they expire, so the reuse of the rotated token is detected and the whole family is revoked.
 type refreshSessionStmts struct {
-	stmtAddRefreshSession        *sql.Stmt
-	stmtDeleteRefreshSession     *sql.Stmt
-	stmtGetRefreshSessionByToken *sql.Stmt
+	stmtAddRefreshSession           *sql.Stmt
+	stmtDeleteExpiredRefreshSession *sql.Stmt
+	stmtTakeRefreshSession          *sql.Stmt
+	stmtGetRefreshSessionState      *sql.Stmt
+	stmtRevokeRefreshSessionFamily  *sql.Stmt
+	stmtRevokeUserRefreshSessions   *sql.Stmt
 }
 
 func prepareRefreshSessionStmts(ctx context.Context, p *Pg) error {
@@ -28,11 +37,23 @@ func prepareRefreshSessionStmts(ctx context.Context, p *Pg) error {
 		return err
 	}
 
-	if newRefreshSessionStmts.stmtDeleteRefreshSession, err = p.db.PrepareContext(ctx, queryDeleteRefreshSessions); err != nil {
+	if newRefreshSessionStmts.stmtDeleteExpiredRefreshSession, err = p.db.PrepareContext(ctx, queryDeleteExpiredRefreshSessions); err != nil {
 		return err
 	}
 
-	if newRefreshSessionStmts.stmtGetRefreshSessionByToken, err = p.db.PrepareContext(ctx, queryGetRefreshSessionByToken); err != nil {
+	if newRefreshSessionStmts.stmtTakeRefreshSession, err = p.db.PrepareContext(ctx, queryTakeRefreshSession); err != nil {
+		return err
+	}
+
+	if newRefreshSessionStmts.stmtGetRefreshSessionState, err = p.db.PrepareContext(ctx, queryGetRefreshSessionState); err != nil {
+		return err
+	}
+
+	if newRefreshSessionStmts.stmtRevokeRefreshSessionFamily, err = p.db.PrepareContext(ctx, queryRevokeRefreshSessionFamily); err != nil {
+		return err
+	}
+
+	if newRefreshSessionStmts.stmtRevokeUserRefreshSessions, err = p.db.PrepareContext(ctx, queryRevokeUserRefreshSessions); err != nil {
 		return err
 	}
 
@@ -41,6 +62,9 @@ func prepareRefreshSessionStmts(ctx context.Context, p *Pg) error {
 	return nil
 }
 
+// UpdateRefreshSession adds the refresh session which starts a new family on the device of the user
+// and deletes the expired refresh sessions and the ended sessions of the user.
+// The other sessions of the user are kept, so the user can be signed in on several devices at once.
 func (p *Pg) UpdateRefreshSession(ctx context.Context, newRefreshSession *model.RefreshSession) error {
 	log.Debug().Str("UserID", fmt.Sprint(newRefreshSession.UserID)).Msg("Pg.UpdateRefreshSession START")
 	var err error
@@ -62,19 +86,24 @@ func (p *Pg) UpdateRefreshSession(ctx context.Context, newRefreshSession *model.
 		}
 	}()
 
-	_, err = tx.StmtContext(ctx, p.refreshSessionStmts.stmtDeleteRefreshSession).ExecContext(ctx, newRefreshSession.UserID)
+	_, err = tx.StmtContext(ctx, p.refreshSessionStmts.stmtDeleteExpiredRefreshSession).ExecContext(ctx, newRefreshSession.UserID)
 	if err != nil {
 		return err
 	}
 
-	_, err = tx.StmtContext(ctx, p.refreshSessionStmts.stmtAddRefreshSession).ExecContext(ctx,
-		newRefreshSession.UserID,
-		newRefreshSession.Token,
-		newRefreshSession.ExpiresIn)
+	_, err = tx.StmtContext(ctx, p.sessionsStmts.stmtDeleteEndedSessions).ExecContext(ctx, newRefreshSession.UserID)
 	if err != nil {
 		return err
 	}
 
+	if err = p.addSession(ctx, tx, newRefreshSession, time.Now()); err != nil {
+		return err
+	}
+
+	if err = p.addRefreshSession(ctx, tx, newRefreshSession); err != nil {
+		return err
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		return err
@@ -83,27 +112,103 @@ func (p *Pg) UpdateRefreshSession(ctx context.Context, newRefreshSession *model.
 	return nil
 }
 
-func (p *Pg) GetRefreshSessionByToken(c context.Context, token string) (*model.RefreshSession, error) {
-	log.Debug().Msg("Pg.GetRefreshSessionByToken START")
-	var err error
+// RotateRefreshSession replaces the refresh session of the token with the new one of the same user and family.
+// The user and the family of the session are set to the new session. ErrRefreshSessionIsNotExists is returned
+// if the token is unknown, expired by rotatedAt or revoked. If the token was already rotated, it is considered stolen:
+// the whole family is revoked and ErrRefreshTokenIsReused is returned.
+func (p *Pg) RotateRefreshSession(ctx context.Context, refreshToken string, rotatedAt time.Time,
+	newRefreshSession *model.RefreshSession) (err error) {
+	log.Debug().Msg("Pg.RotateRefreshSession START")
 	defer func() {
 		if err != nil {
-			log.Error().Err(err).Msg("Pg.GetRefreshSessionByToken END")
+			log.Error().Err(err).Msg("Pg.RotateRefreshSession END")
 		} else {
-			log.Debug().Msg("Pg.GetRefreshSessionByToken END")
+			log.Debug().Msg("Pg.RotateRefreshSession END")
+		}
+	}()
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if errTxRollback := tx.Rollback(); errTxRollback != nil && !errors.Is(errTxRollback, sql.ErrTxDone) {
+			log.Error().Err(errTxRollback).Msg("tx rollback")
 		}
 	}()
 
-	var refreshSession model.RefreshSession
-	err = p.refreshSessionStmts.stmtGetRefreshSessionByToken.QueryRowContext(c, token).Scan(&refreshSession.UserID, &refreshSession.ExpiresIn)
+	tokenHash := hashRefreshToken(refreshToken)
+	err = tx.StmtContext(ctx, p.refreshSessionStmts.stmtTakeRefreshSession).QueryRowContext(ctx, tokenHash, rotatedAt).
+		Scan(&newRefreshSession.UserID, &newRefreshSession.FamilyID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf(`pg: %w: %s`, dberr.ErrRefreshSessionIsNotExists, err)
+			return p.refreshSessionNotRotatedErr(ctx, tx, tokenHash, rotatedAt)
 		}
-		return nil, err
+		return err
+	}
+
+	if err = p.touchSession(ctx, tx, newRefreshSession, rotatedAt); err != nil {
+		return err
+	}
+
+	if err = p.addRefreshSession(ctx, tx, newRefreshSession); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
 	}
 
-	return &refreshSession, nil
+	return nil
+}
+
+// refreshSessionNotRotatedErr explains why the refresh session of the token was not rotated.
+// The family of the reused token and its session are revoked and the transaction is committed.
+func (p *Pg) refreshSessionNotRotatedErr(ctx context.Context, tx *sql.Tx, tokenHash string, revokedAt time.Time) error {
+	var (
+		familyID string
+		rotated  bool
+	)
+	err := tx.StmtContext(ctx, p.refreshSessionStmts.stmtGetRefreshSessionState).QueryRowContext(ctx, tokenHash).
+		Scan(&familyID, &rotated)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf(`pg: %w: %s`, dberr.ErrRefreshSessionIsNotExists, err)
+		}
+		return err
+	}
+
+	if !rotated {
+		return dberr.ErrRefreshSessionIsNotExists
+	}
+
+	_, err = tx.StmtContext(ctx, p.refreshSessionStmts.stmtRevokeRefreshSessionFamily).ExecContext(ctx, familyID, revokedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.StmtContext(ctx, p.sessionsStmts.stmtRevokeFamilySession).ExecContext(ctx, familyID, revokedAt)
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	log.Warn().Str("family_id", familyID).Msg("rotated refresh token is reused, the token family is revoked")
+
+	return dberr.ErrRefreshTokenIsReused
+}
+
+// addRefreshSession adds the refresh session within the transaction.
+func (p *Pg) addRefreshSession(ctx context.Context, tx *sql.Tx, refreshSession *model.RefreshSession) error {
+	_, err := tx.StmtContext(ctx, p.refreshSessionStmts.stmtAddRefreshSession).ExecContext(ctx,
+		refreshSession.UserID,
+		refreshSession.FamilyID,
+		hashRefreshToken(refreshSession.Token),
+		refreshSession.ExpiresIn)
+	return err
 }
 
 func (r *refreshSessionStmts) Close() (err error) {
@@ -112,13 +217,31 @@ func (r *refreshSessionStmts) Close() (err error) {
 		return fmt.Errorf("closing stmt 'AddRefreshSession' : %w", err)
 	}
 
-	if err = r.stmtDeleteRefreshSession.Close(); err != nil {
-		return fmt.Errorf("closing stmt 'DeleteRefreshSession' : %w", err)
+	if err = r.stmtDeleteExpiredRefreshSession.Close(); err != nil {
+		return fmt.Errorf("closing stmt 'DeleteExpiredRefreshSession' : %w", err)
 	}
 
-	if err = r.stmtGetRefreshSessionByToken.Close(); err != nil {
-		return fmt.Errorf("closing stmt 'GetRefreshSessionByToken' : %w", err)
+	if err = r.stmtTakeRefreshSession.Close(); err != nil {
+		return fmt.Errorf("closing stmt 'TakeRefreshSession' : %w", err)
+	}
+
+	if err = r.stmtGetRefreshSessionState.Close(); err != nil {
+		return fmt.Errorf("closing stmt 'GetRefreshSessionState' : %w", err)
+	}
+
+	if err = r.stmtRevokeRefreshSessionFamily.Close(); err != nil {
+		return fmt.Errorf("closing stmt 'RevokeRefreshSessionFamily' : %w", err)
+	}
+
+	if err = r.stmtRevokeUserRefreshSessions.Close(); err != nil {
+		return fmt.Errorf("closing stmt 'RevokeUserRefreshSessions' : %w", err)
 	}
 
 	return nil
 }
+
+// hashRefreshToken returns the hex encoded SHA-256 of the refresh token, the same as the migration of the raw tokens does.
+func hashRefreshToken(refreshToken string) string {
+	hash := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(hash[:])
+}
@@ -1,9 +1,24 @@
 package pg
 
 const (
-	queryAddRefreshSession = `INSERT INTO refreshsessions (user_id, refreshToken, expiresIn) VALUES ($1, $2, $3)`
+	queryAddRefreshSession = `INSERT INTO refreshsessions (user_id, family_id, token_hash, expiresIn) VALUES ($1, $2, $3, $4)`
 
-	queryDeleteRefreshSessions = `DELETE FROM refreshsessions WHERE user_id = $1`
+	queryDeleteExpiredRefreshSessions = `DELETE FROM refreshsessions WHERE user_id = $1 AND expiresIn <= now()`
 
-	queryGetRefreshSessionByToken = `SELECT user_id, expiresIn FROM refreshsessions WHERE refreshToken = $1`
+	// queryTakeRefreshSession marks the active refresh session as rotated if it is not expired
+	// and returns its user and family, so the refresh token can be used only once.
+	queryTakeRefreshSession = `
+UPDATE refreshsessions SET
+	rotated_at = $2
+WHERE
+	token_hash = $1 AND rotated_at IS NULL AND revoked_at IS NULL AND expiresIn > $2
+RETURNING
+	user_id, family_id
+`
+
+	queryGetRefreshSessionState = `SELECT family_id, rotated_at IS NOT NULL AS rotated FROM refreshsessions WHERE token_hash = $1`
+
+	queryRevokeRefreshSessionFamily = `UPDATE refreshsessions SET revoked_at = $2 WHERE family_id = $1 AND revoked_at IS NULL`
+
+	queryRevokeUserRefreshSessions = `UPDATE refreshsessions SET revoked_at = $2 WHERE user_id = $1 AND revoked_at IS NULL`
 )
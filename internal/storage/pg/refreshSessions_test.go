@@ -2,7 +2,6 @@ package pg
 
 import (
 	"context"
-	"database/sql"
 	"errors"
 	"net/http/httptest"
 	"testing"
@@ -19,6 +18,7 @@ import (
 func TestPg_UpdateRefreshSession(t *testing.T) {
 	testPg := Pg{}
 	testPg.refreshSessionStmts = &refreshSessionStmts{}
+	testPg.sessionsStmts = &sessionsStmts{}
 
 	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 	if err != nil {
@@ -27,14 +27,22 @@ func TestPg_UpdateRefreshSession(t *testing.T) {
 	defer db.Close()
 
 	testPg.db = db
-	mock.ExpectPrepare(queryDeleteRefreshSessions)
-	if testPg.refreshSessionStmts.stmtDeleteRefreshSession, err = testPg.db.PrepareContext(context.Background(), queryDeleteRefreshSessions); err != nil {
+	mock.ExpectPrepare(queryDeleteExpiredRefreshSessions)
+	if testPg.refreshSessionStmts.stmtDeleteExpiredRefreshSession, err = testPg.db.PrepareContext(context.Background(), queryDeleteExpiredRefreshSessions); err != nil {
 		t.Fatalf("an error '%s' was not expected when preparing create user statement", err)
 	}
 	mock.ExpectPrepare(queryAddRefreshSession)
 	if testPg.refreshSessionStmts.stmtAddRefreshSession, err = testPg.db.PrepareContext(context.Background(), queryAddRefreshSession); err != nil {
 		t.Fatalf("an error '%s' was not expected when preparing create user statement", err)
 	}
+	mock.ExpectPrepare(queryDeleteEndedSessions)
+	if testPg.sessionsStmts.stmtDeleteEndedSessions, err = testPg.db.PrepareContext(context.Background(), queryDeleteEndedSessions); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing delete ended sessions statement", err)
+	}
+	mock.ExpectPrepare(queryAddSession)
+	if testPg.sessionsStmts.stmtAddSession, err = testPg.db.PrepareContext(context.Background(), queryAddSession); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing add session statement", err)
+	}
 
 	testPg.db = db
 
@@ -49,17 +57,26 @@ func TestPg_UpdateRefreshSession(t *testing.T) {
 			name: "OK",
 			mockBehavior: func(refreshSession *model.RefreshSession) {
 				mock.ExpectBegin()
-				mock.ExpectExec(queryDeleteRefreshSessions).
+				mock.ExpectExec(queryDeleteExpiredRefreshSessions).
+					WithArgs(&refreshSession.UserID).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(queryDeleteEndedSessions).
 					WithArgs(&refreshSession.UserID).
 					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(queryAddSession).
+					WithArgs(&refreshSession.FamilyID, &refreshSession.UserID, &refreshSession.UserAgent, &refreshSession.IP, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
 				mock.ExpectExec(queryAddRefreshSession).
-					WithArgs(&refreshSession.UserID, &refreshSession.Token, &refreshSession.ExpiresIn).
+					WithArgs(&refreshSession.UserID, &refreshSession.FamilyID, hashRefreshToken(refreshSession.Token), &refreshSession.ExpiresIn).
 					WillReturnResult(sqlmock.NewResult(0, 0))
 				mock.ExpectCommit()
 			},
 			refreshSession: &model.RefreshSession{
 				UserID:    1,
+				FamilyID:  "3",
 				Token:     "2",
+				UserAgent: "Mozilla/5.0",
+				IP:        "127.0.0.1",
 				ExpiresIn: time.Time{},
 			},
 		},
@@ -71,7 +88,10 @@ func TestPg_UpdateRefreshSession(t *testing.T) {
 			},
 			refreshSession: &model.RefreshSession{
 				UserID:    1,
+				FamilyID:  "3",
 				Token:     "2",
+				UserAgent: "Mozilla/5.0",
+				IP:        "127.0.0.1",
 				ExpiresIn: time.Time{},
 			},
 			err:     errors.New("unexpected err"),
@@ -81,14 +101,17 @@ func TestPg_UpdateRefreshSession(t *testing.T) {
 			name: "err after delete sessions",
 			mockBehavior: func(refreshSession *model.RefreshSession) {
 				mock.ExpectBegin()
-				mock.ExpectExec(queryDeleteRefreshSessions).
+				mock.ExpectExec(queryDeleteExpiredRefreshSessions).
 					WithArgs(&refreshSession.UserID).
 					WillReturnError(errors.New("unexpected err"))
 				mock.ExpectRollback()
 			},
 			refreshSession: &model.RefreshSession{
 				UserID:    1,
+				FamilyID:  "3",
 				Token:     "2",
+				UserAgent: "Mozilla/5.0",
+				IP:        "127.0.0.1",
 				ExpiresIn: time.Time{},
 			},
 			err:     errors.New("unexpected err"),
@@ -98,17 +121,26 @@ func TestPg_UpdateRefreshSession(t *testing.T) {
 			name: "err after add session",
 			mockBehavior: func(refreshSession *model.RefreshSession) {
 				mock.ExpectBegin()
-				mock.ExpectExec(queryDeleteRefreshSessions).
+				mock.ExpectExec(queryDeleteExpiredRefreshSessions).
+					WithArgs(&refreshSession.UserID).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(queryDeleteEndedSessions).
 					WithArgs(&refreshSession.UserID).
 					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(queryAddSession).
+					WithArgs(&refreshSession.FamilyID, &refreshSession.UserID, &refreshSession.UserAgent, &refreshSession.IP, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
 				mock.ExpectExec(queryAddRefreshSession).
-					WithArgs(&refreshSession.UserID, &refreshSession.Token, &refreshSession.ExpiresIn).
+					WithArgs(&refreshSession.UserID, &refreshSession.FamilyID, hashRefreshToken(refreshSession.Token), &refreshSession.ExpiresIn).
 					WillReturnError(errors.New("unexpected err"))
 				mock.ExpectRollback()
 			},
 			refreshSession: &model.RefreshSession{
 				UserID:    1,
+				FamilyID:  "3",
 				Token:     "2",
+				UserAgent: "Mozilla/5.0",
+				IP:        "127.0.0.1",
 				ExpiresIn: time.Time{},
 			},
 			err:     errors.New("unexpected err"),
@@ -132,9 +164,10 @@ func TestPg_UpdateRefreshSession(t *testing.T) {
 	}
 }
 
-func TestPg_GetRefreshSessionByToken(t *testing.T) {
+func TestPg_RotateRefreshSession(t *testing.T) {
 	testPg := Pg{}
 	testPg.refreshSessionStmts = &refreshSessionStmts{}
+	testPg.sessionsStmts = &sessionsStmts{}
 
 	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 	if err != nil {
@@ -143,57 +176,126 @@ func TestPg_GetRefreshSessionByToken(t *testing.T) {
 	defer db.Close()
 
 	testPg.db = db
-	mock.ExpectPrepare(queryGetRefreshSessionByToken)
-	if testPg.refreshSessionStmts.stmtGetRefreshSessionByToken, err = testPg.db.PrepareContext(context.Background(), queryGetRefreshSessionByToken); err != nil {
-		t.Fatalf("an error '%s' was not expected when preparing create user statement", err)
+	mock.ExpectPrepare(queryTakeRefreshSession)
+	if testPg.refreshSessionStmts.stmtTakeRefreshSession, err = testPg.db.PrepareContext(context.Background(), queryTakeRefreshSession); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing take refresh session statement", err)
+	}
+	mock.ExpectPrepare(queryAddRefreshSession)
+	if testPg.refreshSessionStmts.stmtAddRefreshSession, err = testPg.db.PrepareContext(context.Background(), queryAddRefreshSession); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing add refresh session statement", err)
+	}
+	mock.ExpectPrepare(queryGetRefreshSessionState)
+	if testPg.refreshSessionStmts.stmtGetRefreshSessionState, err = testPg.db.PrepareContext(context.Background(), queryGetRefreshSessionState); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing get refresh session state statement", err)
+	}
+	mock.ExpectPrepare(queryRevokeRefreshSessionFamily)
+	if testPg.refreshSessionStmts.stmtRevokeRefreshSessionFamily, err = testPg.db.PrepareContext(context.Background(), queryRevokeRefreshSessionFamily); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing revoke refresh session family statement", err)
+	}
+	mock.ExpectPrepare(queryTouchSession)
+	if testPg.sessionsStmts.stmtTouchSession, err = testPg.db.PrepareContext(context.Background(), queryTouchSession); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing touch session statement", err)
+	}
+	mock.ExpectPrepare(queryRevokeFamilySession)
+	if testPg.sessionsStmts.stmtRevokeFamilySession, err = testPg.db.PrepareContext(context.Background(), queryRevokeFamilySession); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing revoke family session statement", err)
 	}
 
-	testPg.db = db
+	rotatedAt := time.Now()
+	expiresIn := rotatedAt.Add(time.Hour)
+	oldHash := hashRefreshToken("old")
 
 	tests := []struct {
-		name         string
-		mockBehavior func(string)
-		token        string
-		expected     *model.RefreshSession
-		err          error
-		wantErr      bool
+		name             string
+		mockBehavior     func()
+		expectedUserID   int64
+		expectedFamilyID string
+		err              error
+		wantErr          bool
 	}{
 		{
 			name: "OK",
-			mockBehavior: func(token string) {
-				mock.ExpectQuery(queryGetRefreshSessionByToken).
-					WithArgs(token).
-					WillReturnRows(sqlmock.NewRows([]string{"user_id", "expiresIn"}).AddRow(1, time.Time{}))
-			},
-			expected: &model.RefreshSession{
-				UserID:    1,
-				ExpiresIn: time.Time{},
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(queryTakeRefreshSession).
+					WithArgs(oldHash, rotatedAt).
+					WillReturnRows(sqlmock.NewRows([]string{"user_id", "family_id"}).AddRow(1, "family"))
+				mock.ExpectExec(queryTouchSession).
+					WithArgs("family", rotatedAt, "Mozilla/5.0", "127.0.0.1").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(queryAddRefreshSession).
+					WithArgs(int64(1), "family", hashRefreshToken("new"), expiresIn).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
 			},
+			expectedUserID:   1,
+			expectedFamilyID: "family",
 		},
 		{
-			name: "err sql no rows",
-			mockBehavior: func(token string) {
-				mock.ExpectQuery(queryGetRefreshSessionByToken).
-					WithArgs(token).
-					WillReturnError(sql.ErrNoRows)
+			name: "session is not exists",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(queryTakeRefreshSession).
+					WithArgs(oldHash, rotatedAt).
+					WillReturnRows(sqlmock.NewRows([]string{"user_id", "family_id"}))
+				mock.ExpectQuery(queryGetRefreshSessionState).
+					WithArgs(oldHash).
+					WillReturnRows(sqlmock.NewRows([]string{"family_id", "rotated"}))
+				mock.ExpectRollback()
 			},
-			expected: &model.RefreshSession{
-				UserID:    1,
-				ExpiresIn: time.Time{},
+			err:     dberr.ErrRefreshSessionIsNotExists,
+			wantErr: true,
+		},
+		{
+			name: "session is expired or revoked",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(queryTakeRefreshSession).
+					WithArgs(oldHash, rotatedAt).
+					WillReturnRows(sqlmock.NewRows([]string{"user_id", "family_id"}))
+				mock.ExpectQuery(queryGetRefreshSessionState).
+					WithArgs(oldHash).
+					WillReturnRows(sqlmock.NewRows([]string{"family_id", "rotated"}).AddRow("family", false))
+				mock.ExpectRollback()
 			},
 			err:     dberr.ErrRefreshSessionIsNotExists,
 			wantErr: true,
 		},
 		{
-			name: "unexpected err",
-			mockBehavior: func(token string) {
-				mock.ExpectQuery(queryGetRefreshSessionByToken).
-					WithArgs(token).
-					WillReturnError(errors.New("unexpected err"))
+			name: "rotated token is reused",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(queryTakeRefreshSession).
+					WithArgs(oldHash, rotatedAt).
+					WillReturnRows(sqlmock.NewRows([]string{"user_id", "family_id"}))
+				mock.ExpectQuery(queryGetRefreshSessionState).
+					WithArgs(oldHash).
+					WillReturnRows(sqlmock.NewRows([]string{"family_id", "rotated"}).AddRow("family", true))
+				mock.ExpectExec(queryRevokeRefreshSessionFamily).
+					WithArgs("family", rotatedAt).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectExec(queryRevokeFamilySession).
+					WithArgs("family", rotatedAt).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
 			},
-			expected: &model.RefreshSession{
-				UserID:    1,
-				ExpiresIn: time.Time{},
+			err:     dberr.ErrRefreshTokenIsReused,
+			wantErr: true,
+		},
+		{
+			name: "err after add session",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(queryTakeRefreshSession).
+					WithArgs(oldHash, rotatedAt).
+					WillReturnRows(sqlmock.NewRows([]string{"user_id", "family_id"}).AddRow(1, "family"))
+				mock.ExpectExec(queryTouchSession).
+					WithArgs("family", rotatedAt, "Mozilla/5.0", "127.0.0.1").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(queryAddRefreshSession).
+					WithArgs(int64(1), "family", hashRefreshToken("new"), expiresIn).
+					WillReturnError(errors.New("unexpected err"))
+				mock.ExpectRollback()
 			},
 			err:     errors.New("unexpected err"),
 			wantErr: true,
@@ -201,14 +303,15 @@ func TestPg_GetRefreshSessionByToken(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			tt.mockBehavior(tt.token)
-			gCtx, _ := gin.CreateTestContext(httptest.NewRecorder())
-			refreshSession, err := testPg.GetRefreshSessionByToken(gCtx, tt.token)
+			tt.mockBehavior()
+			newRefreshSession := &model.RefreshSession{Token: "new", ExpiresIn: expiresIn, UserAgent: "Mozilla/5.0", IP: "127.0.0.1"}
+			err := testPg.RotateRefreshSession(context.Background(), "old", rotatedAt, newRefreshSession)
 			if tt.wantErr {
-				assert.Error(t, err)
+				assert.ErrorContains(t, err, tt.err.Error())
 			} else {
 				assert.NoError(t, err)
-				assert.Equal(t, tt.expected, refreshSession)
+				assert.Equal(t, tt.expectedUserID, newRefreshSession.UserID)
+				assert.Equal(t, tt.expectedFamilyID, newRefreshSession.FamilyID)
 			}
 			if err = mock.ExpectationsWereMet(); err != nil {
 				t.Errorf("there were unfulfilled expectations: %s", err)
@@ -216,3 +319,9 @@ func TestPg_GetRefreshSessionByToken(t *testing.T) {
 		})
 	}
 }
+
+func TestHashRefreshToken(t *testing.T) {
+	// the same as encode(sha256('0c9ab3c5-5f4e-4a0e-9d4b-6b6f3b0a8d21'::bytea), 'hex') of the migration
+	assert.Equal(t, "5e90a207397860f375cb8460d990eace0f36f837694639ccb86f13eecd466c32",
+		hashRefreshToken("0c9ab3c5-5f4e-4a0e-9d4b-6b6f3b0a8d21"))
+}
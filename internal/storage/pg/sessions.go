@@ -0,0 +1,263 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"practicum-gophermart/internal/model"
+	dberr "practicum-gophermart/internal/storage/errors"
+)
+
+// sessionsStmts are statements of the sessions of the users on their devices.
+// The id of the session is the family of its refresh tokens.
+type sessionsStmts struct {
+	stmtAddSession          *sql.Stmt
+	stmtTouchSession        *sql.Stmt
+	stmtDeleteEndedSessions *sql.Stmt
+	stmtGetSessions         *sql.Stmt
+	stmtRevokeSession       *sql.Stmt
+	stmtRevokeFamilySession *sql.Stmt
+	stmtRevokeSessions      *sql.Stmt
+	stmtIsSessionActive     *sql.Stmt
+}
+
+func prepareSessionsStmts(ctx context.Context, p *Pg) error {
+
+	newSessionsStmts := sessionsStmts{}
+
+	var err error
+
+	if newSessionsStmts.stmtAddSession, err = p.db.PrepareContext(ctx, queryAddSession); err != nil {
+		return err
+	}
+
+	if newSessionsStmts.stmtTouchSession, err = p.db.PrepareContext(ctx, queryTouchSession); err != nil {
+		return err
+	}
+
+	if newSessionsStmts.stmtDeleteEndedSessions, err = p.db.PrepareContext(ctx, queryDeleteEndedSessions); err != nil {
+		return err
+	}
+
+	if newSessionsStmts.stmtGetSessions, err = p.db.PrepareContext(ctx, queryGetSessions); err != nil {
+		return err
+	}
+
+	if newSessionsStmts.stmtRevokeSession, err = p.db.PrepareContext(ctx, queryRevokeSession); err != nil {
+		return err
+	}
+
+	if newSessionsStmts.stmtRevokeFamilySession, err = p.db.PrepareContext(ctx, queryRevokeFamilySession); err != nil {
+		return err
+	}
+
+	if newSessionsStmts.stmtRevokeSessions, err = p.db.PrepareContext(ctx, queryRevokeSessions); err != nil {
+		return err
+	}
+
+	if newSessionsStmts.stmtIsSessionActive, err = p.db.PrepareContext(ctx, queryIsSessionActive); err != nil {
+		return err
+	}
+
+	p.sessionsStmts = &newSessionsStmts
+
+	return nil
+}
+
+// GetSessions returns the active sessions of the user, the recently used first.
+func (p *Pg) GetSessions(ctx context.Context, userID int64) (sessions []model.Session, err error) {
+	log.Debug().Msg("Pg.GetSessions START")
+	defer func() {
+		if err != nil {
+			log.Error().Err(err).Msg("Pg.GetSessions END")
+		} else {
+			log.Debug().Msg("Pg.GetSessions END")
+		}
+	}()
+
+	rows, err := p.sessionsStmts.stmtGetSessions.QueryContext(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if errRowsClose := rows.Close(); errRowsClose != nil {
+			log.Error().Err(errRowsClose).Msg("closing sql rows")
+		}
+	}()
+
+	for rows.Next() {
+		session := model.Session{UserID: userID}
+		if err = rows.Scan(&session.ID, &session.UserAgent, &session.IP, &session.CreatedAt, &session.LastUsedAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession revokes the session of the user and all its refresh tokens.
+func (p *Pg) RevokeSession(ctx context.Context, userID int64, sessionID string, revokedAt time.Time) (err error) {
+	log.Debug().Str("session_id", sessionID).Msg("Pg.RevokeSession START")
+	defer func() {
+		if err != nil {
+			log.Error().Err(err).Msg("Pg.RevokeSession END")
+		} else {
+			log.Debug().Msg("Pg.RevokeSession END")
+		}
+	}()
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if errTxRollback := tx.Rollback(); errTxRollback != nil && !errors.Is(errTxRollback, sql.ErrTxDone) {
+			log.Error().Err(errTxRollback).Msg("tx rollback")
+		}
+	}()
+
+	var id string
+	err = tx.StmtContext(ctx, p.sessionsStmts.stmtRevokeSession).QueryRowContext(ctx, userID, sessionID, revokedAt).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf(`pg: %w: %s`, dberr.ErrSessionIsNotExists, err)
+		}
+		return err
+	}
+
+	_, err = tx.StmtContext(ctx, p.refreshSessionStmts.stmtRevokeRefreshSessionFamily).ExecContext(ctx, sessionID, revokedAt)
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RevokeSessions revokes all the sessions of the user and all their refresh tokens.
+func (p *Pg) RevokeSessions(ctx context.Context, userID int64, revokedAt time.Time) (err error) {
+	log.Debug().Msg("Pg.RevokeSessions START")
+	defer func() {
+		if err != nil {
+			log.Error().Err(err).Msg("Pg.RevokeSessions END")
+		} else {
+			log.Debug().Msg("Pg.RevokeSessions END")
+		}
+	}()
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if errTxRollback := tx.Rollback(); errTxRollback != nil && !errors.Is(errTxRollback, sql.ErrTxDone) {
+			log.Error().Err(errTxRollback).Msg("tx rollback")
+		}
+	}()
+
+	_, err = tx.StmtContext(ctx, p.sessionsStmts.stmtRevokeSessions).ExecContext(ctx, userID, revokedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.StmtContext(ctx, p.refreshSessionStmts.stmtRevokeUserRefreshSessions).ExecContext(ctx, userID, revokedAt)
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// IsSessionActive reports whether the session of the user exists and is not revoked.
+func (p *Pg) IsSessionActive(ctx context.Context, userID int64, sessionID string) (active bool, err error) {
+	log.Debug().Str("session_id", sessionID).Msg("Pg.IsSessionActive START")
+	defer func() {
+		if err != nil {
+			log.Error().Err(err).Msg("Pg.IsSessionActive END")
+		} else {
+			log.Debug().Msg("Pg.IsSessionActive END")
+		}
+	}()
+
+	err = p.sessionsStmts.stmtIsSessionActive.QueryRowContext(ctx, userID, sessionID).Scan(&active)
+	if err != nil {
+		return false, err
+	}
+
+	return active, nil
+}
+
+// addSession adds the session which is started by the refresh session within the transaction.
+func (p *Pg) addSession(ctx context.Context, tx *sql.Tx, refreshSession *model.RefreshSession, createdAt time.Time) error {
+	_, err := tx.StmtContext(ctx, p.sessionsStmts.stmtAddSession).ExecContext(ctx,
+		refreshSession.FamilyID,
+		refreshSession.UserID,
+		refreshSession.UserAgent,
+		refreshSession.IP,
+		createdAt)
+	return err
+}
+
+// touchSession updates the device and the last use of the session of the rotated refresh session within the transaction.
+func (p *Pg) touchSession(ctx context.Context, tx *sql.Tx, refreshSession *model.RefreshSession, usedAt time.Time) error {
+	_, err := tx.StmtContext(ctx, p.sessionsStmts.stmtTouchSession).ExecContext(ctx,
+		refreshSession.FamilyID,
+		usedAt,
+		refreshSession.UserAgent,
+		refreshSession.IP)
+	return err
+}
+
+func (s *sessionsStmts) Close() (err error) {
+
+	if err = s.stmtAddSession.Close(); err != nil {
+		return fmt.Errorf("closing stmt 'AddSession' : %w", err)
+	}
+
+	if err = s.stmtTouchSession.Close(); err != nil {
+		return fmt.Errorf("closing stmt 'TouchSession' : %w", err)
+	}
+
+	if err = s.stmtDeleteEndedSessions.Close(); err != nil {
+		return fmt.Errorf("closing stmt 'DeleteEndedSessions' : %w", err)
+	}
+
+	if err = s.stmtGetSessions.Close(); err != nil {
+		return fmt.Errorf("closing stmt 'GetSessions' : %w", err)
+	}
+
+	if err = s.stmtRevokeSession.Close(); err != nil {
+		return fmt.Errorf("closing stmt 'RevokeSession' : %w", err)
+	}
+
+	if err = s.stmtRevokeFamilySession.Close(); err != nil {
+		return fmt.Errorf("closing stmt 'RevokeFamilySession' : %w", err)
+	}
+
+	if err = s.stmtRevokeSessions.Close(); err != nil {
+		return fmt.Errorf("closing stmt 'RevokeSessions' : %w", err)
+	}
+
+	if err = s.stmtIsSessionActive.Close(); err != nil {
+		return fmt.Errorf("closing stmt 'IsSessionActive' : %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package pg
+
+const (
+	queryAddSession = `
+INSERT INTO user_sessions (id, user_id, user_agent, ip, created_at, last_used_at)
+VALUES ($1, $2, $3, $4, $5, $5)
+`
+
+	queryTouchSession = `UPDATE user_sessions SET last_used_at = $2, user_agent = $3, ip = $4 WHERE id = $1`
+
+	// queryDeleteEndedSessions deletes the sessions of the user which have no refresh tokens left.
+	queryDeleteEndedSessions = `
+DELETE FROM user_sessions
+WHERE
+	user_id = $1 AND NOT EXISTS (SELECT 1 FROM refreshsessions WHERE family_id = user_sessions.id)
+`
+
+	// queryGetSessions returns the sessions of the user which are not revoked and can still be refreshed.
+	queryGetSessions = `
+SELECT
+	id, user_agent, ip, created_at, last_used_at
+FROM
+	user_sessions
+WHERE
+	user_id = $1 AND revoked_at IS NULL AND EXISTS (
+		SELECT 1
+		FROM refreshsessions
+		WHERE family_id = user_sessions.id AND rotated_at IS NULL AND revoked_at IS NULL AND expiresIn > now()
+	)
+ORDER BY
+	last_used_at DESC
+`
+
+	queryRevokeSession = `
+UPDATE user_sessions SET
+	revoked_at = $3
+WHERE
+	id = $2 AND user_id = $1 AND revoked_at IS NULL
+RETURNING
+	id
+`
+
+	queryRevokeFamilySession = `UPDATE user_sessions SET revoked_at = $2 WHERE id = $1 AND revoked_at IS NULL`
+
+	queryRevokeSessions = `UPDATE user_sessions SET revoked_at = $2 WHERE user_id = $1 AND revoked_at IS NULL`
+
+	queryIsSessionActive = `
+SELECT EXISTS (
+	SELECT 1 FROM user_sessions WHERE id = $2 AND user_id = $1 AND revoked_at IS NULL
+)
+`
+)
@@ -0,0 +1,315 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"practicum-gophermart/internal/model"
+	dberr "practicum-gophermart/internal/storage/errors"
+)
+
+func TestPg_GetSessions(t *testing.T) {
+	testPg := Pg{}
+	testPg.sessionsStmts = &sessionsStmts{}
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	testPg.db = db
+	mock.ExpectPrepare(queryGetSessions)
+	if testPg.sessionsStmts.stmtGetSessions, err = testPg.db.PrepareContext(context.Background(), queryGetSessions); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing get sessions statement", err)
+	}
+
+	createdAt := time.Now().Add(-time.Hour)
+	lastUsedAt := time.Now()
+
+	tests := []struct {
+		name         string
+		mockBehavior func()
+		expected     []model.Session
+		wantErr      bool
+	}{
+		{
+			name: "OK",
+			mockBehavior: func() {
+				mock.ExpectQuery(queryGetSessions).
+					WithArgs(int64(1)).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "user_agent", "ip", "created_at", "last_used_at"}).
+						AddRow("family1", "Mozilla/5.0", "127.0.0.1", createdAt, lastUsedAt).
+						AddRow("family2", "", "", createdAt, createdAt))
+			},
+			expected: []model.Session{
+				{ID: "family1", UserID: 1, UserAgent: "Mozilla/5.0", IP: "127.0.0.1", CreatedAt: createdAt, LastUsedAt: lastUsedAt},
+				{ID: "family2", UserID: 1, CreatedAt: createdAt, LastUsedAt: createdAt},
+			},
+		},
+		{
+			name: "no sessions",
+			mockBehavior: func() {
+				mock.ExpectQuery(queryGetSessions).
+					WithArgs(int64(1)).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "user_agent", "ip", "created_at", "last_used_at"}))
+			},
+		},
+		{
+			name: "unexpected err",
+			mockBehavior: func() {
+				mock.ExpectQuery(queryGetSessions).
+					WithArgs(int64(1)).
+					WillReturnError(errors.New("unexpected err"))
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockBehavior()
+			sessions, err := testPg.GetSessions(context.Background(), 1)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, sessions)
+			}
+			if err = mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestPg_RevokeSession(t *testing.T) {
+	testPg := Pg{}
+	testPg.sessionsStmts = &sessionsStmts{}
+	testPg.refreshSessionStmts = &refreshSessionStmts{}
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	testPg.db = db
+	mock.ExpectPrepare(queryRevokeSession)
+	if testPg.sessionsStmts.stmtRevokeSession, err = testPg.db.PrepareContext(context.Background(), queryRevokeSession); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing revoke session statement", err)
+	}
+	mock.ExpectPrepare(queryRevokeRefreshSessionFamily)
+	if testPg.refreshSessionStmts.stmtRevokeRefreshSessionFamily, err = testPg.db.PrepareContext(context.Background(), queryRevokeRefreshSessionFamily); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing revoke refresh session family statement", err)
+	}
+
+	revokedAt := time.Now()
+
+	tests := []struct {
+		name         string
+		mockBehavior func()
+		err          error
+		wantErr      bool
+	}{
+		{
+			name: "OK",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(queryRevokeSession).
+					WithArgs(int64(1), "family", revokedAt).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("family"))
+				mock.ExpectExec(queryRevokeRefreshSessionFamily).
+					WithArgs("family", revokedAt).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "session is not exists",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(queryRevokeSession).
+					WithArgs(int64(1), "family", revokedAt).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}))
+				mock.ExpectRollback()
+			},
+			err:     dberr.ErrSessionIsNotExists,
+			wantErr: true,
+		},
+		{
+			name: "unexpected err on revoking refresh sessions",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(queryRevokeSession).
+					WithArgs(int64(1), "family", revokedAt).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("family"))
+				mock.ExpectExec(queryRevokeRefreshSessionFamily).
+					WithArgs("family", revokedAt).
+					WillReturnError(errors.New("unexpected err"))
+				mock.ExpectRollback()
+			},
+			err:     errors.New("unexpected err"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockBehavior()
+			err := testPg.RevokeSession(context.Background(), 1, "family", revokedAt)
+			if tt.wantErr {
+				assert.ErrorContains(t, err, tt.err.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+			if err = mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestPg_RevokeSessions(t *testing.T) {
+	testPg := Pg{}
+	testPg.sessionsStmts = &sessionsStmts{}
+	testPg.refreshSessionStmts = &refreshSessionStmts{}
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	testPg.db = db
+	mock.ExpectPrepare(queryRevokeSessions)
+	if testPg.sessionsStmts.stmtRevokeSessions, err = testPg.db.PrepareContext(context.Background(), queryRevokeSessions); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing revoke sessions statement", err)
+	}
+	mock.ExpectPrepare(queryRevokeUserRefreshSessions)
+	if testPg.refreshSessionStmts.stmtRevokeUserRefreshSessions, err = testPg.db.PrepareContext(context.Background(), queryRevokeUserRefreshSessions); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing revoke user refresh sessions statement", err)
+	}
+
+	revokedAt := time.Now()
+
+	tests := []struct {
+		name         string
+		mockBehavior func()
+		err          error
+		wantErr      bool
+	}{
+		{
+			name: "OK",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(queryRevokeSessions).
+					WithArgs(int64(1), revokedAt).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectExec(queryRevokeUserRefreshSessions).
+					WithArgs(int64(1), revokedAt).
+					WillReturnResult(sqlmock.NewResult(0, 3))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "unexpected err on revoking refresh sessions",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(queryRevokeSessions).
+					WithArgs(int64(1), revokedAt).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectExec(queryRevokeUserRefreshSessions).
+					WithArgs(int64(1), revokedAt).
+					WillReturnError(errors.New("unexpected err"))
+				mock.ExpectRollback()
+			},
+			err:     errors.New("unexpected err"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockBehavior()
+			err := testPg.RevokeSessions(context.Background(), 1, revokedAt)
+			if tt.wantErr {
+				assert.ErrorContains(t, err, tt.err.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+			if err = mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestPg_IsSessionActive(t *testing.T) {
+	testPg := Pg{}
+	testPg.sessionsStmts = &sessionsStmts{}
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	testPg.db = db
+	mock.ExpectPrepare(queryIsSessionActive)
+	if testPg.sessionsStmts.stmtIsSessionActive, err = testPg.db.PrepareContext(context.Background(), queryIsSessionActive); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing is session active statement", err)
+	}
+
+	tests := []struct {
+		name         string
+		mockBehavior func()
+		expected     bool
+		wantErr      bool
+	}{
+		{
+			name: "active",
+			mockBehavior: func() {
+				mock.ExpectQuery(queryIsSessionActive).
+					WithArgs(int64(1), "family").
+					WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+			},
+			expected: true,
+		},
+		{
+			name: "revoked",
+			mockBehavior: func() {
+				mock.ExpectQuery(queryIsSessionActive).
+					WithArgs(int64(1), "family").
+					WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+			},
+			expected: false,
+		},
+		{
+			name: "unexpected err",
+			mockBehavior: func() {
+				mock.ExpectQuery(queryIsSessionActive).
+					WithArgs(int64(1), "family").
+					WillReturnError(errors.New("unexpected err"))
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockBehavior()
+			active, err := testPg.IsSessionActive(context.Background(), 1, "family")
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, active)
+			}
+			if err = mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
@@ -52,6 +52,21 @@ func initTables(ctx context.Context, p *Pg) error {
 		return err
 	}
 
+	_, err = tx.ExecContext(ctx, queryMigrateOrdersPollingState)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, queryMigrateOrdersPollingLease)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, queryMigrateOrdersPollResult)
+	if err != nil {
+		return err
+	}
+
 	_, err = tx.ExecContext(ctx, queryCreateTableBalance)
 	if err != nil {
 		return err
@@ -62,6 +77,81 @@ func initTables(ctx context.Context, p *Pg) error {
 		return err
 	}
 
+	_, err = tx.ExecContext(ctx, queryMigrateMoneyToNumeric)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, queryCreateTypePostingKind)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, queryCreateTypeLedgerAccount)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, queryCreateTableLedger)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, queryMigrateBalanceToLedger)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, queryCreateListingIndexes)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, queryMigrateLedgerExpiry)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, queryCreateTablePointLots)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, queryMigrateBalanceToPointLots)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, queryMigrateWithdrawalsStatus)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, queryCreateTableIdempotencyKeys)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, queryCreateTableHolds)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, queryMigrateRefreshSessionsFamilies)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, queryCreateTableUserSessions)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, queryMigrateHoldsActiveOrder)
+	if err != nil {
+		return err
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		return err
@@ -21,31 +21,60 @@ CREATE TABLE IF NOT EXISTS users
 const queryCreateTableRefreshSessions = `
 CREATE TABLE IF NOT EXISTS refreshSessions
 (
-    id           bigint PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
-    user_id      bigint REFERENCES users(id) ON DELETE CASCADE,
-    refreshToken uuid NOT NULL,
-    expiresIn    timestamp NOT NULL
+    id         bigint PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    user_id    bigint REFERENCES users(id) ON DELETE CASCADE,
+    family_id  uuid NOT NULL,
+    token_hash varchar NOT NULL,
+    expiresIn  timestamp NOT NULL,
+    rotated_at timestamp,
+    revoked_at timestamp
 );
 `
 
 const queryCreateTableOrders = `
 CREATE TABLE IF NOT EXISTS orders
 (
-	id            bigint PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
-	user_id       bigint REFERENCES users(id) ON DELETE CASCADE,
-	number        varchar NOT NULL UNIQUE,
-	status        order_status NOT NULL,
-	accrual       double precision NOT NULL,
-	uploaded_at   timestamp NOT NULL
+	id               bigint PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+	user_id          bigint REFERENCES users(id) ON DELETE CASCADE,
+	number           varchar NOT NULL UNIQUE,
+	status           order_status NOT NULL,
+	accrual          numeric(20,2) NOT NULL,
+	uploaded_at      timestamp NOT NULL,
+	attempts         integer NOT NULL DEFAULT 0,
+	last_polled_at   timestamp,
+	next_poll_at     timestamp NOT NULL DEFAULT now(),
+	lease_owner      varchar,
+	lease_expires_at timestamp,
+	poll_result      varchar
 );
 `
 
+const queryMigrateOrdersPollingState = `
+ALTER TABLE orders
+	ADD COLUMN IF NOT EXISTS attempts       integer NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS last_polled_at timestamp,
+	ADD COLUMN IF NOT EXISTS next_poll_at   timestamp NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS orders_next_poll_at_idx ON orders (next_poll_at) WHERE status IN ('NEW', 'PROCESSING');
+`
+
+const queryMigrateOrdersPollingLease = `
+ALTER TABLE orders
+	ADD COLUMN IF NOT EXISTS lease_owner      varchar,
+	ADD COLUMN IF NOT EXISTS lease_expires_at timestamp;
+`
+
+const queryMigrateOrdersPollResult = `
+ALTER TABLE orders
+	ADD COLUMN IF NOT EXISTS poll_result varchar;
+`
+
 const queryCreateTableBalance = `
 CREATE TABLE IF NOT EXISTS balance
 (
 	id             bigint PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
 	user_id        bigint REFERENCES users(id) ON DELETE CASCADE,
-	sum            double precision NOT NULL
+	sum            numeric(20,2) NOT NULL
 );
 `
 
@@ -55,7 +84,279 @@ CREATE TABLE IF NOT EXISTS withdrawals
 	id             bigint PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
 	user_id        bigint REFERENCES users(id) ON DELETE CASCADE,
 	order_number   varchar NOT NULL UNIQUE,
-	sum            double precision NOT NULL,
+	sum            numeric(20,2) NOT NULL,
 	processed_at   timestamp NOT NULL
 );
 `
+
+// queryMigrateMoneyToNumeric converts amounts stored as double precision to exact numeric.
+// Columns already converted are left untouched.
+const queryMigrateMoneyToNumeric = `
+DO $$ BEGIN
+	IF EXISTS (SELECT FROM information_schema.columns
+		WHERE table_name = 'orders' AND column_name = 'accrual' AND data_type = 'double precision')
+	THEN
+		ALTER TABLE orders ALTER COLUMN accrual TYPE numeric(20,2) USING round(accrual::numeric, 2);
+	END IF;
+
+	IF EXISTS (SELECT FROM information_schema.columns
+		WHERE table_name = 'balance' AND column_name = 'sum' AND data_type = 'double precision')
+	THEN
+		ALTER TABLE balance ALTER COLUMN sum TYPE numeric(20,2) USING round(sum::numeric, 2);
+	END IF;
+
+	IF EXISTS (SELECT FROM information_schema.columns
+		WHERE table_name = 'withdrawals' AND column_name = 'sum' AND data_type = 'double precision')
+	THEN
+		ALTER TABLE withdrawals ALTER COLUMN sum TYPE numeric(20,2) USING round(sum::numeric, 2);
+	END IF;
+END $$;
+`
+
+const queryCreateTypePostingKind = `
+DO $$ BEGIN
+	CREATE TYPE posting_kind AS ENUM ('ACCRUAL', 'WITHDRAWAL', 'ADJUSTMENT', 'REVERSAL');
+EXCEPTION
+	WHEN duplicate_object
+	THEN null;
+END $$;
+`
+
+const queryCreateTypeLedgerAccount = `
+DO $$ BEGIN
+	CREATE TYPE ledger_account AS ENUM ('USER', 'ACCRUALS', 'WITHDRAWALS', 'ADJUSTMENTS');
+EXCEPTION
+	WHEN duplicate_object
+	THEN null;
+END $$;
+`
+
+// queryCreateTableLedger creates the append-only ledger of points postings.
+// Every posting moves a positive amount from the debit account to the credit account.
+const queryCreateTableLedger = `
+CREATE TABLE IF NOT EXISTS ledger
+(
+	id             bigint PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+	user_id        bigint REFERENCES users(id) ON DELETE CASCADE,
+	kind           posting_kind NOT NULL,
+	debit_account  ledger_account NOT NULL,
+	credit_account ledger_account NOT NULL CHECK (credit_account <> debit_account),
+	amount         numeric(20,2) NOT NULL CHECK (amount > 0),
+	order_number   varchar,
+	reverses_id    bigint REFERENCES ledger(id),
+	created_at     timestamp NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS ledger_user_id_created_at_idx ON ledger (user_id, created_at);
+`
+
+// queryMigrateBalanceToLedger fills the empty ledger with postings of the processed orders and withdrawals.
+// The difference between the balance and the postings is posted as an adjustment,
+// so the balance of every user equals the sum of its postings.
+const queryMigrateBalanceToLedger = `
+DO $$ BEGIN
+	IF NOT EXISTS (SELECT FROM ledger) THEN
+		INSERT INTO ledger (user_id, kind, debit_account, credit_account, amount, order_number, created_at)
+		SELECT user_id, 'ACCRUAL', 'ACCRUALS', 'USER', accrual, number, uploaded_at
+		FROM orders
+		WHERE status = 'PROCESSED' AND accrual > 0;
+
+		INSERT INTO ledger (user_id, kind, debit_account, credit_account, amount, order_number, created_at)
+		SELECT user_id, 'WITHDRAWAL', 'USER', 'WITHDRAWALS', sum, order_number, processed_at
+		FROM withdrawals
+		WHERE sum > 0;
+
+		INSERT INTO ledger (user_id, kind, debit_account, credit_account, amount, created_at)
+		SELECT
+			user_id,
+			'ADJUSTMENT',
+			CASE WHEN diff > 0 THEN 'ADJUSTMENTS' ELSE 'USER' END::ledger_account,
+			CASE WHEN diff > 0 THEN 'USER' ELSE 'ADJUSTMENTS' END::ledger_account,
+			abs(diff),
+			now()
+		FROM (
+			SELECT
+				balance.user_id,
+				balance.sum - COALESCE(SUM(CASE WHEN ledger.credit_account = 'USER' THEN ledger.amount ELSE -ledger.amount END), 0) AS diff
+			FROM
+				balance LEFT JOIN ledger ON
+					balance.user_id = ledger.user_id
+			GROUP BY
+				balance.user_id, balance.sum
+		) AS balance_diff
+		WHERE diff <> 0;
+	END IF;
+END $$;
+`
+
+// queryCreateListingIndexes backs keyset pagination of the user orders and withdrawals.
+const queryCreateListingIndexes = `
+CREATE INDEX IF NOT EXISTS orders_user_id_uploaded_at_id_idx ON orders (user_id, uploaded_at, id);
+
+CREATE INDEX IF NOT EXISTS withdrawals_user_id_processed_at_id_idx ON withdrawals (user_id, processed_at, id);
+`
+
+// queryMigrateLedgerExpiry adds the expiry postings to the ledger.
+// New enum values can't be used in the transaction which adds them, so nothing else here refers to them.
+const queryMigrateLedgerExpiry = `
+ALTER TYPE posting_kind ADD VALUE IF NOT EXISTS 'EXPIRY';
+
+ALTER TYPE ledger_account ADD VALUE IF NOT EXISTS 'EXPIRED';
+`
+
+// queryCreateTablePointLots creates the lots of points accrued for the processed orders.
+// The remaining of the lot is consumed by withdrawals and expires at expires_at,
+// the lot without expires_at never expires.
+const queryCreateTablePointLots = `
+CREATE TABLE IF NOT EXISTS point_lots
+(
+	id           bigint PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+	user_id      bigint REFERENCES users(id) ON DELETE CASCADE,
+	order_number varchar,
+	amount       numeric(20,2) NOT NULL CHECK (amount > 0),
+	remaining    numeric(20,2) NOT NULL CHECK (remaining >= 0 AND remaining <= amount),
+	accrued_at   timestamp NOT NULL,
+	expires_at   timestamp
+);
+
+CREATE INDEX IF NOT EXISTS point_lots_user_id_accrued_at_id_idx ON point_lots (user_id, accrued_at, id) WHERE remaining > 0;
+
+CREATE INDEX IF NOT EXISTS point_lots_expires_at_idx ON point_lots (expires_at) WHERE remaining > 0;
+`
+
+// queryMigrateBalanceToPointLots puts the balances accrued before the points expiry into lots which never expire,
+// so the points are not expired retroactively.
+const queryMigrateBalanceToPointLots = `
+DO $$ BEGIN
+	IF NOT EXISTS (SELECT FROM point_lots) THEN
+		INSERT INTO point_lots (user_id, amount, remaining, accrued_at)
+		SELECT user_id, sum, sum, now()
+		FROM balance
+		WHERE sum > 0;
+	END IF;
+END $$;
+`
+
+// queryMigrateWithdrawalsStatus adds the status of the withdrawal, the withdrawals made before are processed.
+const queryMigrateWithdrawalsStatus = `
+DO $$ BEGIN
+	CREATE TYPE withdrawal_status AS ENUM ('PROCESSED', 'CANCELLED');
+EXCEPTION
+	WHEN duplicate_object
+	THEN null;
+END $$;
+
+ALTER TABLE withdrawals
+	ADD COLUMN IF NOT EXISTS status withdrawal_status NOT NULL DEFAULT 'PROCESSED',
+	ADD COLUMN IF NOT EXISTS cancelled_at timestamp;
+`
+
+// queryCreateTableIdempotencyKeys creates the keys of the idempotent requests of the users
+// with the responses to replay.
+const queryCreateTableIdempotencyKeys = `
+CREATE TABLE IF NOT EXISTS idempotency_keys
+(
+	user_id       bigint REFERENCES users(id) ON DELETE CASCADE,
+	key           varchar NOT NULL,
+	request_hash  varchar NOT NULL,
+	response_code integer,
+	response_body bytea,
+	created_at    timestamp NOT NULL,
+	PRIMARY KEY (user_id, key)
+);
+`
+
+// queryCreateTableHolds creates the points reserved for the pending payments of the orders
+// and the held sum of the balance excluded from the available points.
+const queryCreateTableHolds = `
+DO $$ BEGIN
+	CREATE TYPE hold_status AS ENUM ('HELD', 'CAPTURED', 'RELEASED', 'EXPIRED');
+EXCEPTION
+	WHEN duplicate_object
+	THEN null;
+END $$;
+
+CREATE TABLE IF NOT EXISTS holds
+(
+	id           bigint PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+	user_id      bigint REFERENCES users(id) ON DELETE CASCADE,
+	order_number varchar UNIQUE NOT NULL,
+	sum          numeric(20,2) NOT NULL,
+	status       hold_status NOT NULL DEFAULT 'HELD',
+	created_at   timestamp NOT NULL,
+	expires_at   timestamp NOT NULL,
+	closed_at    timestamp
+);
+
+CREATE INDEX IF NOT EXISTS holds_status_expires_at_idx ON holds (status, expires_at);
+
+ALTER TABLE balance
+	ADD COLUMN IF NOT EXISTS held numeric(20,2) NOT NULL DEFAULT 0;
+`
+
+// queryMigrateRefreshSessionsFamilies upgrades the refresh sessions of the databases created before the families:
+// it replaces the raw refresh tokens with their hashes and groups the sessions rotated one from another into families.
+// Every existing session starts its own family. The new tables are created with the families and only get the indexes.
+const queryMigrateRefreshSessionsFamilies = `
+ALTER TABLE refreshSessions
+	ADD COLUMN IF NOT EXISTS family_id uuid,
+	ADD COLUMN IF NOT EXISTS token_hash varchar,
+	ADD COLUMN IF NOT EXISTS rotated_at timestamp,
+	ADD COLUMN IF NOT EXISTS revoked_at timestamp;
+
+DO $$ BEGIN
+	IF EXISTS (
+		SELECT 1 FROM information_schema.columns WHERE table_name = 'refreshsessions' AND column_name = 'refreshtoken'
+	) THEN
+		UPDATE refreshSessions SET
+			family_id = md5(random()::text || id::text)::uuid,
+			token_hash = encode(sha256(refreshToken::text::bytea), 'hex')
+		WHERE token_hash IS NULL;
+
+		ALTER TABLE refreshSessions DROP COLUMN refreshToken;
+	END IF;
+END $$;
+
+ALTER TABLE refreshSessions
+	ALTER COLUMN family_id SET NOT NULL,
+	ALTER COLUMN token_hash SET NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS refreshsessions_token_hash_idx ON refreshSessions (token_hash);
+CREATE INDEX IF NOT EXISTS refreshsessions_family_id_idx ON refreshSessions (family_id);
+`
+
+// queryCreateTableUserSessions creates the sessions of the users on their devices, a session is a refresh token family.
+// The active families existing when the table is created become the sessions of unknown devices,
+// the backfill runs once like the other backfills, so the revoked families do not come back.
+const queryCreateTableUserSessions = `
+CREATE TABLE IF NOT EXISTS user_sessions
+(
+	id           uuid PRIMARY KEY,
+	user_id      bigint REFERENCES users(id) ON DELETE CASCADE,
+	user_agent   varchar NOT NULL DEFAULT '',
+	ip           varchar NOT NULL DEFAULT '',
+	created_at   timestamp NOT NULL,
+	last_used_at timestamp NOT NULL,
+	revoked_at   timestamp
+);
+
+CREATE INDEX IF NOT EXISTS user_sessions_user_id_idx ON user_sessions (user_id);
+
+DO $$ BEGIN
+	IF NOT EXISTS (SELECT FROM user_sessions) THEN
+		INSERT INTO user_sessions (id, user_id, created_at, last_used_at)
+		SELECT DISTINCT ON (family_id) family_id, user_id, now(), now()
+		FROM refreshSessions
+		WHERE revoked_at IS NULL;
+	END IF;
+END $$;
+`
+
+// queryMigrateHoldsActiveOrder lets the order be held again after its hold is closed,
+// only one hold of the order can be active at a time.
+const queryMigrateHoldsActiveOrder = `
+ALTER TABLE holds DROP CONSTRAINT IF EXISTS holds_order_number_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS holds_active_order_number_key ON holds (order_number) WHERE status = 'HELD';
+CREATE INDEX IF NOT EXISTS holds_order_number_idx ON holds (order_number);
+`
@@ -34,10 +34,46 @@ func Test_initTables(t *testing.T) {
 					WillReturnResult(sqlmock.NewResult(0, 0))
 				mock.ExpectExec(queryCreateTableOrders).
 					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(queryMigrateOrdersPollingState).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(queryMigrateOrdersPollingLease).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(queryMigrateOrdersPollResult).
+					WillReturnResult(sqlmock.NewResult(0, 0))
 				mock.ExpectExec(queryCreateTableBalance).
 					WillReturnResult(sqlmock.NewResult(0, 0))
 				mock.ExpectExec(queryCreateTableWithdrawals).
 					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(queryMigrateMoneyToNumeric).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(queryCreateTypePostingKind).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(queryCreateTypeLedgerAccount).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(queryCreateTableLedger).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(queryMigrateBalanceToLedger).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(queryCreateListingIndexes).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(queryMigrateLedgerExpiry).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(queryCreateTablePointLots).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(queryMigrateBalanceToPointLots).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(queryMigrateWithdrawalsStatus).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(queryCreateTableIdempotencyKeys).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(queryCreateTableHolds).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(queryMigrateRefreshSessionsFamilies).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(queryCreateTableUserSessions).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(queryMigrateHoldsActiveOrder).
+					WillReturnResult(sqlmock.NewResult(0, 0))
 				mock.ExpectCommit()
 			},
 		},
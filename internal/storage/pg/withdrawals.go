@@ -3,7 +3,9 @@ package pg
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/rs/zerolog/log"
 
@@ -12,8 +14,10 @@ import (
 )
 
 type withdrawalsStmts struct {
-	stmtAddWithdrawal  *sql.Stmt
-	stmtGetWithdrawals *sql.Stmt
+	stmtAddWithdrawal       *sql.Stmt
+	stmtGetWithdrawals      *sql.Stmt
+	stmtCancelWithdrawal    *sql.Stmt
+	stmtGetWithdrawalStatus *sql.Stmt
 }
 
 func prepareWithdrawalsStmts(ctx context.Context, p *Pg) error {
@@ -30,11 +34,22 @@ func prepareWithdrawalsStmts(ctx context.Context, p *Pg) error {
 		return err
 	}
 
+	if newWithdrawalsStmts.stmtCancelWithdrawal, err = p.db.PrepareContext(ctx, queryCancelWithdrawal); err != nil {
+		return err
+	}
+
+	if newWithdrawalsStmts.stmtGetWithdrawalStatus, err = p.db.PrepareContext(ctx, queryGetWithdrawalStatus); err != nil {
+		return err
+	}
+
 	p.withdrawalsStmts = &newWithdrawalsStmts
 
 	return nil
 }
 
+// AddWithdrawal withdraws the sum from the balance of the user, the oldest lots of accrued points are consumed first.
+// The balance is debited only if it covers the sum, otherwise nothing is changed and ErrNegativeBalance is returned.
+// The lots of the user are locked before the balance, in the same order as the points expiry does.
 func (p *Pg) AddWithdrawal(ctx context.Context, userID int64, withdraw model.Withdraw) error {
 	log.Debug().Msg("Pg.AddWithdrawal START")
 	var err error
@@ -46,41 +61,61 @@ func (p *Pg) AddWithdrawal(ctx context.Context, userID int64, withdraw model.Wit
 		}
 	}()
 
-	tx, err := p.db.Begin()
+	tx, err := p.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer func() {
-		if errTxRollback := tx.Rollback(); errTxRollback != nil {
+		if errTxRollback := tx.Rollback(); errTxRollback != nil && !errors.Is(errTxRollback, sql.ErrTxDone) {
 			log.Error().Err(errTxRollback).Msg("tx rollback")
 		}
 	}()
 
-	_, err = tx.StmtContext(ctx, p.withdrawalsStmts.stmtAddWithdrawal).ExecContext(ctx, userID, withdraw.Order, withdraw.Sum, withdraw.ProcessedAt)
+	if err = p.addWithdrawal(ctx, tx, userID, withdraw); err != nil {
+		return err
+	}
+
+	var newBalance model.Money
+	err = tx.StmtContext(ctx, p.balanceStmts.stmtDebitBalance).QueryRowContext(ctx, userID, withdraw.Sum).Scan(&newBalance)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = nil
+			return dberr.ErrNegativeBalance
+		}
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
 		return err
 	}
 
-	var newBalance float64
-	err = tx.StmtContext(ctx, p.balanceStmts.stmtReduceBalance).QueryRowContext(ctx, userID, withdraw.Sum).Scan(&newBalance)
+	return nil
+}
+
+// addWithdrawal adds the withdrawal within the transaction, consumes the lots of accrued points
+// and posts it to the ledger. The balance is left to the caller.
+func (p *Pg) addWithdrawal(ctx context.Context, tx *sql.Tx, userID int64, withdraw model.Withdraw) error {
+	_, err := tx.StmtContext(ctx, p.withdrawalsStmts.stmtAddWithdrawal).ExecContext(ctx, userID, withdraw.Order, withdraw.Sum,
+		withdraw.ProcessedAt)
 	if err != nil {
 		return err
 	}
-	if newBalance < 0 {
-		if errTxRollback := tx.Rollback(); errTxRollback != nil {
-			log.Error().Err(errTxRollback).Msg("tx rollback")
-		}
-		return dberr.ErrNegativeBalance
+
+	if err = p.consumePointLots(ctx, tx, userID, withdraw.Sum); err != nil {
+		return err
 	}
 
-	if err = tx.Commit(); err != nil {
+	if _, err = p.addPosting(ctx, tx, model.NewWithdrawalPosting(userID, withdraw.Order, withdraw.Sum, withdraw.ProcessedAt)); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (p *Pg) GetWithdrawals(ctx context.Context, userID int64) (withdrawals []model.Withdraw, err error) {
+// GetWithdrawals returns the page of the user withdrawals selected by the filter and the cursor of the next page.
+// The cursor is nil on the last page.
+func (p *Pg) GetWithdrawals(ctx context.Context, userID int64, filter model.ListFilter) (withdrawals []model.Withdraw,
+	next *model.Cursor, err error) {
 	log.Debug().Msg("Pg.GetWithdrawals START")
 	defer func() {
 		if err != nil {
@@ -90,9 +125,11 @@ func (p *Pg) GetWithdrawals(ctx context.Context, userID int64) (withdrawals []mo
 		}
 	}()
 
-	rows, err := p.withdrawalsStmts.stmtGetWithdrawals.QueryContext(ctx, userID)
+	afterTime, afterID := cursorArgs(filter.After)
+	rows, err := p.withdrawalsStmts.stmtGetWithdrawals.QueryContext(ctx, userID, nullTime(filter.From), nullTime(filter.To),
+		afterTime, afterID, limitArg(filter.Limit))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer func() {
 		if errRowsClose := rows.Close(); errRowsClose != nil {
@@ -100,19 +137,109 @@ func (p *Pg) GetWithdrawals(ctx context.Context, userID int64) (withdrawals []mo
 		}
 	}()
 
+	var last model.Cursor
+	rowsCount := 0
 	for rows.Next() {
+		rowsCount++
+		if filter.Limit > 0 && rowsCount > filter.Limit {
+			break
+		}
+
 		currWithdraw := model.Withdraw{}
-		if err = rows.Scan(&currWithdraw.Order, &currWithdraw.Sum, &currWithdraw.ProcessedAt); err != nil {
-			return nil, err
+		if err = rows.Scan(&last.ID, &currWithdraw.Order, &currWithdraw.Sum, &currWithdraw.ProcessedAt,
+			&currWithdraw.Status); err != nil {
+			return nil, nil, err
 		}
+		last.Time = currWithdraw.ProcessedAt
 		withdrawals = append(withdrawals, currWithdraw)
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	return withdrawals, nextCursor(rowsCount, filter.Limit, last), nil
+}
+
+// CancelWithdrawal cancels the withdrawal for the order and returns the points to the user.
+// The reversal of the withdrawal posting is added to the ledger and the points are put into a new lot
+// which expires at pointsExpireAt.
+func (p *Pg) CancelWithdrawal(ctx context.Context, orderNumber string, cancelledAt, pointsExpireAt time.Time) (
+	withdraw model.Withdraw, err error) {
+	log.Debug().Msg("Pg.CancelWithdrawal START")
+	defer func() {
+		if err != nil {
+			log.Error().Err(err).Msg("Pg.CancelWithdrawal END")
+		} else {
+			log.Debug().Msg("Pg.CancelWithdrawal END")
+		}
+	}()
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Withdraw{}, err
 	}
+	defer func() {
+		if errTxRollback := tx.Rollback(); errTxRollback != nil && !errors.Is(errTxRollback, sql.ErrTxDone) {
+			log.Error().Err(errTxRollback).Msg("tx rollback")
+		}
+	}()
 
-	return withdrawals, nil
+	var userID int64
+	err = tx.StmtContext(ctx, p.withdrawalsStmts.stmtCancelWithdrawal).QueryRowContext(ctx, orderNumber, cancelledAt).
+		Scan(&userID, &withdraw.Sum, &withdraw.ProcessedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = p.withdrawalNotCancelledErr(ctx, tx, orderNumber)
+		}
+		return model.Withdraw{}, err
+	}
+	withdraw.Order = orderNumber
+	withdraw.Status = model.WithdrawStatusCancelled.String()
+
+	reversesID, err := p.getPostingID(ctx, tx, model.PostingKindWithdrawal.String(), orderNumber)
+	if err != nil {
+		return model.Withdraw{}, err
+	}
+
+	if _, err = p.addPosting(ctx, tx, model.NewWithdrawalReversalPosting(userID, orderNumber, withdraw.Sum, reversesID,
+		cancelledAt)); err != nil {
+		return model.Withdraw{}, err
+	}
+
+	err = p.addPointLot(ctx, tx, model.PointLot{
+		UserID:      userID,
+		OrderNumber: orderNumber,
+		Amount:      withdraw.Sum,
+		AccruedAt:   cancelledAt,
+		ExpiresAt:   pointsExpireAt,
+	})
+	if err != nil {
+		return model.Withdraw{}, err
+	}
+
+	if _, err = tx.StmtContext(ctx, p.balanceStmts.stmtIncreaseBalance).ExecContext(ctx, userID, withdraw.Sum); err != nil {
+		return model.Withdraw{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return model.Withdraw{}, err
+	}
+
+	return withdraw, nil
+}
+
+// withdrawalNotCancelledErr explains why the withdrawal for the order was not cancelled.
+func (p *Pg) withdrawalNotCancelledErr(ctx context.Context, tx *sql.Tx, orderNumber string) error {
+	var status string
+	err := tx.StmtContext(ctx, p.withdrawalsStmts.stmtGetWithdrawalStatus).QueryRowContext(ctx, orderNumber).Scan(&status)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return dberr.ErrWithdrawalIsNotExists
+		}
+		return err
+	}
+	return dberr.ErrWithdrawalIsAlreadyCancelled
 }
 
 func (w *withdrawalsStmts) Close() (err error) {
@@ -125,5 +252,13 @@ func (w *withdrawalsStmts) Close() (err error) {
 		return fmt.Errorf("closing stmt 'GetWithdrawals' : %w", err)
 	}
 
+	if err = w.stmtCancelWithdrawal.Close(); err != nil {
+		return fmt.Errorf("closing stmt 'CancelWithdrawal' : %w", err)
+	}
+
+	if err = w.stmtGetWithdrawalStatus.Close(); err != nil {
+		return fmt.Errorf("closing stmt 'GetWithdrawalStatus' : %w", err)
+	}
+
 	return nil
 }
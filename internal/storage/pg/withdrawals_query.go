@@ -1,6 +1,29 @@
 package pg
 
-const (
-	queryAddWithdrawal  = `INSERT INTO withdrawals (user_id, order_number, sum, processed_at) VALUES ($1, $2, $3, $4)`
-	queryGetWithdrawals = `SELECT order_number, sum, processed_at FROM withdrawals WHERE user_id=$1 ORDER BY processed_at`
-)
+const queryAddWithdrawal = `INSERT INTO withdrawals (user_id, order_number, sum, processed_at) VALUES ($1, $2, $3, $4)`
+
+// queryGetWithdrawals returns the page of the user withdrawals after the cursor ($4, $5) sorted by processing time.
+// Null filters and limit are not applied.
+const queryGetWithdrawals = `
+SELECT
+	id, order_number, sum, processed_at, status
+FROM
+	withdrawals
+WHERE
+	user_id = $1
+	AND ($2::timestamp IS NULL OR processed_at >= $2)
+	AND ($3::timestamp IS NULL OR processed_at < $3)
+	AND ($4::timestamp IS NULL OR (processed_at, id) > ($4, $5))
+ORDER BY
+	processed_at, id
+LIMIT $6
+`
+
+// queryCancelWithdrawal cancels the processed withdrawal, a withdrawal is cancelled once.
+const queryCancelWithdrawal = `
+UPDATE withdrawals SET status = 'CANCELLED', cancelled_at = $2
+WHERE order_number = $1 AND status = 'PROCESSED'
+RETURNING user_id, sum, processed_at
+`
+
+const queryGetWithdrawalStatus = `SELECT status FROM withdrawals WHERE order_number = $1`
@@ -2,14 +2,20 @@ package pg
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"fmt"
 	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"practicum-gophermart/internal/model"
 	dberr "practicum-gophermart/internal/storage/errors"
@@ -19,6 +25,8 @@ func TestPg_AddWithdrawal(t *testing.T) {
 	testPg := Pg{}
 	testPg.withdrawalsStmts = &withdrawalsStmts{}
 	testPg.balanceStmts = &balanceStmts{}
+	testPg.ledgerStmts = &ledgerStmts{}
+	testPg.pointLotsStmts = &pointLotsStmts{}
 
 	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 	if err != nil {
@@ -31,8 +39,16 @@ func TestPg_AddWithdrawal(t *testing.T) {
 	if testPg.withdrawalsStmts.stmtAddWithdrawal, err = testPg.db.PrepareContext(context.Background(), queryAddWithdrawal); err != nil {
 		t.Fatalf("an error '%s' was not expected when preparing create user statement", err)
 	}
-	mock.ExpectPrepare(queryReduceBalance)
-	if testPg.balanceStmts.stmtReduceBalance, err = testPg.db.PrepareContext(context.Background(), queryReduceBalance); err != nil {
+	mock.ExpectPrepare(queryDebitBalance)
+	if testPg.balanceStmts.stmtDebitBalance, err = testPg.db.PrepareContext(context.Background(), queryDebitBalance); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing create user statement", err)
+	}
+	mock.ExpectPrepare(queryAddPosting)
+	if testPg.ledgerStmts.stmtAddPosting, err = testPg.db.PrepareContext(context.Background(), queryAddPosting); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing create user statement", err)
+	}
+	mock.ExpectPrepare(queryConsumePointLots)
+	if testPg.pointLotsStmts.stmtConsumePointLots, err = testPg.db.PrepareContext(context.Background(), queryConsumePointLots); err != nil {
 		t.Fatalf("an error '%s' was not expected when preparing create user statement", err)
 	}
 	testPg.db = db
@@ -52,7 +68,13 @@ func TestPg_AddWithdrawal(t *testing.T) {
 				mock.ExpectExec(queryAddWithdrawal).
 					WithArgs(userID, withdraw.Order, withdraw.Sum, withdraw.ProcessedAt).
 					WillReturnResult(sqlmock.NewResult(0, 0))
-				mock.ExpectQuery(queryReduceBalance).
+				mock.ExpectExec(queryConsumePointLots).
+					WithArgs(userID, withdraw.Sum).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectQuery(queryAddPosting).
+					WithArgs(userID, "WITHDRAWAL", "USER", "WITHDRAWALS", withdraw.Sum, withdraw.Order, int64(0), withdraw.ProcessedAt).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+				mock.ExpectQuery(queryDebitBalance).
 					WithArgs(userID, withdraw.Sum).
 					WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow("1"))
 				mock.ExpectCommit()
@@ -60,7 +82,7 @@ func TestPg_AddWithdrawal(t *testing.T) {
 			userID: 1,
 			withdraw: model.Withdraw{
 				Order:       "123",
-				Sum:         1.1,
+				Sum:         110,
 				ProcessedAt: time.Now(),
 			},
 		},
@@ -72,7 +94,7 @@ func TestPg_AddWithdrawal(t *testing.T) {
 			userID: 1,
 			withdraw: model.Withdraw{
 				Order:       "123",
-				Sum:         1.1,
+				Sum:         110,
 				ProcessedAt: time.Now(),
 			},
 			err:     "unexpected err",
@@ -90,31 +112,55 @@ func TestPg_AddWithdrawal(t *testing.T) {
 			userID: 1,
 			withdraw: model.Withdraw{
 				Order:       "123",
-				Sum:         1.1,
+				Sum:         110,
 				ProcessedAt: time.Now(),
 			},
 			err:     "unexpected error",
 			wantErr: true,
 		},
 		{
-			name: "unexpected err on reducing balance",
+			name: "unexpected err on consuming point lots",
 			mockBehavior: func(userID int64, withdraw model.Withdraw) {
 				mock.ExpectBegin()
 				mock.ExpectExec(queryAddWithdrawal).
 					WithArgs(userID, withdraw.Order, withdraw.Sum, withdraw.ProcessedAt).
 					WillReturnResult(sqlmock.NewResult(0, 0))
-				mock.ExpectQuery(queryReduceBalance).
+				mock.ExpectExec(queryConsumePointLots).
 					WithArgs(userID, withdraw.Sum).
-					WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow("-1"))
+					WillReturnError(errors.New("unexpected error"))
 				mock.ExpectRollback()
 			},
 			userID: 1,
 			withdraw: model.Withdraw{
 				Order:       "123",
-				Sum:         1.1,
+				Sum:         110,
 				ProcessedAt: time.Now(),
 			},
-			err:     dberr.ErrNegativeBalance.Error(),
+			err:     "consuming point lots: unexpected error",
+			wantErr: true,
+		},
+		{
+			name: "unexpected err on adding posting",
+			mockBehavior: func(userID int64, withdraw model.Withdraw) {
+				mock.ExpectBegin()
+				mock.ExpectExec(queryAddWithdrawal).
+					WithArgs(userID, withdraw.Order, withdraw.Sum, withdraw.ProcessedAt).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(queryConsumePointLots).
+					WithArgs(userID, withdraw.Sum).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectQuery(queryAddPosting).
+					WithArgs(userID, "WITHDRAWAL", "USER", "WITHDRAWALS", withdraw.Sum, withdraw.Order, int64(0), withdraw.ProcessedAt).
+					WillReturnError(errors.New("unexpected error"))
+				mock.ExpectRollback()
+			},
+			userID: 1,
+			withdraw: model.Withdraw{
+				Order:       "123",
+				Sum:         110,
+				ProcessedAt: time.Now(),
+			},
+			err:     "unexpected error",
 			wantErr: true,
 		},
 		{
@@ -124,7 +170,40 @@ func TestPg_AddWithdrawal(t *testing.T) {
 				mock.ExpectExec(queryAddWithdrawal).
 					WithArgs(userID, withdraw.Order, withdraw.Sum, withdraw.ProcessedAt).
 					WillReturnResult(sqlmock.NewResult(0, 0))
-				mock.ExpectQuery(queryReduceBalance).
+				mock.ExpectExec(queryConsumePointLots).
+					WithArgs(userID, withdraw.Sum).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectQuery(queryAddPosting).
+					WithArgs(userID, "WITHDRAWAL", "USER", "WITHDRAWALS", withdraw.Sum, withdraw.Order, int64(0), withdraw.ProcessedAt).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+				mock.ExpectQuery(queryDebitBalance).
+					WithArgs(userID, withdraw.Sum).
+					WillReturnRows(sqlmock.NewRows([]string{"sum"}))
+				mock.ExpectRollback()
+			},
+			userID: 1,
+			withdraw: model.Withdraw{
+				Order:       "123",
+				Sum:         110,
+				ProcessedAt: time.Now(),
+			},
+			err:     dberr.ErrNegativeBalance.Error(),
+			wantErr: true,
+		},
+		{
+			name: "unexpected err on debiting balance",
+			mockBehavior: func(userID int64, withdraw model.Withdraw) {
+				mock.ExpectBegin()
+				mock.ExpectExec(queryAddWithdrawal).
+					WithArgs(userID, withdraw.Order, withdraw.Sum, withdraw.ProcessedAt).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(queryConsumePointLots).
+					WithArgs(userID, withdraw.Sum).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectQuery(queryAddPosting).
+					WithArgs(userID, "WITHDRAWAL", "USER", "WITHDRAWALS", withdraw.Sum, withdraw.Order, int64(0), withdraw.ProcessedAt).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+				mock.ExpectQuery(queryDebitBalance).
 					WithArgs(userID, withdraw.Sum).
 					WillReturnError(errors.New("unexpected error"))
 				mock.ExpectRollback()
@@ -132,7 +211,7 @@ func TestPg_AddWithdrawal(t *testing.T) {
 			userID: 1,
 			withdraw: model.Withdraw{
 				Order:       "123",
-				Sum:         1.1,
+				Sum:         110,
 				ProcessedAt: time.Now(),
 			},
 			err:     "unexpected error",
@@ -157,6 +236,78 @@ func TestPg_AddWithdrawal(t *testing.T) {
 	}
 }
 
+// TestPg_AddWithdrawal_concurrent withdraws from one user in many goroutines at once on the real database,
+// the test is skipped unless DATABASE_URI is set.
+// Only the withdrawals covered by the balance are debited, the others get ErrNegativeBalance.
+func TestPg_AddWithdrawal_concurrent(t *testing.T) {
+	pgConn := os.Getenv("DATABASE_URI")
+	if pgConn == "" {
+		t.Skip("DATABASE_URI is not set")
+	}
+
+	const (
+		withdrawalsCount = 50
+		accrual          = model.Money(100000)
+		sum              = model.Money(30000)
+	)
+
+	testPg, err := New(pgConn)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, testPg.Close())
+	}()
+
+	ctx := context.Background()
+	prefix := fmt.Sprint(time.Now().UnixNano())
+	userID, err := testPg.AddUser(ctx, &model.User{Login: "concurrent-" + prefix, Password: "password"})
+	require.NoError(t, err)
+
+	order := model.Order{UserID: userID, Number: prefix, Status: model.OrderStatusNew.String(), UploadedAt: time.Now()}
+	require.NoError(t, testPg.AddOrder(ctx, &order))
+	order.Status, order.Accrual = model.OrderStatusProcessed.String(), accrual
+	require.NoError(t, testPg.UpdateOrderStatuses([]model.Order{order}, time.Now().AddDate(1, 0, 0)))
+
+	var (
+		wg                sync.WaitGroup
+		succeeded, denied atomic.Int64
+	)
+	for i := 0; i < withdrawalsCount; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			err := testPg.AddWithdrawal(ctx, userID, model.Withdraw{
+				Order:       fmt.Sprintf("%s%03d", prefix, i),
+				Sum:         sum,
+				ProcessedAt: time.Now(),
+			})
+			switch {
+			case err == nil:
+				succeeded.Add(1)
+			case errors.Is(err, dberr.ErrNegativeBalance):
+				denied.Add(1)
+			default:
+				t.Errorf("unexpected error: %s", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	covered := int64(accrual / sum)
+	assert.Equal(t, covered, succeeded.Load())
+	assert.Equal(t, withdrawalsCount-covered, denied.Load())
+
+	// the raw sum, GetBalance does not show a negative balance
+	var balance model.Money
+	require.NoError(t, testPg.db.QueryRowContext(ctx, `SELECT sum FROM balance WHERE user_id = $1`, userID).Scan(&balance))
+	assert.GreaterOrEqual(t, balance, model.Money(0))
+	assert.Equal(t, accrual-sum*model.Money(covered), balance)
+
+	_, _, withdrawn, err := testPg.GetBalance(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, sum*model.Money(covered), withdrawn)
+}
+
 func TestPg_GetWithdrawals(t *testing.T) {
 	testPg := Pg{}
 	testPg.withdrawalsStmts = &withdrawalsStmts{}
@@ -178,7 +329,9 @@ func TestPg_GetWithdrawals(t *testing.T) {
 		name         string
 		mockBehavior func(userID int64)
 		userID       int64
+		filter       model.ListFilter
 		expected     []model.Withdraw
+		expectedNext *model.Cursor
 		err          error
 		wantErr      bool
 	}{
@@ -186,34 +339,65 @@ func TestPg_GetWithdrawals(t *testing.T) {
 			name: "OK",
 			mockBehavior: func(userID int64) {
 				mock.ExpectQuery(queryGetWithdrawals).
-					WithArgs(userID).
-					WillReturnRows(sqlmock.NewRows([]string{"order_number", "sum", "processed_at"}).
-						AddRow("123", 123.0, time.Unix(1, 1)).
-						AddRow("321", 321.0, time.Unix(2, 3)))
+					WithArgs(userID, sql.NullTime{}, sql.NullTime{}, sql.NullTime{}, int64(0), 3).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "order_number", "sum", "processed_at", "status"}).
+						AddRow(1, "123", "123.00", time.Unix(1, 1), "PROCESSED").
+						AddRow(2, "321", "321.00", time.Unix(2, 3), "CANCELLED"))
 
 			},
 			userID: 1,
+			filter: model.ListFilter{Limit: 2},
 			expected: []model.Withdraw{
 				{
 					Order:       "123",
-					Sum:         123.0,
+					Sum:         12300,
 					ProcessedAt: time.Unix(1, 1),
+					Status:      "PROCESSED",
 				},
 				{
 					Order:       "321",
-					Sum:         321.0,
+					Sum:         32100,
 					ProcessedAt: time.Unix(2, 3),
+					Status:      "CANCELLED",
 				},
 			},
 		},
+		{
+			name: "page with next page",
+			mockBehavior: func(userID int64) {
+				mock.ExpectQuery(queryGetWithdrawals).
+					WithArgs(userID, sql.NullTime{Time: time.Unix(1, 0), Valid: true}, sql.NullTime{},
+						sql.NullTime{Time: time.Unix(1, 1), Valid: true}, int64(1), 2).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "order_number", "sum", "processed_at", "status"}).
+						AddRow(2, "321", "321.00", time.Unix(2, 3), "CANCELLED").
+						AddRow(3, "456", "1.00", time.Unix(3, 0), "PROCESSED"))
+
+			},
+			userID: 1,
+			filter: model.ListFilter{
+				From:  time.Unix(1, 0),
+				After: &model.Cursor{Time: time.Unix(1, 1), ID: 1},
+				Limit: 1,
+			},
+			expected: []model.Withdraw{
+				{
+					Order:       "321",
+					Sum:         32100,
+					ProcessedAt: time.Unix(2, 3),
+					Status:      "CANCELLED",
+				},
+			},
+			expectedNext: &model.Cursor{Time: time.Unix(2, 3), ID: 2},
+		},
 		{
 			name: "unexpected error",
 			mockBehavior: func(userID int64) {
 				mock.ExpectQuery(queryGetWithdrawals).
-					WithArgs(userID).
+					WithArgs(userID, sql.NullTime{}, sql.NullTime{}, sql.NullTime{}, int64(0), 3).
 					WillReturnError(errors.New("unexpected error"))
 			},
 			userID:  1,
+			filter:  model.ListFilter{Limit: 2},
 			wantErr: true,
 			err:     errors.New("unexpected error"),
 		},
@@ -222,12 +406,158 @@ func TestPg_GetWithdrawals(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.mockBehavior(tt.userID)
 			gCtx, _ := gin.CreateTestContext(httptest.NewRecorder())
-			withdraws, err := testPg.GetWithdrawals(gCtx, tt.userID)
+			withdraws, next, err := testPg.GetWithdrawals(gCtx, tt.userID, tt.filter)
 			if tt.wantErr {
 				assert.Error(t, err)
 			} else {
 				assert.NoError(t, err)
 				assert.Equal(t, tt.expected, withdraws)
+				assert.Equal(t, tt.expectedNext, next)
+			}
+			if err = mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestPg_CancelWithdrawal(t *testing.T) {
+	testPg := Pg{}
+	testPg.withdrawalsStmts = &withdrawalsStmts{}
+	testPg.balanceStmts = &balanceStmts{}
+	testPg.ledgerStmts = &ledgerStmts{}
+	testPg.pointLotsStmts = &pointLotsStmts{}
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	testPg.db = db
+	mock.ExpectPrepare(queryCancelWithdrawal)
+	if testPg.withdrawalsStmts.stmtCancelWithdrawal, err = testPg.db.PrepareContext(context.Background(), queryCancelWithdrawal); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing create user statement", err)
+	}
+	mock.ExpectPrepare(queryGetWithdrawalStatus)
+	if testPg.withdrawalsStmts.stmtGetWithdrawalStatus, err = testPg.db.PrepareContext(context.Background(), queryGetWithdrawalStatus); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing create user statement", err)
+	}
+	mock.ExpectPrepare(queryGetPostingID)
+	if testPg.ledgerStmts.stmtGetPostingID, err = testPg.db.PrepareContext(context.Background(), queryGetPostingID); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing create user statement", err)
+	}
+	mock.ExpectPrepare(queryAddPosting)
+	if testPg.ledgerStmts.stmtAddPosting, err = testPg.db.PrepareContext(context.Background(), queryAddPosting); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing create user statement", err)
+	}
+	mock.ExpectPrepare(queryAddPointLot)
+	if testPg.pointLotsStmts.stmtAddPointLot, err = testPg.db.PrepareContext(context.Background(), queryAddPointLot); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing create user statement", err)
+	}
+	mock.ExpectPrepare(queryIncreaseBalance)
+	if testPg.balanceStmts.stmtIncreaseBalance, err = testPg.db.PrepareContext(context.Background(), queryIncreaseBalance); err != nil {
+		t.Fatalf("an error '%s' was not expected when preparing create user statement", err)
+	}
+
+	cancelledAt := time.Now()
+	pointsExpireAt := cancelledAt.AddDate(1, 0, 0)
+	processedAt := cancelledAt.Add(-time.Hour)
+
+	tests := []struct {
+		name         string
+		mockBehavior func()
+		expected     model.Withdraw
+		err          error
+		wantErr      bool
+	}{
+		{
+			name: "OK",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(queryCancelWithdrawal).
+					WithArgs("123", cancelledAt).
+					WillReturnRows(sqlmock.NewRows([]string{"user_id", "sum", "processed_at"}).AddRow(1, "110", processedAt))
+				mock.ExpectQuery(queryGetPostingID).
+					WithArgs("WITHDRAWAL", "123").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+				mock.ExpectQuery(queryAddPosting).
+					WithArgs(int64(1), "REVERSAL", "WITHDRAWALS", "USER", model.Money(11000), "123", int64(7), cancelledAt).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+				mock.ExpectExec(queryAddPointLot).
+					WithArgs(int64(1), "123", model.Money(11000), cancelledAt, pointsExpireAt).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(queryIncreaseBalance).
+					WithArgs(int64(1), model.Money(11000)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			expected: model.Withdraw{Order: "123", Sum: 11000, ProcessedAt: processedAt, Status: "CANCELLED"},
+		},
+		{
+			name: "withdrawal is not exists",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(queryCancelWithdrawal).
+					WithArgs("123", cancelledAt).
+					WillReturnRows(sqlmock.NewRows([]string{"user_id", "sum", "processed_at"}))
+				mock.ExpectQuery(queryGetWithdrawalStatus).
+					WithArgs("123").
+					WillReturnRows(sqlmock.NewRows([]string{"status"}))
+				mock.ExpectRollback()
+			},
+			err:     dberr.ErrWithdrawalIsNotExists,
+			wantErr: true,
+		},
+		{
+			name: "withdrawal is already cancelled",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(queryCancelWithdrawal).
+					WithArgs("123", cancelledAt).
+					WillReturnRows(sqlmock.NewRows([]string{"user_id", "sum", "processed_at"}))
+				mock.ExpectQuery(queryGetWithdrawalStatus).
+					WithArgs("123").
+					WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("CANCELLED"))
+				mock.ExpectRollback()
+			},
+			err:     dberr.ErrWithdrawalIsAlreadyCancelled,
+			wantErr: true,
+		},
+		{
+			name: "unexpected err on increasing balance",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(queryCancelWithdrawal).
+					WithArgs("123", cancelledAt).
+					WillReturnRows(sqlmock.NewRows([]string{"user_id", "sum", "processed_at"}).AddRow(1, "110", processedAt))
+				mock.ExpectQuery(queryGetPostingID).
+					WithArgs("WITHDRAWAL", "123").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}))
+				mock.ExpectQuery(queryAddPosting).
+					WithArgs(int64(1), "REVERSAL", "WITHDRAWALS", "USER", model.Money(11000), "123", int64(0), cancelledAt).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+				mock.ExpectExec(queryAddPointLot).
+					WithArgs(int64(1), "123", model.Money(11000), cancelledAt, pointsExpireAt).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(queryIncreaseBalance).
+					WithArgs(int64(1), model.Money(11000)).
+					WillReturnError(errors.New("unexpected error"))
+				mock.ExpectRollback()
+			},
+			err:     errors.New("unexpected error"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockBehavior()
+			withdraw, err := testPg.CancelWithdrawal(context.Background(), "123", cancelledAt, pointsExpireAt)
+			if tt.wantErr {
+				assert.ErrorContains(t, err, tt.err.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, withdraw)
 			}
 			if err = mock.ExpectationsWereMet(); err != nil {
 				t.Errorf("there were unfulfilled expectations: %s", err)
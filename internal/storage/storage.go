@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"errors"
+	"time"
 
 	"practicum-gophermart/internal/config"
 	"practicum-gophermart/internal/model"
@@ -18,14 +19,30 @@ type Storage interface {
 	GetUser(ctx context.Context, login string, password string) (*model.User, error)
 	GetUserPassword(ctx context.Context, login string) (string, error)
 	UpdateRefreshSession(ctx context.Context, newRefreshSession *model.RefreshSession) error
-	GetRefreshSessionByToken(ctx context.Context, refreshToken string) (*model.RefreshSession, error)
+	RotateRefreshSession(ctx context.Context, refreshToken string, rotatedAt time.Time, newRefreshSession *model.RefreshSession) error
+	GetSessions(ctx context.Context, userID int64) ([]model.Session, error)
+	RevokeSession(ctx context.Context, userID int64, sessionID string, revokedAt time.Time) error
+	RevokeSessions(ctx context.Context, userID int64, revokedAt time.Time) error
+	IsSessionActive(ctx context.Context, userID int64, sessionID string) (bool, error)
 	AddOrder(ctx context.Context, order *model.Order) error
-	GetOrdersByUser(ctx context.Context, userID int64) ([]model.Order, error)
-	GetOrdersByStatuses(statuses []string) ([]model.Order, error)
-	UpdateOrderStatuses(newOrderStatuses []model.Order) error
-	GetBalance(ctx context.Context, userID int64) (balance float64, withdrawn float64, err error)
+	GetOrdersByUser(ctx context.Context, userID int64, filter model.ListFilter) ([]model.Order, *model.Cursor, error)
+	ClaimOrdersToPoll(ctx context.Context, owner string, leaseExpiresAt time.Time, statuses []string, dueAt time.Time,
+		limit int) ([]model.Order, error)
+	UpdateOrderStatuses(newOrderStatuses []model.Order, pointsExpireAt time.Time) error
+	UpdateOrdersPollingState(ctx context.Context, owner string, orders []model.Order) error
+	GetBalance(ctx context.Context, userID int64) (balance, held, withdrawn model.Money, err error)
 	AddWithdrawal(ctx context.Context, userID int64, withdraw model.Withdraw) error
-	GetWithdrawals(ctx context.Context, userID int64) ([]model.Withdraw, error)
+	GetWithdrawals(ctx context.Context, userID int64, filter model.ListFilter) ([]model.Withdraw, *model.Cursor, error)
+	CancelWithdrawal(ctx context.Context, orderNumber string, cancelledAt, pointsExpireAt time.Time) (model.Withdraw, error)
+	GetBalanceHistory(ctx context.Context, userID int64, filter model.ListFilter) ([]model.BalanceOperation, *model.Cursor, error)
+	ExpirePointLots(ctx context.Context, expiredAt time.Time, limit int) ([]model.PointLot, error)
+	ClaimIdempotencyKey(ctx context.Context, key model.IdempotencyKey, expiredBefore, claimExpiredBefore time.Time) (model.IdempotencyKey, bool, error)
+	SaveIdempotentResponse(ctx context.Context, userID int64, key string, code int, body []byte) error
+	DeleteIdempotencyKey(ctx context.Context, userID int64, key string) error
+	AddHold(ctx context.Context, hold model.Hold) error
+	CaptureHold(ctx context.Context, userID int64, orderNumber string, capturedAt time.Time) (model.Hold, error)
+	ReleaseHold(ctx context.Context, userID int64, orderNumber string, releasedAt time.Time) (model.Hold, error)
+	ReleaseExpiredHolds(ctx context.Context, expiredAt time.Time, limit int) ([]model.Hold, error)
 	Close() error
 }
 